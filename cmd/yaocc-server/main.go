@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/agent"
 	"github.com/dev-dhg/yaocc/pkg/config"
@@ -12,6 +17,10 @@ import (
 	"github.com/dev-dhg/yaocc/pkg/server"
 )
 
+// shutdownTimeout is how long in-flight turns may take to finish after SIGINT or SIGTERM
+// before they are cancelled.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	configPath := flag.String("config", "config.json", "path to config file")
 	logLevel := flag.String("level", "info", "log level (info, verbose)")
@@ -47,11 +56,11 @@ func main() {
 	// Start Telegram Bot
 	// Start Messaging Clients
 	providers := make(map[string]messaging.Provider)
+	var tgClient *telegram.Client
 	for _, msgCfg := range cfg.Messaging {
 		if msgCfg.Provider == "telegram" && msgCfg.Telegram.Enabled {
 			log.Printf("Initializing Telegram Bot...")
-			tgClient := telegram.NewClient(msgCfg.Telegram, myAgent)
-			go tgClient.Start() // Use interface method
+			tgClient = telegram.NewClient(msgCfg.Telegram, myAgent)
 			providers["telegram"] = tgClient
 			// For now, we only support one telegram client in the scheduler/server
 			break
@@ -62,8 +71,20 @@ func main() {
 
 	// Start Cron/Heartbeat Scheduler
 	scheduler := cron.NewScheduler(cfg, configDir, myAgent, providers)
+	scheduler.ConfigPath = loadedPath
 	scheduler.Start()
-	defer scheduler.Stop()
+
+	// Incoming messages can fire message-triggered jobs
+	if tgClient != nil {
+		tgClient.OnMessage = func(provider, chatID, text string) {
+			scheduler.MatchMessage(provider, chatID, text)
+		}
+		go tgClient.Start() // Use interface method
+	}
+
+	srv := server.NewServer(cfg, myAgent, providers, scheduler)
+	srv.ConfigPath = loadedPath
+	srv.Jobs = server.NewJobStore(configDir)
 
 	// Start Config Watcher
 	go config.WatchConfig(loadedPath, func(newCfg *config.Config) {
@@ -72,14 +93,38 @@ func main() {
 
 		myAgent.UpdateConfig(newCfg)
 		scheduler.Reload(newCfg)
+		srv.SetAuth(newCfg.Server)
 
 		// If we had a mechanism to update Telegram client, we would do it here too.
 		// For now, most telegram changes require restart, but we can update allowed users if we refactor Client.
 	})
 
 	// Start Server
-	srv := server.NewServer(cfg, myAgent, providers, scheduler)
-	if err := srv.Run(); err != nil {
-		log.Fatalf("Server error: %v", err)
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- srv.Run() }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	case sig := <-signals:
+		log.Printf("Received %v, shutting down (waiting up to %v for running turns)...", sig, shutdownTimeout)
+	}
+	signal.Stop(signals) // A second signal kills the process
+
+	scheduler.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown: %v", err)
+	}
+	for name, client := range myAgent.MCPServers {
+		log.Printf("Stopping MCP server '%s'", name)
+		client.Close()
 	}
+	log.Printf("Server stopped")
 }
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/dev-dhg/yaocc/pkg/config"
 	"github.com/dev-dhg/yaocc/pkg/utils"
 )
 
@@ -29,6 +29,7 @@ func runChat(args []string) {
 	sessionID := chatCmd.String("session", "", "Session ID for the conversation (optional if provider/chat-id set)")
 	provider := chatCmd.String("provider", "", "Provider context (e.g., telegram, local)")
 	chatID := chatCmd.String("chat-id", "", "Chat ID for the provider context")
+	configPath := chatCmd.String("config", "config.json", "Path to config file (server port and token)")
 
 	if err := chatCmd.Parse(args); err != nil {
 		fmt.Println("Error parsing flags:", err)
@@ -36,12 +37,17 @@ func runChat(args []string) {
 	}
 
 	if chatCmd.NArg() < 1 {
-		fmt.Println("Usage: yaocc chat [--session <id>] [--provider <name>] [--chat-id <id>] <message>")
+		fmt.Println("Usage: yaocc chat [--session <id>] [--provider <name>] [--chat-id <id>] [--config <path>] <message>")
 		return
 	}
 
 	message := chatCmd.Arg(0)
-	serverURL := "http://localhost:8080/chat" // TODO: Read from config or flags
+
+	// Without a config, the default port and $YAOCC_TOKEN are used
+	cfg, _, _, err := config.LoadConfig(*configPath)
+	if err != nil {
+		cfg = &config.Config{}
+	}
 
 	reqBody, _ := json.Marshal(ChatRequest{
 		SessionID: *sessionID,
@@ -49,7 +55,7 @@ func runChat(args []string) {
 		ChatID:    *chatID,
 		Message:   message,
 	})
-	resp, err := http.Post(serverURL, "application/json", bytes.NewBuffer(reqBody))
+	resp, err := postServer(cfg, "/chat", reqBody)
 	if err != nil {
 		fmt.Printf("Error connecting to server: %v\n", err)
 		return
@@ -59,6 +65,9 @@ func runChat(args []string) {
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
 		fmt.Printf("Server returned error: %s\n", string(body))
+		if resp.StatusCode == http.StatusUnauthorized {
+			fmt.Println("Set YAOCC_TOKEN or server.authToken in the config to a valid server token.")
+		}
 		return
 	}
 
@@ -1,21 +1,21 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/cron"
 )
 
 func runCron(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: yaocc cron <list|add|remove|run> [args]")
+		fmt.Println("Usage: yaocc cron <list|add|remove|run|history|show|edit|enable|disable> [args]")
 		return
 	}
 
@@ -29,6 +29,16 @@ func runCron(args []string) {
 		runCronRemove(args[1:])
 	case "run":
 		runCronRun(args[1:])
+	case "history":
+		runCronHistory(args[1:])
+	case "show":
+		runCronShow(args[1:])
+	case "edit":
+		runCronEdit(args[1:])
+	case "enable":
+		runCronSetEnabled(args[1:], true)
+	case "disable":
+		runCronSetEnabled(args[1:], false)
 	default:
 		fmt.Printf("Unknown cron command: %s\n", cmd)
 	}
@@ -55,46 +65,83 @@ func runCronList(args []string) {
 	}
 
 	fmt.Println("Configured Jobs:")
-	for i, job := range cfg.Cron {
+	for _, job := range cfg.Cron {
 		desc := job.Prompt
-		if job.Type == "script" {
+		if job.IsDigest() {
+			desc = "Digest of " + describeDigest(job.Digest)
+		} else if job.Type == "script" {
 			desc = fmt.Sprintf("Script: %s", job.Script)
 		}
 
 		stateString := "stateless"
 		if job.UseHistory {
 			stateString = "stateful/history-aware"
+		} else if job.UseTools {
+			stateString = "stateless, with tools"
 		}
 
-		fmt.Printf("  [%d] %s: %s (%s) [%s]\n", i, job.Name, job.Schedule, desc, stateString)
+		if !job.IsEnabled() {
+			stateString += ", disabled"
+		}
+
+		fmt.Printf("  [%s] %s: %s (%s) [%s]\n", job.JobID(), job.Name, describeTiming(job), desc, stateString)
 	}
 }
 
+// runCronAdd adds a job. Like edit, it leaves scheduling to the server's config watcher.
 func runCronAdd(args []string) {
 	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
 	configPath := addCmd.String("config", "config.json", "Path to config file")
 	name := addCmd.String("name", "", "Name of the cron job")
 	schedule := addCmd.String("schedule", "", "Cron schedule (e.g. \"0 9 * * *\")")
+	at := addCmd.String("at", "", "Run once at this time (RFC3339 or a duration like 2h), then remove the job")
+	when := addCmd.String("when", "", "Schedule in plain English, e.g. \"every weekday at 8:30\" or \"tomorrow at 9\"")
+	onFile := addCmd.String("on-file", "", "Run when files under this path (relative to the config dir) change")
+	filePattern := addCmd.String("file-pattern", "", "Only watch file names matching this glob, e.g. \"*.md\"")
+	debounce := addCmd.Duration("debounce", 0, "Wait until file changes settle for this long (default 2s)")
+	onWebhook := addCmd.Bool("on-webhook", false, "Run when the webhook POST /hooks/<name> is called")
+	webhookSecret := addCmd.String("webhook-secret", "", "Secret webhook callers must send (X-Hook-Secret, ?secret= or X-Hub-Signature-256)")
+	webhookToken := addCmd.Bool("webhook-token", false, "Webhook callers must send an API token with the cron scope (implied without --webhook-secret)")
+	onMessage := addCmd.String("on-message", "", "Run when an incoming message matches this regular expression")
 	prompt := addCmd.String("prompt", "", "Prompt for the agent (type=prompt)")
 	script := addCmd.String("script", "", "Script path (type=script)")
+	var include, includeScripts multiFlag
+	addCmd.Var(&include, "include", "Make this a digest of the named job's output (repeatable)")
+	addCmd.Var(&includeScripts, "include-script", "Make this a digest including this script's output (repeatable)")
 	sessionID := addCmd.String("session", "", "Session ID context (optional)")
 	useHistory := addCmd.Bool("use-history", false, "Use target session history. If true, runs for each target separately.")
-	targetProvider := addCmd.String("target-provider", "", "Target provider (e.g. telegram)")
-	targetID := addCmd.String("target-id", "", "Target ID (e.g. chat_id)")
+	useTools := addCmd.Bool("use-tools", false, "Run the prompt with tools (search, fetch, skills) in an ephemeral session that leaves the chat history untouched")
+	logSession := addCmd.String("log-session", "", "Session to append --use-tools runs to for review (optional)")
+	targetProvider := addCmd.String("target-provider", "", "Target provider: a messaging provider (e.g. telegram), local (inbox), file, webhook or email")
+	targetID := addCmd.String("target-id", "", "Target ID: chat_id, inbox name, file path (e.g. memory/{date}.md), URL or email addresses")
+	targetSecret := addCmd.String("target-secret", "", "HMAC key to sign webhook target requests with (optional)")
+	timeout := addCmd.Duration("timeout", 0, "Maximum run time, e.g. 5m (default: no limit)")
+	retries := addCmd.Int("retries", 0, "Extra attempts for failed LLM calls and deliveries")
+	backoff := addCmd.Duration("backoff", 0, "Delay before the first retry, doubled after each one (default 2s)")
+	concurrency := addCmd.String("concurrency", "", "When the previous run is still active: allow (default), skip or queue")
+	catchUp := addCmd.String("catch-up", "", "Runs missed while the server was down: none (default), once or all")
+	catchUpWindow := addCmd.Duration("catch-up-window", 0, "How far back missed runs are caught up (default 24h)")
+	notifyOn := addCmd.String("notify-on", "", "When to send the result: always (default), failure, change, match or judge")
+	notifyPattern := addCmd.String("notify-pattern", "", "Regular expression for --notify-on match")
+	notifyCondition := addCmd.String("notify-condition", "", "Condition the LLM checks for --notify-on judge")
+	notifyResolved := addCmd.Bool("notify-resolved", false, "Send a message once the condition clears")
 
 	if err := addCmd.Parse(args); err != nil {
 		fmt.Println("Error parsing flags:", err)
 		return
 	}
 
-	if *name == "" || *schedule == "" {
-		fmt.Println("Usage: yaocc cron add --name <name> --schedule <schedule> [--prompt <prompt> | --script <script>] [--use-history] [--target-provider <provider> --target-id <id>] [--config <path>]")
+	if *name == "" || (*schedule == "" && *at == "" && *when == "" && *onFile == "" && !*onWebhook && *onMessage == "") {
+		fmt.Println("Usage: yaocc cron add --name <name> (--schedule <schedule> | --at <time> | --when <text> | --on-file <path> [--file-pattern <glob>] [--debounce <duration>] | --on-webhook [--webhook-secret <secret>] [--webhook-token] | --on-message <regex>) [--prompt <prompt> | --script <script> | --include <job>... [--include-script <script>...] [--prompt <instruction>]] [--use-history | --use-tools [--log-session <id>]] [--target-provider <provider> --target-id <id> [--target-secret <key>]] [--timeout <duration>] [--retries <n>] [--backoff <duration>] [--concurrency allow|skip|queue] [--catch-up none|once|all] [--catch-up-window <duration>] [--notify-on failure|change|match|judge [--notify-pattern <regex>] [--notify-condition <text>] [--notify-resolved]] [--config <path>]")
 		return
 	}
 
 	// Determine Type
+	digest := digestSources(include, includeScripts)
 	jobType := "prompt"
-	if *script != "" {
+	if len(digest) > 0 {
+		jobType = "digest"
+	} else if *script != "" {
 		jobType = "script"
 	}
 
@@ -103,53 +150,198 @@ func runCronAdd(args []string) {
 		return
 	}
 
+	trigger, err := cronTrigger(*onFile, *onWebhook, *onMessage, *filePattern, *webhookSecret, *webhookToken, *debounce)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	var timing config.CronJob
+	if trigger != nil {
+		if *schedule != "" || *at != "" || *when != "" {
+			fmt.Println("Error: a triggered job cannot also have --schedule, --at or --when.")
+			return
+		}
+		timing.Trigger = trigger
+	} else if timing, err = resolveCronTiming(*configPath, *schedule, *at, *when); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
 	// Build Targets
 	var targets []config.CronTarget
 	if *targetProvider != "" && *targetID != "" {
 		targets = append(targets, config.CronTarget{
 			Provider: *targetProvider,
 			ID:       *targetID,
+			Secret:   *targetSecret,
 		})
 	}
 
 	// Prepare new job
 	newJob := config.CronJob{
 		Name:       *name,
-		Schedule:   *schedule,
+		Schedule:   timing.Schedule,
+		At:         timing.At,
+		Trigger:    timing.Trigger,
 		Type:       jobType,
 		Prompt:     *prompt,
 		Script:     *script,
 		SessionID:  *sessionID,
 		UseHistory: *useHistory,
+		UseTools:   *useTools,
+		LogSession: *logSession,
 		Targets:    targets,
+		Digest:     digest,
+
+		TimeoutMs:   int(timeout.Milliseconds()),
+		Retries:     *retries,
+		BackoffMs:   int(backoff.Milliseconds()),
+		Concurrency: *concurrency,
+
+		CatchUp:         *catchUp,
+		CatchUpWindowMs: int(catchUpWindow.Milliseconds()),
+	}
+	if *notifyOn != "" && *notifyOn != "always" {
+		newJob.Notify = &config.CronNotifyConfig{On: *notifyOn, Pattern: *notifyPattern, Condition: *notifyCondition, Resolved: *notifyResolved}
+	}
+	// Update Config
+	newJob, err = config.AddCronJob(*configPath, newJob)
+	if err != nil {
+		fmt.Printf("Error updating configuration: %v\n", err)
+		return
 	}
 
-	// Acquire Lock
-	if err := config.AcquireConfigLock(); err != nil {
-		fmt.Printf("Warning: Failed to acquire config lock: %v. Proceeding anyway.\n", err)
+	if newJob.IsOneShot() {
+		fmt.Printf("Added cron job: %s (id: %s), runs once at %s\n", *name, newJob.ID, newJob.At)
+	} else if newJob.IsTriggered() {
+		fmt.Printf("Added cron job: %s (id: %s), runs %s\n", *name, newJob.ID, describeTiming(newJob))
 	} else {
-		defer config.ReleaseConfigLock()
+		fmt.Printf("Added cron job: %s (id: %s), schedule \"%s\"\n", *name, newJob.ID, newJob.Schedule)
 	}
+}
 
-	// Update Config
-	err := config.UpdateConfigRawWithPath(*configPath, func(cfg *config.Config) error {
-		// Check duplicates
-		for _, job := range cfg.Cron {
-			if strings.EqualFold(job.Name, *name) {
-				return fmt.Errorf("job with name '%s' already exists", *name)
-			}
+// cronTrigger builds a trigger from the --on-* flags, or returns nil if none of them is given.
+// A webhook without a secret requires an API token.
+func cronTrigger(onFile string, onWebhook bool, onMessage, filePattern, secret string, token bool, debounce time.Duration) (*config.CronTrigger, error) {
+	var t *config.CronTrigger
+	given := 0
+	if onFile != "" {
+		t = &config.CronTrigger{Type: "file", Path: onFile, Pattern: filePattern, DebounceMs: int(debounce.Milliseconds())}
+		given++
+	}
+	if onWebhook {
+		t = &config.CronTrigger{Type: "webhook", Secret: secret, RequireToken: token || secret == ""}
+		given++
+	}
+	if onMessage != "" {
+		t = &config.CronTrigger{Type: "message", Pattern: onMessage}
+		given++
+	}
+	switch {
+	case given > 1:
+		return nil, fmt.Errorf("use only one of --on-file, --on-webhook and --on-message")
+	case t == nil && (filePattern != "" || debounce != 0):
+		return nil, fmt.Errorf("--file-pattern and --debounce require --on-file")
+	case t == nil && (secret != "" || token):
+		return nil, fmt.Errorf("--webhook-secret and --webhook-token require --on-webhook")
+	case t == nil:
+		return nil, nil
+	}
+	return t, t.Validate()
+}
+
+// digestSources builds the sources of a digest from --include and --include-script, in that order.
+func digestSources(jobs, scripts []string) []config.CronDigestSource {
+	var sources []config.CronDigestSource
+	for _, job := range jobs {
+		if job != "" {
+			sources = append(sources, config.CronDigestSource{Job: job})
+		}
+	}
+	for _, script := range scripts {
+		if script != "" {
+			sources = append(sources, config.CronDigestSource{Script: script})
 		}
+	}
+	return sources
+}
 
-		cfg.Cron = append(cfg.Cron, newJob)
-		return nil
-	})
+func describeDigest(sources []config.CronDigestSource) string {
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		switch {
+		case src.Label != "":
+			names = append(names, src.Label)
+		case src.Job != "":
+			names = append(names, src.Job)
+		default:
+			names = append(names, src.Script)
+		}
+	}
+	return strings.Join(names, ", ")
+}
 
-	if err != nil {
-		fmt.Printf("Error updating configuration: %v\n", err)
-		return
+// describeTiming tells when job runs, for list and history.
+func describeTiming(job config.CronJob) string {
+	switch {
+	case job.IsOneShot():
+		return "once at " + job.At
+	case !job.IsTriggered():
+		return job.Schedule
 	}
+	switch t := job.Trigger; t.Type {
+	case "file":
+		if t.Pattern != "" {
+			return fmt.Sprintf("on changes to %s in %s", t.Pattern, t.Path)
+		}
+		return "on changes in " + t.Path
+	case "webhook":
+		return "on webhook POST /hooks/" + job.Name
+	default:
+		return fmt.Sprintf("on messages matching /%s/", t.Pattern)
+	}
+}
 
-	fmt.Printf("Added cron job: %s\n", *name)
+// resolveCronTiming turns exactly one of --schedule, --at and --when into a schedule or a
+// one-shot time. Times are resolved in the configured timezone.
+func resolveCronTiming(configPath, schedule, at, when string) (config.CronJob, error) {
+	given := 0
+	for _, v := range []string{schedule, at, when} {
+		if v != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return config.CronJob{}, fmt.Errorf("use exactly one of --schedule, --at and --when")
+	}
+
+	if schedule != "" {
+		return config.CronJob{Schedule: schedule}, cron.ValidateSchedule(schedule)
+	}
+
+	loc := time.Local
+	if cfg, _, _, err := config.LoadConfig(configPath); err == nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	if at != "" {
+		t, err := cron.ParseAt(at, time.Now(), loc)
+		if err != nil {
+			return config.CronJob{}, err
+		}
+		return config.CronJob{At: t.Format(time.RFC3339)}, nil
+	}
+
+	w, err := cron.ParseWhen(when, time.Now(), loc)
+	if err != nil {
+		return config.CronJob{}, err
+	}
+	if w.Schedule != "" {
+		return config.CronJob{Schedule: w.Schedule}, cron.ValidateSchedule(w.Schedule)
+	}
+	return config.CronJob{At: w.At.Format(time.RFC3339)}, nil
 }
 
 func runCronRemove(args []string) {
@@ -162,7 +354,7 @@ func runCronRemove(args []string) {
 	}
 
 	if removeCmd.NArg() < 1 {
-		fmt.Println("Usage: yaocc cron remove <name> [--config <path>]")
+		fmt.Println("Usage: yaocc cron remove <name|id> [--config <path>]")
 		return
 	}
 	name := removeCmd.Arg(0)
@@ -174,26 +366,7 @@ func runCronRemove(args []string) {
 		defer config.ReleaseConfigLock()
 	}
 
-	err := config.UpdateConfigRawWithPath(*configPath, func(cfg *config.Config) error {
-		newCron := []config.CronJob{}
-		found := false
-		for _, job := range cfg.Cron {
-			if strings.EqualFold(job.Name, name) {
-				found = true
-				continue
-			}
-			newCron = append(newCron, job)
-		}
-
-		if !found {
-			return fmt.Errorf("job '%s' not found", name)
-		}
-
-		cfg.Cron = newCron
-		return nil
-	})
-
-	if err != nil {
+	if _, err := config.RemoveCronJob(*configPath, name); err != nil {
 		fmt.Printf("Error updating configuration: %v\n", err)
 		return
 	}
@@ -211,33 +384,27 @@ func runCronRun(args []string) {
 	}
 
 	if runCmd.NArg() < 1 {
-		fmt.Println("Usage: yaocc cron run <index> [--config <path>]")
-		fmt.Println("\nUse 'yaocc cron list' to see job indices.")
-		return
-	}
-
-	index, err := strconv.Atoi(runCmd.Arg(0))
-	if err != nil {
-		fmt.Printf("Invalid index: %s (must be a number)\n", runCmd.Arg(0))
+		fmt.Println("Usage: yaocc cron run <name|id> [--config <path>]")
+		fmt.Println("\nUse 'yaocc cron list' to see job names and IDs.")
 		return
 	}
+	ref := runCmd.Arg(0)
 
-	// Load config to get server port
+	// Load config to get server port and token
 	cfg, _, _, err := config.LoadConfig(*configPath)
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		return
 	}
 
-	port := cfg.Server.Port
-	if port == 0 {
-		port = 8080
+	idx := config.FindCronJob(cfg.Cron, ref)
+	if idx < 0 {
+		fmt.Printf("Job '%s' not found\n", ref)
+		return
 	}
 
-	serverURL := fmt.Sprintf("http://localhost:%d/cron/run", port)
-
-	reqBody, _ := json.Marshal(map[string]int{"index": index})
-	resp, err := http.Post(serverURL, "application/json", bytes.NewBuffer(reqBody))
+	reqBody, _ := json.Marshal(map[string]string{"job": cfg.Cron[idx].JobID()})
+	resp, err := postServer(cfg, "/cron/run", reqBody)
 	if err != nil {
 		fmt.Printf("Error connecting to server: %v\n", err)
 		fmt.Println("Make sure the yaocc server is running.")
@@ -256,5 +423,314 @@ func runCronRun(args []string) {
 		}
 	} else {
 		fmt.Printf("Error: %s\n", string(body))
+		if resp.StatusCode == http.StatusUnauthorized {
+			fmt.Println("Set YAOCC_TOKEN or server.authToken in the config to a valid server token.")
+		}
+	}
+}
+
+func runCronHistory(args []string) {
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	configPath := historyCmd.String("config", "config.json", "Path to config file")
+	limit := historyCmd.Int("limit", 10, "Number of runs to show")
+	asJSON := historyCmd.Bool("json", false, "Print the runs as JSON")
+
+	name, err := parseCronRef(historyCmd, args)
+	if err != nil || name == "" {
+		fmt.Println("Usage: yaocc cron history <name|id> [--limit <n>] [--json] [--config <path>]")
+		return
+	}
+
+	cfg, configDir, _, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	idx := config.FindCronJob(cfg.Cron, name)
+	if idx < 0 {
+		fmt.Printf("Job '%s' not found\n", name)
+		return
+	}
+	job := cfg.Cron[idx]
+
+	runs, err := cron.NewHistory(configDir).Runs(job.JobID(), *limit)
+	if err != nil {
+		fmt.Printf("Error reading run history: %v\n", err)
+		return
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(runs, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("Job: %s [%s] (%s)\n", job.Name, job.JobID(), describeTiming(job))
+	if !job.IsEnabled() {
+		fmt.Println("Next run: disabled")
+	} else if next, err := cron.NextJobFire(job, cfg.Timezone, time.Now()); err == nil {
+		fmt.Printf("Next run: %s\n", next.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded yet.")
+		return
+	}
+
+	fmt.Println("Recent runs:")
+	for _, run := range runs {
+		exit := ""
+		if run.ExitCode != nil {
+			exit = fmt.Sprintf(", exit %d", *run.ExitCode)
+		}
+		fmt.Printf("  %s  %-7s %s (%s, %dms%s)\n", run.StartedAt.Local().Format("2006-01-02 15:04:05"), run.Status, run.Trigger, summarizeDeliveries(run.Deliveries), run.DurationMs, exit)
+		if run.Error != "" {
+			fmt.Printf("      error: %s\n", firstLine(run.Error))
+		}
+		for _, d := range run.Deliveries {
+			if d.Error != "" {
+				fmt.Printf("      delivery to %s/%s failed: %s\n", d.Provider, d.ID, firstLine(d.Error))
+			}
+		}
+	}
+}
+
+func runCronShow(args []string) {
+	showCmd := flag.NewFlagSet("show", flag.ExitOnError)
+	configPath := showCmd.String("config", "config.json", "Path to config file")
+
+	ref, err := parseCronRef(showCmd, args)
+	if err != nil || ref == "" {
+		fmt.Println("Usage: yaocc cron show <name|id> [--config <path>]")
+		return
+	}
+
+	cfg, configDir, _, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	idx := config.FindCronJob(cfg.Cron, ref)
+	if idx < 0 {
+		fmt.Printf("Job '%s' not found\n", ref)
+		return
+	}
+
+	st := cron.JobStatus{CronJob: cfg.Cron[idx]}
+	st.ID = st.JobID()
+	if st.IsEnabled() {
+		if next, err := cron.NextJobFire(st.CronJob, cfg.Timezone, time.Now()); err == nil {
+			st.NextRun = &next
+		}
+	}
+	if last, err := cron.NewHistory(configDir).Last(st.ID); err == nil {
+		st.LastRun = last
+	}
+
+	out, _ := json.MarshalIndent(st, "", "  ")
+	fmt.Println(string(out))
+}
+
+// runCronEdit changes the given fields of a job. The config lock is not taken so the
+// server's config watcher reschedules the job.
+func runCronEdit(args []string) {
+	editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+	configPath := editCmd.String("config", "config.json", "Path to config file")
+	editCmd.String("name", "", "New name of the cron job")
+	editCmd.String("schedule", "", "New cron schedule")
+	at := editCmd.String("at", "", "Run once at this time instead (RFC3339 or a duration like 2h)")
+	when := editCmd.String("when", "", "New schedule in plain English, e.g. \"every monday at 9\"")
+	onFile := editCmd.String("on-file", "", "Run on changes under this path instead (replaces the trigger)")
+	filePattern := editCmd.String("file-pattern", "", "Only watch file names matching this glob")
+	debounce := editCmd.Duration("debounce", 0, "Wait until file changes settle for this long (0 for the default)")
+	onWebhook := editCmd.Bool("on-webhook", false, "Run when the webhook POST /hooks/<name> is called instead (replaces the trigger)")
+	webhookSecret := editCmd.String("webhook-secret", "", "Secret webhook callers must send")
+	webhookToken := editCmd.Bool("webhook-token", false, "Webhook callers must send an API token with the cron scope (implied without --webhook-secret)")
+	onMessage := editCmd.String("on-message", "", "Run when an incoming message matches this regular expression instead (replaces the trigger)")
+	editCmd.String("prompt", "", "New prompt (empty to remove)")
+	editCmd.String("script", "", "New script (empty to remove)")
+	var include, includeScripts multiFlag
+	editCmd.Var(&include, "include", "Replace the digest sources with this job (repeatable; with --include-script)")
+	editCmd.Var(&includeScripts, "include-script", "Replace the digest sources with this script (repeatable; with --include)")
+	editCmd.String("session", "", "New session ID context")
+	useHistory := editCmd.Bool("use-history", false, "Use target session history")
+	useTools := editCmd.Bool("use-tools", false, "Run stateless prompts with tools in an ephemeral session")
+	editCmd.String("log-session", "", "Session to append --use-tools runs to (empty to stop logging)")
+	targetProvider := editCmd.String("target-provider", "", "Replace the targets with this provider")
+	targetID := editCmd.String("target-id", "", "Replace the targets with this ID")
+	targetSecret := editCmd.String("target-secret", "", "HMAC key for a webhook target")
+	timeout := editCmd.Duration("timeout", 0, "Maximum run time, e.g. 5m (0 for no limit)")
+	retries := editCmd.Int("retries", 0, "Extra attempts for failed LLM calls and deliveries")
+	backoff := editCmd.Duration("backoff", 0, "Delay before the first retry (0 for the default)")
+	editCmd.String("concurrency", "", "When the previous run is still active: allow, skip or queue")
+	editCmd.String("catch-up", "", "Runs missed while the server was down: none, once or all")
+	catchUpWindow := editCmd.Duration("catch-up-window", 0, "How far back missed runs are caught up (0 for the default)")
+	notifyOn := editCmd.String("notify-on", "", "When to send the result: always, failure, change, match or judge (replaces all notify settings)")
+	notifyPattern := editCmd.String("notify-pattern", "", "Regular expression for --notify-on match")
+	notifyCondition := editCmd.String("notify-condition", "", "Condition the LLM checks for --notify-on judge")
+	notifyResolved := editCmd.Bool("notify-resolved", false, "Send a message once the condition clears")
+
+	ref, err := parseCronRef(editCmd, args)
+	if err != nil || ref == "" {
+		fmt.Println("Usage: yaocc cron edit <name|id> [--name <new name>] [--schedule <schedule> | --at <time> | --when <text> | --on-file <path> [--file-pattern <glob>] [--debounce <duration>] | --on-webhook [--webhook-secret <secret>] [--webhook-token] | --on-message <regex>] [--prompt <prompt>] [--script <script>] [--include <job>...] [--include-script <script>...] [--session <id>] [--use-history=true|false] [--use-tools=true|false] [--log-session <id>] [--target-provider <provider> --target-id <id> [--target-secret <key>]] [--timeout <duration>] [--retries <n>] [--backoff <duration>] [--concurrency allow|skip|queue] [--catch-up none|once|all] [--catch-up-window <duration>] [--notify-on <condition> [--notify-pattern <regex>] [--notify-condition <text>] [--notify-resolved]] [--config <path>]")
+		return
+	}
+
+	var patch config.CronJobPatch
+	editCmd.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "name":
+			patch.Name = &value
+		case "schedule":
+			patch.Schedule = &value
+		case "prompt":
+			patch.Prompt = &value
+		case "script":
+			patch.Script = &value
+		case "session":
+			patch.SessionID = &value
+		case "use-history":
+			patch.UseHistory = useHistory
+		case "use-tools":
+			patch.UseTools = useTools
+		case "log-session":
+			patch.LogSession = &value
+		case "timeout":
+			ms := int(timeout.Milliseconds())
+			patch.TimeoutMs = &ms
+		case "retries":
+			patch.Retries = retries
+		case "backoff":
+			ms := int(backoff.Milliseconds())
+			patch.BackoffMs = &ms
+		case "concurrency":
+			patch.Concurrency = &value
+		case "catch-up":
+			patch.CatchUp = &value
+		case "catch-up-window":
+			ms := int(catchUpWindow.Milliseconds())
+			patch.CatchUpWindowMs = &ms
+		}
+	})
+	if len(include) > 0 || len(includeScripts) > 0 {
+		digest := digestSources(include, includeScripts)
+		patch.Digest = &digest
+	}
+	if *notifyOn != "" {
+		patch.Notify = &config.CronNotifyConfig{On: *notifyOn, Pattern: *notifyPattern, Condition: *notifyCondition, Resolved: *notifyResolved}
+	} else if *notifyPattern != "" || *notifyCondition != "" {
+		fmt.Println("Error: --notify-pattern and --notify-condition require --notify-on.")
+		return
+	}
+	if *targetProvider != "" || *targetID != "" {
+		if *targetProvider == "" || *targetID == "" {
+			fmt.Println("Error: --target-provider and --target-id must be given together.")
+			return
+		}
+		targets := []config.CronTarget{{Provider: *targetProvider, ID: *targetID, Secret: *targetSecret}}
+		patch.Targets = &targets
+	}
+
+	trigger, err := cronTrigger(*onFile, *onWebhook, *onMessage, *filePattern, *webhookSecret, *webhookToken, *debounce)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if trigger != nil {
+		if patch.Schedule != nil || *at != "" || *when != "" {
+			fmt.Println("Error: a triggered job cannot also have --schedule, --at or --when.")
+			return
+		}
+		patch.Trigger = trigger
+	} else if patch.Schedule != nil || *at != "" || *when != "" {
+		schedule := ""
+		if patch.Schedule != nil {
+			schedule = *patch.Schedule
+		}
+		timing, err := resolveCronTiming(*configPath, schedule, *at, *when)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if timing.IsOneShot() {
+			patch.Schedule, patch.At = nil, &timing.At
+		} else {
+			patch.Schedule = &timing.Schedule
+		}
+	}
+
+	if patch.IsEmpty() {
+		fmt.Println("Nothing to change. Pass at least one field to edit.")
+		return
+	}
+
+	job, err := config.UpdateCronJob(*configPath, ref, patch)
+	if err != nil {
+		fmt.Printf("Error updating configuration: %v\n", err)
+		return
+	}
+	fmt.Printf("Updated cron job: %s (id: %s)\n", job.Name, job.ID)
+}
+
+// runCronSetEnabled enables or disables a job. Like edit, it leaves the reload to the config watcher.
+func runCronSetEnabled(args []string, enabled bool) {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	setCmd := flag.NewFlagSet(action, flag.ExitOnError)
+	configPath := setCmd.String("config", "config.json", "Path to config file")
+
+	ref, err := parseCronRef(setCmd, args)
+	if err != nil || ref == "" {
+		fmt.Printf("Usage: yaocc cron %s <name|id> [--config <path>]\n", action)
+		return
+	}
+
+	job, err := config.UpdateCronJob(*configPath, ref, config.CronJobPatch{Enabled: &enabled})
+	if err != nil {
+		fmt.Printf("Error updating configuration: %v\n", err)
+		return
+	}
+	fmt.Printf("%sd cron job: %s (id: %s)\n", strings.ToUpper(action[:1])+action[1:], job.Name, job.ID)
+}
+
+// parseCronRef parses flags that may come before or after the job's name or ID.
+func parseCronRef(fs *flag.FlagSet, args []string) (string, error) {
+	ref := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		ref, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if ref == "" && fs.NArg() > 0 {
+		// Flags after the ref
+		ref = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return "", err
+		}
+	}
+	return ref, nil
+}
+
+func summarizeDeliveries(deliveries []cron.Delivery) string {
+	sent := 0
+	for _, d := range deliveries {
+		if d.Error == "" {
+			sent++
+		}
+	}
+	return fmt.Sprintf("%d/%d delivered", sent, len(deliveries))
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
 	}
+	return s
 }
@@ -1,54 +1,175 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/fetch"
+	"github.com/dev-dhg/yaocc/pkg/httpcache"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 )
 
+const defaultMaxBodyBytes = 5 * 1024 * 1024
+
+// multiFlag collects a repeatable string flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ", ") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
 func runFetch(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: yaocc fetch <url>")
+	fetchCmd := flag.NewFlagSet("fetch", flag.ExitOnError)
+	raw := fetchCmd.Bool("raw", false, "Print the raw response body instead of extracted Markdown")
+	selector := fetchCmd.String("selector", "", "CSS selector of the element(s) to extract (e.g. \"article\", \"div.content\")")
+	offset := fetchCmd.Int("offset", 0, "Character offset to start reading from (for long pages)")
+	maxChars := fetchCmd.Int("max-chars", 0, "Maximum number of characters to print (default from config, or 10000)")
+	method := fetchCmd.String("method", "GET", "HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD)")
+	data := fetchCmd.String("data", "", "Request body. JSON bodies get Content-Type application/json automatically")
+	authName := fetchCmd.String("auth", "", "Name of an auth profile from config (fetch.auth)")
+	timeoutSec := fetchCmd.Int("timeout", 0, "Request timeout in seconds (default from config, or 30)")
+	listAuth := fetchCmd.Bool("list-auth", false, "List the configured auth profile names")
+	noCache := fetchCmd.Bool("no-cache", false, "Bypass the on-disk HTTP cache")
+	var headers, query multiFlag
+	fetchCmd.Var(&headers, "header", "Request header \"Name: value\" (repeatable)")
+	fetchCmd.Var(&query, "query", "Query parameter \"key=value\" (repeatable)")
+
+	if err := fetchCmd.Parse(args); err != nil {
+		fmt.Println("Error parsing flags:", err)
+		os.Exit(1)
+	}
+
+	// Try to load config for storage path, limits and auth profiles
+	var tempDir string
+	budget := fetch.DefaultMaxChars
+	maxBody := int64(defaultMaxBodyBytes)
+	timeout := 30 * time.Second
+	var egress config.EgressConfig
+	var profiles map[string]config.AuthProfile
+	var cacheCfg config.CacheConfig
+	cfg, _, _, err := config.LoadConfig("config.json")
+	if err == nil {
+		if cfg.Storage.TempDir != "" {
+			tempDir = cfg.Storage.TempDir
+			if err := os.MkdirAll(tempDir, 0755); err != nil {
+				fmt.Printf("Warning: could not create temp dir %s: %v\n", tempDir, err)
+				tempDir = "" // Fallback to current dir
+			}
+		}
+		if cfg.Fetch.MaxChars > 0 {
+			budget = cfg.Fetch.MaxChars
+		}
+		if cfg.Fetch.MaxBodyBytes > 0 {
+			maxBody = int64(cfg.Fetch.MaxBodyBytes)
+		}
+		if cfg.Fetch.TimeoutMs > 0 {
+			timeout = time.Duration(cfg.Fetch.TimeoutMs) * time.Millisecond
+		}
+		egress = cfg.Egress
+		profiles = cfg.Fetch.Auth
+		cacheCfg = cfg.Cache
+	}
+	if *maxChars > 0 {
+		budget = *maxChars
+	}
+	if *timeoutSec > 0 {
+		timeout = time.Duration(*timeoutSec) * time.Second
+	}
+
+	if *listAuth {
+		if len(profiles) == 0 {
+			fmt.Println("No auth profiles configured.")
+			return
+		}
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("Auth profiles:")
+		for _, name := range names {
+			fmt.Printf("  - %s (%s) hosts: %s\n", name, profiles[name].Type, strings.Join(profiles[name].Hosts, ", "))
+		}
+		return
+	}
+
+	if fetchCmd.NArg() < 1 {
+		fmt.Println("Usage: yaocc fetch [--method <m>] [--header \"K: V\"] [--query k=v] [--data <body>] [--auth <profile>] [--raw] [--selector <css>] [--offset <n>] [--max-chars <n>] [--no-cache] <url>")
 		os.Exit(1)
 	}
 
-	url := args[0]
+	url := fetchCmd.Arg(0)
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
 		url = "https://" + url
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	fetchReq := fetch.Request{
+		Method:  *method,
+		URL:     url,
+		Headers: map[string]string{},
+		Query:   map[string]string{},
+		Body:    *data,
+		Auth:    *authName,
 	}
-
-	// Try to load config for storage path
-	var tempDir string
-	cfg, _, _, err := config.LoadConfig("config.json")
-	if err == nil && cfg.Storage.TempDir != "" {
-		tempDir = cfg.Storage.TempDir
-		if err := os.MkdirAll(tempDir, 0755); err != nil {
-			fmt.Printf("Warning: could not create temp dir %s: %v\n", tempDir, err)
-			tempDir = "" // Fallback to current dir
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Printf("Invalid header %q (expected \"Name: value\")\n", h)
+			os.Exit(1)
+		}
+		fetchReq.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	for _, q := range query {
+		k, v, ok := strings.Cut(q, "=")
+		if !ok {
+			fmt.Printf("Invalid query parameter %q (expected key=value)\n", q)
+			os.Exit(1)
 		}
+		fetchReq.Query[k] = v
+	}
+
+	req, secretHeaders, err := fetchReq.Build(profiles)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	resp, err := client.Get(url)
+	// Enforce the egress policy (private/link-local ranges are denied by default)
+	policy, err := netguard.NewPolicy(egress)
 	if err != nil {
+		fmt.Printf("Error loading egress policy: %v\n", err)
+		os.Exit(1)
+	}
+	if err := policy.CheckURL(req.URL); err != nil {
 		fmt.Printf("Error fetching URL: %v\n", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
+	client := policy.Client(timeout)
+	client.CheckRedirect = fetch.StripOnRedirect(client.CheckRedirect, secretHeaders)
+
+	// GET responses are cached on disk and revalidated with ETag/Last-Modified,
+	// which also makes paging through a long document with --offset cheap.
+	if !*noCache {
+		if c := httpcache.FromConfig(cacheCfg, tempDir); c != nil {
+			client.Transport = httpcache.NewTransport(c, client.Transport)
+		}
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Error: HTTP Status %d\n", resp.StatusCode)
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Printf("Error fetching URL: %v\n", err)
 		os.Exit(1)
 	}
+	defer resp.Body.Close()
+
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
 
 	contentType := resp.Header.Get("Content-Type")
 
@@ -87,7 +208,7 @@ func runFetch(args []string) {
 	}
 
 	// If it's media, save to a temporary file and tell the Agent to use it
-	if fileType != "" {
+	if fileType != "" && success {
 		filename := fmt.Sprintf("fetched_%s_%d%s", fileType, time.Now().Unix(), ext)
 		var filePath string
 		if tempDir != "" {
@@ -115,12 +236,66 @@ func runFetch(args []string) {
 		return
 	}
 
-	// For text/html/json, just print body
-	body, err := io.ReadAll(resp.Body)
+	// For text/html/json, read a bounded amount of the body
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
 	if err != nil {
 		fmt.Printf("Error reading response body: %v\n", err)
 		os.Exit(1)
 	}
+	bodyTruncated := int64(len(body)) > maxBody
+	if bodyTruncated {
+		body = body[:maxBody]
+	}
+
+	var header, content string
+	if !*raw && success && req.Method == http.MethodGet && isHTML(contentType, body) {
+		article, err := fetch.ExtractReadable(string(body), resp.Request.URL.String(), *selector)
+		if err != nil {
+			fmt.Printf("Error extracting content: %v\n", err)
+			os.Exit(1)
+		}
+		if article.Title != "" {
+			header += fmt.Sprintf("Title: %s\n", article.Title)
+		}
+		header += fmt.Sprintf("URL: %s\n\n", resp.Request.URL.String())
+		content = article.Markdown
+	} else {
+		if *selector != "" && !*raw && success {
+			fmt.Printf("Error: --selector requires an HTML response (got %s)\n", contentType)
+			os.Exit(1)
+		}
+		// Structured result: status line, relevant headers, then the (pretty-printed) body
+		header = fetch.FormatResponseHead(resp)
+		content = fetch.PrettyJSON(string(body))
+	}
+
+	page := fetch.Paginate(content, *offset, budget)
+	if page.Content == "" && *offset > 0 {
+		fmt.Printf("No content at offset %d (total %d characters).\n", *offset, page.Total)
+		return
+	}
+
+	fmt.Print(header)
+	fmt.Println(page.Content)
+	if footer := page.Footer(); footer != "" {
+		fmt.Printf("\n%s\n", footer)
+	}
+	if bodyTruncated {
+		fmt.Printf("\n[Response body exceeded %d bytes and was cut off.]\n", maxBody)
+	}
+	if !success {
+		os.Exit(1)
+	}
+}
 
-	fmt.Println(string(body))
+// isHTML decides whether a response should go through readable extraction.
+func isHTML(contentType string, body []byte) bool {
+	if strings.Contains(contentType, "html") {
+		return true
+	}
+	if contentType != "" && !strings.HasPrefix(contentType, "text/plain") {
+		return false
+	}
+	head := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html")
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/utils"
 )
 
 func runFile(args []string) {
@@ -31,7 +32,7 @@ func runFile(args []string) {
 
 	// Helper to resolve paths using the shared logic
 	resolvePath := func(inputPath string) (string, error) {
-		return resolveSafePath(configDir, inputPath)
+		return utils.ResolveSafePath(configDir, inputPath)
 	}
 
 	cmd := args[0]
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/cron"
+)
+
+// runInbox shows the messages cron jobs delivered to "local" targets and marks them as read.
+func runInbox(args []string) {
+	inboxCmd := flag.NewFlagSet("inbox", flag.ExitOnError)
+	configPath := inboxCmd.String("config", "config.json", "Path to config file")
+	all := inboxCmd.Bool("all", false, "Show read messages too")
+	limit := inboxCmd.Int("limit", 20, "Number of messages to show")
+	session := inboxCmd.String("session", "", "Only show messages for this local target ID")
+	asJSON := inboxCmd.Bool("json", false, "Print the messages as JSON")
+	clear := inboxCmd.Bool("clear", false, "Delete all messages")
+
+	if err := inboxCmd.Parse(args); err != nil {
+		fmt.Println("Usage: yaocc inbox [--all] [--limit <n>] [--session <id>] [--json] [--clear] [--config <path>]")
+		return
+	}
+
+	_, configDir, _, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	inbox := cron.NewInbox(configDir)
+
+	if *clear {
+		if err := inbox.Clear(); err != nil {
+			fmt.Printf("Error clearing inbox: %v\n", err)
+			return
+		}
+		fmt.Println("Inbox cleared.")
+		return
+	}
+
+	msgs, err := inbox.Messages(!*all, 0)
+	if err != nil {
+		fmt.Printf("Error reading inbox: %v\n", err)
+		return
+	}
+	if *session != "" {
+		filtered := msgs[:0]
+		for _, msg := range msgs {
+			if msg.Session == *session {
+				filtered = append(filtered, msg)
+			}
+		}
+		msgs = filtered
+	}
+	if *limit > 0 && len(msgs) > *limit {
+		msgs = msgs[len(msgs)-*limit:]
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(msgs, "", "  ")
+		fmt.Println(string(out))
+	} else if len(msgs) == 0 && *all {
+		fmt.Println("Inbox is empty.")
+	} else if len(msgs) == 0 {
+		fmt.Println("No new messages. Use --all to show read ones.")
+	} else {
+		for _, msg := range msgs {
+			marker := " "
+			if !msg.Read {
+				marker = "*"
+			}
+			fmt.Printf("%s %s  %s\n", marker, msg.Time.Local().Format("2006-01-02 15:04"), msg.Job)
+			for _, line := range strings.Split(strings.TrimSpace(msg.Message), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(msgs) == 0 {
+		return
+	}
+	if err := inbox.MarkRead(msgs); err != nil {
+		fmt.Printf("Warning: could not mark messages as read: %v\n", err)
+	}
+}
@@ -86,6 +86,12 @@ func runInit() {
 	}
 	createFileFromTemplate(p("skills/skills/SKILL.md"), "skills_skill.md")
 
+	// 10. Create research skill
+	if err := os.MkdirAll(p("skills/research"), 0755); err != nil {
+		fmt.Printf("Error creating skills/research directory: %v\n", err)
+	}
+	createFileFromTemplate(p("skills/research/SKILL.md"), "research_skill.md")
+
 	fmt.Println("Project initialized successfully!")
 	fmt.Println("Run 'yaocc-server' to start the server.")
 }
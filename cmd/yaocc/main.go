@@ -12,10 +12,12 @@ func main() {
 		fmt.Println("  init    Initialize a new YAOCC project")
 		fmt.Println("  chat    Send a message to the YAOCC server")
 		fmt.Println("  cron    Manage cron jobs")
+		fmt.Println("  inbox   Show messages cron jobs sent to local targets")
 		fmt.Println("  file    Manage workspace files")
 		fmt.Println("  model   Manage LLM models")
 		fmt.Println("  fetch   Fetch a URL content")
 		fmt.Println("  websearch Search the web")
+		fmt.Println("  research Research a question across several web sources")
 		fmt.Println("  skills  Manage and run skills")
 		fmt.Println("  prompt  Ask a quick question to the LLM")
 		fmt.Println("  exec    Execute shell commands (requires config enable)")
@@ -30,6 +32,8 @@ func main() {
 		runChat(os.Args[2:])
 	case "cron":
 		runCron(os.Args[2:])
+	case "inbox":
+		runInbox(os.Args[2:])
 	case "model":
 		runModel(os.Args[2:])
 	case "file":
@@ -38,6 +42,8 @@ func main() {
 		runFetch(os.Args[2:])
 	case "websearch":
 		runWebSearch(os.Args[2:])
+	case "research":
+		runResearch(os.Args[2:])
 	case "skills":
 		runSkills(os.Args[2:])
 	case "prompt":
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"strings"
@@ -107,7 +108,7 @@ func runPrompt(args []string) {
 	}
 
 	fmt.Printf("Sending prompt to %s...\n", modelID)
-	response, _, err := client.Chat(messages, nil)
+	response, _, err := client.Chat(context.Background(), messages, nil)
 	if err != nil {
 		fmt.Printf("Error during chat: %v\n", err)
 		return
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/httpcache"
+	"github.com/dev-dhg/yaocc/pkg/llm"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+	"github.com/dev-dhg/yaocc/pkg/research"
+)
+
+func runResearch(args []string) {
+	researchCmd := flag.NewFlagSet("research", flag.ExitOnError)
+	modelFlag := researchCmd.String("model", "", "Model used for planning and synthesis (provider/modelID)")
+	maxSources := researchCmd.Int("max-sources", 0, "Number of pages to read and cite (default from config, or 5)")
+	subQueries := researchCmd.Int("sub-queries", 0, "Number of search queries to run (default from config, or 3)")
+	timeoutSec := researchCmd.Int("timeout", 0, "Time budget in seconds (default from config, or 120)")
+	maxTokens := researchCmd.Int("max-tokens", 0, "Approximate token budget for extracted passages (default from config, or 6000)")
+	freshness := researchCmd.String("freshness", "", "Only use sources from the last day, week, month or year")
+	var sites multiFlag
+	researchCmd.Var(&sites, "site", "Only use sources from this domain (repeatable)")
+	jsonOut := researchCmd.Bool("json", false, "Print the full report (sub-queries, passages, notes) as JSON")
+	noCache := researchCmd.Bool("no-cache", false, "Bypass the HTTP and search caches")
+
+	if err := researchCmd.Parse(args); err != nil {
+		fmt.Println("Error parsing flags:", err)
+		os.Exit(1)
+	}
+	if researchCmd.NArg() < 1 {
+		fmt.Println("Usage: yaocc research [flags] \"<question>\"")
+		fmt.Println("Flags:")
+		researchCmd.PrintDefaults()
+		os.Exit(1)
+	}
+	question := strings.Join(researchCmd.Args(), " ")
+
+	cfg, _, _, err := config.LoadConfig("config.json")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := research.Options{
+		MaxSubQueries: cfg.Research.MaxSubQueries,
+		MaxSources:    cfg.Research.MaxSources,
+		MaxTokens:     cfg.Research.MaxTokens,
+		Timeout:       time.Duration(cfg.Research.TimeoutMs) * time.Millisecond,
+	}
+	if *maxSources > 0 {
+		opts.MaxSources = *maxSources
+	}
+	if *subQueries > 0 {
+		opts.MaxSubQueries = *subQueries
+	}
+	if *maxTokens > 0 {
+		opts.MaxTokens = *maxTokens
+	}
+	if *timeoutSec > 0 {
+		opts.Timeout = time.Duration(*timeoutSec) * time.Second
+	}
+	opts.Search.Freshness = *freshness
+	opts.Search.Sites = sites
+	if err := opts.Search.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	modelRef := cfg.Research.Model
+	if *modelFlag != "" {
+		modelRef = *modelFlag
+	}
+	providerCfg, model, err := cfg.ResolveModel(modelRef)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	provider, err := newSearchProvider(cfg, *noCache)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	policy, err := netguard.NewPolicy(cfg.Egress)
+	if err != nil {
+		fmt.Printf("Error loading egress policy: %v\n", err)
+		os.Exit(1)
+	}
+	client := policy.Client(30 * time.Second)
+	if !*noCache {
+		if c := httpcache.FromConfig(cfg.Cache, cfg.Storage.TempDir); c != nil {
+			client.Transport = httpcache.NewTransport(c, client.Transport)
+		}
+	}
+	maxBody := int64(defaultMaxBodyBytes)
+	if cfg.Fetch.MaxBodyBytes > 0 {
+		maxBody = int64(cfg.Fetch.MaxBodyBytes)
+	}
+
+	r := &research.Researcher{
+		LLM:     llm.NewClient(providerCfg, model.Model),
+		Search:  provider,
+		Fetch:   research.NewWebFetcher(client, maxBody),
+		Options: opts,
+	}
+
+	report, err := r.Run(context.Background(), question)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshaling report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(report.Format())
+}
@@ -8,31 +8,6 @@ import (
 	"strings"
 )
 
-// resolveSafePath ensures that the path is within the config directory and is not a sensitive file.
-func resolveSafePath(configDir, inputPath string) (string, error) {
-	// 1. Join with configDir
-	fullPath := filepath.Join(configDir, inputPath)
-
-	// 2. Clean path
-	cleanPath := filepath.Clean(fullPath)
-
-	// 3. Check for path escape
-	absConfigDir, _ := filepath.Abs(configDir)
-	absPath, _ := filepath.Abs(cleanPath)
-
-	if !strings.HasPrefix(absPath, absConfigDir) {
-		return "", fmt.Errorf("access denied: path escapes configuration directory")
-	}
-
-	// 4. Blacklist Check
-	baseName := filepath.Base(absPath)
-	if baseName == "config.json" || baseName == ".env" || baseName == "agent.log" {
-		return "", fmt.Errorf("access denied: cannot access sensitive configuration file '%s'", baseName)
-	}
-
-	return absPath, nil
-}
-
 // executeScript validates and runs a script file.
 func executeScript(targetPath string, args []string) {
 	// Security Check 1: Extension Whitelist
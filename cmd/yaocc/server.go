@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+// postServer posts a JSON body to the local yaocc server, authenticated with serverToken.
+func postServer(cfg *config.Config, path string, body []byte) (*http.Response, error) {
+	port := cfg.Server.Port
+	if port == 0 {
+		port = 8080
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:%d%s", port, path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := serverToken(cfg); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// serverToken returns the bearer token for the server API: $YAOCC_TOKEN, or else server.authToken.
+func serverToken(cfg *config.Config) string {
+	if token := os.Getenv("YAOCC_TOKEN"); token != "" {
+		return token
+	}
+	return cfg.Server.AuthToken
+}
@@ -5,9 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/skills"
+	"github.com/dev-dhg/yaocc/pkg/utils"
 )
 
 func runSkills(args []string) {
@@ -44,18 +45,14 @@ func runSkills(args []string) {
 		scriptPath := args[2]
 
 		// Validation: Reserved names
-		reserved := map[string]bool{
-			"register": true, "unregister": true, "list": true, "help": true,
-			"file": true, "cron": true, "chat": true, "model": true, "init": true, "fetch": true, "websearch": true, "skills": true, "prompt": true,
-		}
-		if reserved[strings.ToLower(name)] {
-			fmt.Printf("Error: '%s' is a reserved command name.\n", name)
+		if err := skills.ValidateName(name); err != nil {
+			fmt.Printf("Error: %v.\n", err)
 			return
 		}
 
 		// Validation: Check if script exists
 		configDir := config.ResolveConfigDir()
-		resolvedPath, err := resolveSafePath(configDir, scriptPath)
+		resolvedPath, err := utils.ResolveSafePath(configDir, scriptPath)
 		if err != nil {
 			fmt.Printf("Error resolving script path: %v\n", err)
 			return
@@ -161,7 +158,7 @@ func runSkills(args []string) {
 			// Generally, skills documentation will be under the same folder named SKILL.md
 			// Try to automatically read it
 			dir := config.ResolveConfigDir()
-			resolvedScript, _ := resolveSafePath(dir, scriptPath)
+			resolvedScript, _ := utils.ResolveSafePath(dir, scriptPath)
 			skillDir := filepath.Dir(resolvedScript)
 			readmePath := filepath.Join(skillDir, "SKILL.md")
 			if content, err := os.ReadFile(readmePath); err == nil {
@@ -198,7 +195,7 @@ func runSkills(args []string) {
 		if scriptPath, ok := cfg.Skills.Registered[name]; ok {
 			// Execute it!
 			// Resolve path again just to be safe at runtime
-			resolvedPath, err := resolveSafePath(configDir, scriptPath)
+			resolvedPath, err := utils.ResolveSafePath(configDir, scriptPath)
 			if err != nil {
 				fmt.Printf("Error resolving skill path: %v\n", err)
 				return
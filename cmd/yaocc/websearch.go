@@ -2,48 +2,68 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/httpcache"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 	"github.com/dev-dhg/yaocc/pkg/websearch"
 )
 
 func runWebSearch(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: yaocc websearch <query>")
-		os.Exit(1)
+	if len(args) > 0 && args[0] == "quota" {
+		runWebSearchQuota(args[1:])
+		return
 	}
 
-	query := strings.Join(args, " ")
+	searchCmd := flag.NewFlagSet("websearch", flag.ExitOnError)
+	noCache := searchCmd.Bool("no-cache", false, "Ignore cached results and query the provider")
+	var opts websearch.SearchOptions
+	searchCmd.StringVar(&opts.Freshness, "freshness", "", "Only results from the last day, week, month or year")
+	searchCmd.StringVar(&opts.Language, "lang", "", "Result language (ISO 639-1, e.g. en)")
+	searchCmd.StringVar(&opts.Region, "region", "", "Result region (ISO 3166-1 alpha-2, e.g. us)")
+	searchCmd.StringVar(&opts.SafeSearch, "safesearch", "", "Safe search level: off, moderate or strict")
+	searchCmd.StringVar(&opts.Category, "category", "", "Result category: web, news, images or videos")
+	var sites, excludeSites multiFlag
+	searchCmd.Var(&sites, "site", "Only return results from this domain (repeatable)")
+	searchCmd.Var(&excludeSites, "exclude-site", "Never return results from this domain (repeatable)")
+	if err := searchCmd.Parse(args); err != nil {
+		fmt.Println("Error parsing flags:", err)
+		os.Exit(1)
+	}
 
-	cfg, _, _, err := config.LoadConfig("config.json")
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
+	if searchCmd.NArg() < 1 {
+		fmt.Println("Usage: yaocc websearch [--freshness day|week|month|year] [--site <domain>] [--exclude-site <domain>] [--lang <code>] [--region <code>] [--safesearch off|moderate|strict] [--category web|news|images|videos] [--no-cache] <query>")
+		fmt.Println("       yaocc websearch quota [--json]")
 		os.Exit(1)
 	}
 
-	if cfg.WebSearch.Provider == "" {
-		fmt.Println("Error: No websearch provider selected in config.")
+	opts.Sites = sites
+	opts.ExcludeSites = excludeSites
+	if err := opts.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	providerCfg, ok := cfg.WebSearch.Providers[cfg.WebSearch.Provider]
-	if !ok {
-		fmt.Printf("Error: Websearch provider '%s' not found in config.\n", cfg.WebSearch.Provider)
+	query := strings.Join(searchCmd.Args(), " ")
+
+	cfg, _, _, err := config.LoadConfig("config.json")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Initialize provider
-	// We need tempDir from config
-	provider, err := websearch.NewProvider(cfg.WebSearch.Provider, providerCfg, cfg.WebSearch.Providers, cfg.Storage.TempDir)
+	provider, err := newSearchProvider(cfg, *noCache)
 	if err != nil {
-		fmt.Printf("Error initializing websearch provider: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	results, err := provider.Search(query)
+	results, err := provider.Search(query, opts)
 	if err != nil {
 		fmt.Printf("Error performing search: %v\n", err)
 		os.Exit(1)
@@ -57,3 +77,102 @@ func runWebSearch(args []string) {
 
 	fmt.Println(string(jsonOutput))
 }
+
+// newSearchProvider builds the configured web search provider behind the egress policy
+// and, unless noCache is set, the result cache.
+func newSearchProvider(cfg *config.Config, noCache bool) (websearch.Provider, error) {
+	if cfg.WebSearch.Provider == "" {
+		return nil, fmt.Errorf("no websearch provider selected in config")
+	}
+
+	providerCfg, ok := cfg.WebSearch.Providers[cfg.WebSearch.Provider]
+	if !ok {
+		return nil, fmt.Errorf("websearch provider '%s' not found in config", cfg.WebSearch.Provider)
+	}
+
+	policy, err := netguard.NewPolicy(cfg.Egress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load egress policy: %w", err)
+	}
+
+	provider, err := websearch.NewProvider(cfg.WebSearch.Provider, providerCfg, cfg.WebSearch.Providers, cfg.Storage.TempDir, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize websearch provider: %w", err)
+	}
+
+	if !noCache {
+		provider = websearch.WithCache(provider, cfg.WebSearch.Provider, httpcache.FromConfig(cfg.Cache, cfg.Storage.TempDir), cfg.Cache)
+	}
+	return provider, nil
+}
+
+// runWebSearchQuota prints the remaining request budget of every configured provider.
+func runWebSearchQuota(args []string) {
+	quotaCmd := flag.NewFlagSet("websearch quota", flag.ExitOnError)
+	asJSON := quotaCmd.Bool("json", false, "Print the status as JSON")
+	if err := quotaCmd.Parse(args); err != nil {
+		fmt.Println("Error parsing flags:", err)
+		os.Exit(1)
+	}
+
+	cfg, _, _, err := config.LoadConfig("config.json")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(cfg.WebSearch.Providers))
+	for name := range cfg.WebSearch.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manager := websearch.NewQuotaManager(cfg.Storage.TempDir)
+	statuses := make([]websearch.QuotaStatus, 0, len(names))
+	for _, name := range names {
+		st, err := manager.Status(name, websearch.EffectiveQuota(cfg.WebSearch.Providers[name]))
+		if err != nil {
+			fmt.Printf("Error reading quota: %v\n", err)
+			os.Exit(1)
+		}
+		statuses = append(statuses, st)
+	}
+
+	if *asJSON {
+		jsonOutput, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshaling status: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(jsonOutput))
+		return
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No websearch providers configured.")
+		return
+	}
+	for _, st := range statuses {
+		marker := ""
+		if st.Provider == cfg.WebSearch.Provider {
+			marker = " (active)"
+		}
+		fmt.Printf("%s%s\n", st.Provider, marker)
+		if st.Limits.IsZero() {
+			fmt.Printf("  no limits configured, %d requests today, %d this month\n", st.UsedToday, st.UsedThisMonth)
+			continue
+		}
+		if st.Limits.PerSecond > 0 {
+			fmt.Printf("  rate:  %d/s\n", st.Limits.PerSecond)
+		}
+		fmt.Printf("  today: %s (resets %s)\n", formatBudget(st.UsedToday, st.Limits.PerDay, st.RemainingToday), st.DayResetsAt.Format("2006-01-02 15:04"))
+		fmt.Printf("  month: %s (resets %s)\n", formatBudget(st.UsedThisMonth, st.Limits.PerMonth, st.RemainingMonth), st.MonthResetsAt.Format("2006-01-02"))
+	}
+}
+
+func formatBudget(used, limit, remaining int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d used, unlimited", used)
+	}
+	return fmt.Sprintf("%d/%d used, %d remaining", used, limit, remaining)
+}
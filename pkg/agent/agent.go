@@ -1,9 +1,12 @@
 package agent
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +19,7 @@ import (
 	"github.com/dev-dhg/yaocc/pkg/llm"
 	"github.com/dev-dhg/yaocc/pkg/mcp"
 	"github.com/dev-dhg/yaocc/pkg/messaging"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 	"github.com/dev-dhg/yaocc/pkg/skills"
 )
 
@@ -34,6 +38,9 @@ type Agent struct {
 	configDir  string
 	SummaryLLM *llm.Client
 	MCPServers map[string]*mcp.Client
+	Events     *EventBus // Progress of runs for API clients and status messages; nil disables events
+
+	tracker runTracker // In-flight runs, see Stop and Shutdown
 }
 
 // GetCurrentModel returns the selected model configuration, or nil if not found.
@@ -139,6 +146,7 @@ func NewAgent(cfg *config.Config, configDir string, verbose bool, logFile string
 		LogFile:    logFile,
 		configDir:  configDir,
 		MCPServers: make(map[string]*mcp.Client),
+		Events:     NewEventBus(),
 	}
 
 	// Initialize LLM
@@ -150,13 +158,16 @@ func NewAgent(cfg *config.Config, configDir string, verbose bool, logFile string
 	if cfg.UseNativeToolCalling && len(cfg.MCPServers) > 0 {
 		log.Printf("Initializing %d MCP servers...", len(cfg.MCPServers))
 		for name, mcpCfg := range cfg.MCPServers {
-			client, err := mcp.NewClient(name, mcpCfg.Command, mcpCfg.Args, mcpCfg.Env)
+			client, err := newMCPClient(cfg, name, mcpCfg)
 			if err != nil {
 				log.Printf("Failed to start MCP server '%s': %v", name, err)
 				continue
 			}
 
-			_, err = client.Initialize()
+			if mcpCfg.TimeoutMs > 0 {
+				client.Timeout = time.Duration(mcpCfg.TimeoutMs) * time.Millisecond
+			}
+			_, err = client.Initialize(context.Background())
 			if err != nil {
 				log.Printf("Failed to initialize MCP server '%s': %v", name, err)
 				client.Close()
@@ -171,6 +182,24 @@ func NewAgent(cfg *config.Config, configDir string, verbose bool, logFile string
 	return agent, nil
 }
 
+// newMCPClient starts a stdio MCP server, or connects to an HTTP one through the egress policy.
+// The configured endpoint itself is trusted, like a SearxNG endpoint; redirects are checked.
+func newMCPClient(cfg *config.Config, name string, mcpCfg config.MCPServerConfig) (*mcp.Client, error) {
+	if mcpCfg.URL == "" {
+		return mcp.NewClient(name, mcpCfg.Command, mcpCfg.Args, mcpCfg.Env)
+	}
+	u, err := url.Parse(mcpCfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MCP server URL: %w", err)
+	}
+	policy, err := netguard.NewPolicy(cfg.Egress)
+	if err != nil {
+		return nil, err
+	}
+	policy = policy.Trust(strings.ToLower(u.Hostname()))
+	return mcp.NewHTTPClient(name, mcpCfg.URL, mcpCfg.Headers, policy.Client(0))
+}
+
 func readFileOrDefault(path, defaultContent string) string {
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -298,7 +327,15 @@ func (a *Agent) UpdateConfig(newCfg *config.Config) {
 	log.Println("Agent configuration updated and LLM re-initialized.")
 }
 
-func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input string) (string, error) {
+// Run answers input in sessionID with the full ReAct loop and saves the exchange to the session.
+// The run is aborted when ctx ends or Stop is called for the session.
+func (a *Agent) Run(ctx context.Context, sessionID string, provider messaging.Provider, chatID, input string) (string, error) {
+	ctx, done, err := a.startRun(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
 	// 1. Load History
 	history, err := a.Sessions.LoadHistory(sessionID)
 	if err != nil {
@@ -322,6 +359,87 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 	}
 
 	// 5. Run ReAct Loop
+	response, err := a.react(ctx, sessionID, provider, chatID, messages)
+	if err != nil {
+		if errors.Is(err, errMaxTurns) && a.Config.Session.Summarize {
+			// Pass recent messages? For now just trigger generic update
+			// We need to pass the new messages if we want rolling update to be efficient without re-reading everything?
+			// But existing LoadHistory reads everything anyway.
+			// Let's just trigger it.
+			go a.UpdateSessionSummary(sessionID)
+		}
+		return "", err
+	}
+
+	// Normal response, save to history
+	if err := a.Sessions.Append(sessionID, "assistant", response); err != nil {
+		log.Printf("Error appending assistant message: %v", err)
+	}
+	// Trigger async summarization for single turn response
+	if a.Config.Session.Summarize {
+		go a.UpdateSessionSummary(sessionID)
+	}
+
+	return response, nil
+}
+
+// RunEphemeral runs input through the full ReAct loop, tools included, in a throwaway session:
+// no chat history is loaded or written. If logSession is set, the exchange is appended to that
+// session afterwards so the runs can be reviewed without touching the user's chat.
+func (a *Agent) RunEphemeral(ctx context.Context, provider messaging.Provider, chatID, input, logSession string) (string, error) {
+	ctx, done, err := a.startRun(ctx, logSession)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	messages := []llm.Message{
+		{Role: "system", Content: a.GetSystemPrompt(provider, chatID)},
+		{Role: "user", Content: input},
+	}
+	response, err := a.react(ctx, "", provider, chatID, messages)
+
+	if logSession != "" {
+		if err := a.Sessions.Append(logSession, "user", input); err != nil {
+			log.Printf("Error appending to log session %s: %v", logSession, err)
+		}
+		entry, role := response, "assistant"
+		if err != nil {
+			entry, role = fmt.Sprintf("Run failed: %v", err), "system"
+		}
+		if err := a.Sessions.Append(logSession, role, entry); err != nil {
+			log.Printf("Error appending to log session %s: %v", logSession, err)
+		}
+	}
+	return response, err
+}
+
+// RunConversation runs input through the full ReAct loop with a history supplied by the caller
+// instead of a stored session, e.g. the messages an OpenAI-compatible client sends with every
+// request. Nothing is written to the session history. extraSystem is appended to the system prompt.
+func (a *Agent) RunConversation(ctx context.Context, extraSystem string, history []llm.Message, input string) (string, error) {
+	ctx, done, err := a.startRun(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	sysPrompt := a.GetSystemPrompt(nil, "")
+	if extraSystem != "" {
+		sysPrompt += "\n\n" + extraSystem
+	}
+	messages := []llm.Message{{Role: "system", Content: sysPrompt}}
+	messages = append(messages, history...)
+	messages = append(messages, llm.Message{Role: "user", Content: input})
+	return a.react(ctx, "", nil, "", messages)
+}
+
+var errMaxTurns = errors.New("max turns reached")
+
+// react runs the ReAct loop: it calls the LLM, executes the tools it asks for and feeds the
+// results back until the LLM answers without tools. It returns that final response, or the
+// reason ctx ended (see stopError) as soon as the LLM request or tool in progress is aborted.
+func (a *Agent) react(ctx context.Context, sessionID string, provider messaging.Provider, chatID string, messages []llm.Message) (string, error) {
 	// Determine MaxTurns
 	maxTurns := 5 // Default
 	if a.Config.MaxTurns > 0 {
@@ -336,6 +454,13 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 	}
 
 	for i := 0; i < maxTurns; i++ {
+		if ctx.Err() != nil {
+			err := stopError(ctx)
+			a.emit(Event{Type: EventError, Session: sessionID, Turn: i + 1, Content: err.Error()})
+			return "", err
+		}
+		a.emit(Event{Type: EventTurnStart, Session: sessionID, Turn: i + 1})
+
 		// LOGGING
 		if a.Verbose {
 			promptContent := fmt.Sprintf("=== REQUEST (Turn %d) ===\n%v\n=========================\n", i+1, messages)
@@ -354,16 +479,26 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 		var toolCalls []llm.ToolCall
 		var err error
 
+		a.emit(Event{Type: EventLLMRequest, Session: sessionID, Turn: i + 1, Messages: len(messages)})
+		llmStart := time.Now()
 		if a.IsNativeToolCallingEnabled() {
 			tools := a.GetTools()
-			response, toolCalls, err = a.LLM.Chat(messages, tools)
+			response, toolCalls, err = a.LLM.Chat(ctx, messages, tools)
 		} else {
-			response, toolCalls, err = a.LLM.Chat(messages, nil)
+			response, toolCalls, err = a.LLM.Chat(ctx, messages, nil)
 		}
 
 		if err != nil {
-			return "", fmt.Errorf("LLM error: %w", err)
+			if ctx.Err() != nil {
+				err = stopError(ctx)
+			} else {
+				err = fmt.Errorf("LLM error: %w", err)
+			}
+			a.emit(Event{Type: EventError, Session: sessionID, Turn: i + 1, Content: err.Error()})
+			return "", err
 		}
+		a.emit(Event{Type: EventLLMResponse, Session: sessionID, Turn: i + 1, ToolCalls: len(toolCalls),
+			Content: truncateEvent(response), DurationMs: time.Since(llmStart).Milliseconds()})
 
 		// LOGGING RESPONSE
 		if a.Verbose {
@@ -389,7 +524,13 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 
 			// Process each tool call
 			for _, tc := range toolCalls {
+				if ctx.Err() != nil {
+					break // The next turn reports why
+				}
 				toolResult := ""
+				toolStart := time.Now()
+				a.emit(Event{Type: EventToolStart, Session: sessionID, Turn: i + 1, Tool: tc.Function.Name, ToolCallID: tc.ID,
+					Status: toolStatus(tc.Function.Name, tc.Function.Arguments), Args: truncateEvent(tc.Function.Arguments)})
 
 				// Route local yaocc skills
 				if strings.HasPrefix(tc.Function.Name, "yaocc_") {
@@ -420,13 +561,13 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 
 						if tc.Function.Name == "yaocc_exec" {
 							if cmd, ok := rawArgs["command"].(string); ok {
-								toolResult, _ = executeCommand(cmd)
+								toolResult, _ = executeCommand(ctx, cmd)
 							}
 						} else if tc.Function.Name == "yaocc_skills_run" {
 							name, _ := rawArgs["name"].(string)
 							args, _ := rawArgs["args"].(string)
 							cmd := fmt.Sprintf("yaocc %s %s", name, args)
-							toolResult, _ = executeCommand(cmd)
+							toolResult, _ = executeCommand(ctx, cmd)
 						} else if strings.HasSuffix(tc.Function.Name, "_usage") {
 							// Dedicated Usage Tool interception
 							baseName := strings.TrimSuffix(tc.Function.Name, "_usage")
@@ -447,7 +588,7 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 							// Normal explicitly-typed or generic fallback skill
 							// Try to build structured bash commands from explicitly mapped tool parameter keys inside tools.go
 							if builtCmd, err := BuildBuiltinCommandArgs(tc.Function.Name, rawArgs); err == nil {
-								cmd := exec.Command(resolveCLIPath(), builtCmd...)
+								cmd := commandContext(ctx, resolveCLIPath(), builtCmd...)
 								out, err := cmd.CombinedOutput()
 								toolResult = string(out)
 								if err != nil && toolResult == "" {
@@ -471,7 +612,7 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 									args = a
 								}
 								cmd := fmt.Sprintf("yaocc %s %s", skillName, args)
-								toolResult, _ = executeCommand(cmd)
+								toolResult, _ = executeCommand(ctx, cmd)
 							}
 						}
 					} else {
@@ -487,7 +628,7 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 						if client, ok := a.MCPServers[serverName]; ok {
 							var args interface{}
 							json.Unmarshal([]byte(tc.Function.Arguments), &args)
-							res, err := client.CallTool(toolName, args)
+							res, err := client.CallTool(ctx, toolName, args)
 							if err != nil {
 								toolResult = fmt.Sprintf("Error returning tool call %s: %v", toolName, err)
 							} else {
@@ -525,6 +666,9 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 					}
 				}
 
+				a.emit(Event{Type: EventToolFinish, Session: sessionID, Turn: i + 1, Tool: tc.Function.Name, ToolCallID: tc.ID,
+					Result: truncateEvent(toolResult), DurationMs: time.Since(toolStart).Milliseconds()})
+
 				// Append tool response
 				messages = append(messages, llm.Message{
 					Role:       "tool",
@@ -541,42 +685,40 @@ func (a *Agent) Run(sessionID string, provider messaging.Provider, chatID, input
 			commands = parseCommands(response)
 			if len(commands) > 0 {
 				messages = append(messages, llm.Message{Role: "assistant", Content: response})
-				toolOutput := a.HandleCommands(sessionID, provider, chatID, commands)
+				toolStart := time.Now()
+				a.emit(Event{Type: EventToolStart, Session: sessionID, Turn: i + 1, Tool: "commands",
+					Status: toolStatus("commands", ""), Args: truncateEvent(strings.Join(commands, "\n"))})
+				toolOutput := a.HandleCommands(ctx, sessionID, provider, chatID, commands)
+				a.emit(Event{Type: EventToolFinish, Session: sessionID, Turn: i + 1, Tool: "commands",
+					Result: truncateEvent(toolOutput), DurationMs: time.Since(toolStart).Milliseconds()})
 				messages = append(messages, llm.Message{Role: "user", Content: toolOutput})
 				continue
 			}
 		}
 
 		// If no tools were called in either flow, this is the final final response.
-		// Normal response, save to history
-		if err := a.Sessions.Append(sessionID, "assistant", response); err != nil {
-			log.Printf("Error appending assistant message: %v", err)
-		}
-		// Trigger async summarization for single turn response
-		if a.Config.Session.Summarize {
-			go a.UpdateSessionSummary(sessionID)
-		}
-
+		a.emit(Event{Type: EventAnswer, Session: sessionID, Turn: i + 1, Content: response})
 		return response, nil
 	}
 
-	// Trigger async summarization
-	if a.Config.Session.Summarize {
-		// Pass recent messages? For now just trigger generic update
-		// We need to pass the new messages if we want rolling update to be efficient without re-reading everything?
-		// But existing LoadHistory reads everything anyway.
-		// Let's just trigger it.
-		go a.UpdateSessionSummary(sessionID)
+	err := errMaxTurns
+	if ctx.Err() != nil {
+		err = stopError(ctx) // Stopped during the last turn's tools
 	}
-
-	return "", fmt.Errorf("max turns reached")
+	a.emit(Event{Type: EventError, Session: sessionID, Turn: maxTurns, Content: err.Error()})
+	return "", err
 }
 
-func (a *Agent) RunTask(sessionID, prompt, contextMsg string) (string, error) {
+func (a *Agent) RunTask(ctx context.Context, sessionID, prompt, contextMsg string) (string, error) {
 	// If sessionID is empty, try to use a default
 	if sessionID == "" {
 		sessionID = "general"
 	}
+	ctx, done, err := a.startRun(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	defer done()
 
 	// Construct System Prompt
 	// We do NOT load history for tasks to keep context clean and focused.
@@ -623,7 +765,7 @@ func (a *Agent) RunTask(sessionID, prompt, contextMsg string) (string, error) {
 	}
 
 	// Call LLM
-	response, _, err := a.LLM.Chat(messages, nil)
+	response, _, err := a.LLM.Chat(ctx, messages, nil)
 	if err != nil {
 		log.Printf("RunTask: LLM error: %v", err)
 		return "", err
@@ -764,7 +906,7 @@ func (a *Agent) ConfigDir() string {
 	return a.configDir // access private field if added, currently passed in NewAgent but not stored?
 }
 
-func (a *Agent) HandleCommands(sessionID string, provider messaging.Provider, chatID string, commands []string) string {
+func (a *Agent) HandleCommands(ctx context.Context, sessionID string, provider messaging.Provider, chatID string, commands []string) string {
 	// Context is now passed explicitly
 	currentProvider := "unknown"
 	if provider != nil {
@@ -780,7 +922,11 @@ func (a *Agent) HandleCommands(sessionID string, provider messaging.Provider, ch
 		cmd = strings.ReplaceAll(cmd, "CURRENT_SESSION_ID", currentID)
 
 		log.Printf("Executing command: %s", cmd)
-		out, err := executeCommand(cmd)
+		if ctx.Err() != nil {
+			outputSb.WriteString(fmt.Sprintf("Command: %s\nSkipped: %v\n", cmd, stopError(ctx)))
+			continue
+		}
+		out, err := executeCommand(ctx, cmd)
 		outputSb.WriteString(fmt.Sprintf("Command: %s\nOutput:\n%s\n", cmd, out))
 		if err != nil {
 			outputSb.WriteString(fmt.Sprintf("Error: %v\n", err))
@@ -820,7 +966,7 @@ func resolveCLIPath() string {
 	return "yaocc"
 }
 
-func executeCommand(cmdStr string) (string, error) {
+func executeCommand(ctx context.Context, cmdStr string) (string, error) {
 	// split command and args
 	// accurate splitting handles quotes? for now simple split
 	// actually for bash commands, we should run them through bash/sh
@@ -833,15 +979,26 @@ func executeCommand(cmdStr string) (string, error) {
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/c", cmdStr)
+		cmd = commandContext(ctx, "cmd", "/c", cmdStr)
 	} else {
-		cmd = exec.Command("sh", "-c", cmdStr)
+		cmd = commandContext(ctx, "sh", "-c", cmdStr)
 	}
 
 	out, err := cmd.CombinedOutput()
 	return string(out), err
 }
 
+// commandWaitDelay is how long a killed command's children may keep its output open.
+const commandWaitDelay = 5 * time.Second
+
+// commandContext is exec.CommandContext for tools: the process is killed when ctx ends, and the
+// output is not waited for much longer, even if a child process still holds it open.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
 // GetTools maps active skills and registered MCP tools into the LLM Tool schema.
 func (a *Agent) GetTools() []llm.Tool {
 	var tools []llm.Tool
@@ -924,7 +1081,7 @@ func (a *Agent) GetTools() []llm.Tool {
 	// 3. Aggregate Tools from MCP Servers
 	if a.IsNativeToolCallingEnabled() && len(a.MCPServers) > 0 {
 		for srvName, client := range a.MCPServers {
-			mcpTools, err := client.GetTools()
+			mcpTools, err := client.GetTools(context.Background())
 			if err != nil {
 				log.Printf("Failed to get tools from MCP server %s: %v", srvName, err)
 				continue
@@ -1029,7 +1186,7 @@ func (a *Agent) UpdateSessionSummary(sessionID string) {
 		{Role: "user", Content: prompt},
 	}
 
-	newSummary, _, err := a.SummaryLLM.Chat(summaryMsg, nil)
+	newSummary, _, err := a.SummaryLLM.Chat(context.Background(), summaryMsg, nil)
 	if err != nil {
 		log.Printf("Failed to generate summary: %v", err)
 		return
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types emitted while the agent works on a message.
+const (
+	EventTurnStart   = "turn_start"   // A ReAct turn begins
+	EventLLMRequest  = "llm_request"  // Messages are sent to the LLM
+	EventLLMResponse = "llm_response" // The LLM answered, possibly with tool calls
+	EventToolStart   = "tool_start"   // A tool is about to run
+	EventToolFinish  = "tool_finish"  // A tool finished; Result is truncated
+	EventAnswer      = "answer"       // The final answer
+	EventError       = "error"        // The run failed
+)
+
+// maxEventText is how much of tool arguments, results and LLM responses an event carries.
+const maxEventText = 1000
+
+// Event describes a step of an agent run. Session is empty for ephemeral runs.
+type Event struct {
+	Type       string    `json:"type"`
+	Session    string    `json:"session,omitempty"`
+	Time       time.Time `json:"time"`
+	Turn       int       `json:"turn,omitempty"`
+	Messages   int       `json:"messages,omitempty"`   // llm_request: number of messages sent
+	ToolCalls  int       `json:"toolCalls,omitempty"`  // llm_response: number of tools requested
+	Tool       string    `json:"tool,omitempty"`       // Tool name, e.g. yaocc_websearch
+	ToolCallID string    `json:"toolCallId,omitempty"` // Links tool_start and tool_finish
+	Status     string    `json:"status,omitempty"`     // Human-readable tool description, e.g. `Searching the web for "go 1.23"`
+	Args       string    `json:"args,omitempty"`       // Tool arguments as JSON
+	Result     string    `json:"result,omitempty"`     // Tool output
+	Content    string    `json:"content,omitempty"`    // LLM response, answer or error message
+	DurationMs int64     `json:"durationMs,omitempty"` // llm_response and tool_finish
+}
+
+// EventBus fans agent events out to subscribers. Publishing never blocks: a subscriber that
+// falls behind loses events instead of slowing the agent down.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]string // Channel -> session filter, "" for all sessions
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]string)}
+}
+
+// Subscribe returns the events of session, or of all sessions if it is empty, and a function
+// that ends the subscription and closes the channel.
+func (b *EventBus) Subscribe(session string) (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	b.mu.Lock()
+	b.subs[ch] = session
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *EventBus) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, session := range b.subs {
+		if session != "" && session != ev.Session {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// emit publishes ev on the agent's bus, if it has one.
+func (a *Agent) emit(ev Event) {
+	if a.Events == nil {
+		return
+	}
+	ev.Time = time.Now()
+	a.Events.Publish(ev)
+}
+
+// truncateEvent shortens s to maxEventText bytes, noting the full length.
+func truncateEvent(s string) string {
+	if len(s) <= maxEventText {
+		return s
+	}
+	return fmt.Sprintf("%s… (%d bytes)", cutUTF8(s, maxEventText), len(s))
+}
+
+// cutUTF8 returns at most n bytes of s without splitting a UTF-8 character.
+func cutUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// toolStatus describes a tool call for people watching the agent, e.g. "Searching the web for ...".
+func toolStatus(name, arguments string) string {
+	var args map[string]interface{}
+	json.Unmarshal([]byte(arguments), &args)
+	arg := func(key string) string {
+		s, _ := args[key].(string)
+		if len(s) > 80 {
+			s = cutUTF8(s, 80) + "…"
+		}
+		return s
+	}
+	quoted := func(prefix, key string) string {
+		if v := arg(key); v != "" {
+			return fmt.Sprintf("%s %q", prefix, v)
+		}
+		return prefix
+	}
+
+	switch {
+	case name == "commands":
+		return "Running commands"
+	case name == "yaocc_websearch":
+		return quoted("Searching the web for", "query")
+	case name == "yaocc_fetch":
+		if url := arg("url"); url != "" {
+			return "Reading " + url
+		}
+		return "Fetching a page"
+	case name == "yaocc_research":
+		return quoted("Researching", "question")
+	case name == "yaocc_exec":
+		return "Running a command"
+	case strings.HasSuffix(name, "_usage"):
+		return fmt.Sprintf("Reading the %s manual", strings.TrimSuffix(strings.TrimPrefix(name, "yaocc_"), "_usage"))
+	case strings.HasPrefix(name, "yaocc_file_"):
+		if path := arg("path"); path != "" {
+			return fmt.Sprintf("Working on %s (%s)", path, name[strings.LastIndex(name, "_")+1:])
+		}
+		return "Working with files"
+	case strings.HasPrefix(name, "yaocc_cron"):
+		return "Managing scheduled jobs"
+	case strings.HasPrefix(name, "mcp_"):
+		if server, tool, ok := strings.Cut(strings.TrimPrefix(name, "mcp_"), "_"); ok {
+			return fmt.Sprintf("Using %s on %s", tool, server)
+		}
+	}
+	return "Using " + strings.TrimPrefix(name, "yaocc_")
+}
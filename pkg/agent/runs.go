@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrStopped is the cause of runs stopped with Stop, e.g. by /stop in a chat.
+	ErrStopped = errors.New("stopped by user")
+	// ErrShuttingDown is returned for runs refused or cancelled because the agent is shutting down.
+	ErrShuttingDown = errors.New("agent is shutting down")
+)
+
+// runTracker keeps the cancel functions of in-flight runs by session, so a user can stop a
+// turn and a shutdown can wait for them to finish.
+type runTracker struct {
+	mu       sync.Mutex
+	runs     map[string]map[*activeRun]struct{}
+	inflight sync.WaitGroup
+	closing  bool
+}
+
+type activeRun struct {
+	cancel context.CancelCauseFunc
+}
+
+// startRun registers a run of sessionID. The returned context is cancelled by Stop or a
+// shutdown; done must be called when the run ends.
+func (a *Agent) startRun(ctx context.Context, sessionID string) (context.Context, func(), error) {
+	t := &a.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return nil, nil, ErrShuttingDown
+	}
+	if t.runs == nil {
+		t.runs = make(map[string]map[*activeRun]struct{})
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	run := &activeRun{cancel: cancel}
+	if t.runs[sessionID] == nil {
+		t.runs[sessionID] = make(map[*activeRun]struct{})
+	}
+	t.runs[sessionID][run] = struct{}{}
+	t.inflight.Add(1)
+
+	done := func() {
+		t.mu.Lock()
+		delete(t.runs[sessionID], run)
+		if len(t.runs[sessionID]) == 0 {
+			delete(t.runs, sessionID)
+		}
+		t.mu.Unlock()
+		cancel(nil)
+		t.inflight.Done()
+	}
+	return ctx, done, nil
+}
+
+// Stop cancels the runs in progress for sessionID and returns how many there were. The LLM
+// request or tool they are waiting on is aborted and they end with ErrStopped.
+func (a *Agent) Stop(sessionID string) int {
+	if sessionID == "" {
+		return 0
+	}
+	t := &a.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for run := range t.runs[sessionID] {
+		run.cancel(ErrStopped)
+	}
+	return len(t.runs[sessionID])
+}
+
+// Running reports whether a run of sessionID is in progress.
+func (a *Agent) Running(sessionID string) bool {
+	t := &a.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.runs[sessionID]) > 0
+}
+
+// Shutdown refuses new runs and waits for the ones in progress to finish. When ctx ends first,
+// the remaining runs are cancelled with ErrShuttingDown and ctx's error is returned.
+func (a *Agent) Shutdown(ctx context.Context) error {
+	t := &a.tracker
+	t.mu.Lock()
+	t.closing = true
+	t.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		t.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	for _, runs := range t.runs {
+		for run := range runs {
+			run.cancel(ErrShuttingDown)
+		}
+	}
+	t.mu.Unlock()
+	return ctx.Err()
+}
+
+// stopError explains why ctx ended: ErrStopped or ErrShuttingDown as is, anything else (such
+// as a deadline) wrapped.
+func stopError(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrStopped) || errors.Is(cause, ErrShuttingDown) {
+		return cause
+	}
+	return fmt.Errorf("run cancelled: %w", cause)
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/dev-dhg/yaocc/pkg/llm"
@@ -49,24 +50,113 @@ func GetBuiltinToolSchemas(skillName, description string) []llm.Tool {
 		}
 	}
 
+	enumProp := func(desc string, values ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        "string",
+			"enum":        values,
+			"description": desc,
+		}
+	}
+
 	switch skillName {
 	case "cron-manager", "cron_manager", "cron":
 		addTool("list", "List all configured cron jobs", nil, nil)
-		addTool("add", "Add a new cron job. ALWAYS use this to schedule events, recurring tasks, and future actions.", map[string]interface{}{
-			"name":            prop("string", "Name of the cron job"),
-			"schedule":        prop("string", "Cron schedule expression, e.g. '0 9 * * *'"),
-			"prompt":          prop("string", "The prompt to send to the LLM (for prompt-type jobs)"),
-			"script":          prop("string", "Path to a script to execute (for script-type jobs). e.g 'scripts/ping_server.js 192.168.1.1'. You can create this script first using the file-manager skill if it doesn't exist. see cron_manager usage for more info"),
-			"use_history":     prop("boolean", "Whether to use target session history state"),
-			"target_provider": prop("string", "The messaging provider to target (e.g. telegram). Use 'CURRENT_PROVIDER' as a placeholder to target the current session's provider."),
-			"target_id":       prop("string", "The ID of the target chat/session. Use 'CURRENT_SESSION_ID' as a placeholder to target the current session's ID."),
-		}, []string{"name", "schedule"})
+		addTool("add", "Add a new cron job. ALWAYS use this to schedule events, recurring tasks, reminders and future actions. Give exactly one of schedule, at, when, on_file, on_webhook or on_message.", map[string]interface{}{
+			"name":             prop("string", "Name of the cron job"),
+			"schedule":         prop("string", "Cron schedule expression, e.g. '0 9 * * *'"),
+			"at":               prop("string", "Run once at this time, then remove the job: an RFC3339 timestamp or a duration from now like '2h' or '30m'"),
+			"when":             prop("string", "Schedule in plain English, recurring ('every weekday at 8:30', 'every 15 minutes') or one-off ('tomorrow at 9', 'in 2 hours', 'friday 6pm'). One-off jobs are removed after they run."),
+			"on_file":          prop("string", "Run when files under this workspace path change instead of on time, e.g. 'inbox' (the changed files are passed to the prompt)"),
+			"file_pattern":     prop("string", "Only react to file names matching this glob, e.g. '*.pdf' (with on_file)"),
+			"on_webhook":       prop("boolean", "Run when the webhook POST /hooks/<name> is called instead of on time; the request body is passed to the prompt"),
+			"webhook_secret":   prop("string", "Secret webhook callers must send (with on_webhook)"),
+			"on_message":       prop("string", "Run when an incoming chat message matches this regular expression instead of on time, e.g. '(?i)^expense:'"),
+			"prompt":           prop("string", "The prompt to send to the LLM (for prompt-type jobs). Triggered jobs can use {{.payload}}, {{.message}} or {{.files}} to place the event"),
+			"script":           prop("string", "Path to a script to execute (for script-type jobs). e.g 'scripts/ping_server.js 192.168.1.1'. You can create this script first using the file-manager skill if it doesn't exist. see cron_manager usage for more info"),
+			"use_history":      prop("boolean", "Whether to use target session history state"),
+			"use_tools":        prop("boolean", "Let a stateless prompt use tools (web search, fetch, skills) in an ephemeral session that does not touch the chat history. Use for prompts like 'check the weather and tell me'."),
+			"log_session":      prop("string", "Session ID to append use_tools runs to for later review (optional)"),
+			"target_provider":  prop("string", "Where to send the result: a messaging provider (e.g. telegram), 'local' (the inbox), 'file', 'webhook' or 'email'. Use 'CURRENT_PROVIDER' as a placeholder to target the current session's provider."),
+			"target_id":        prop("string", "The ID of the target chat/session, a file path like 'memory/{date}.md', a URL or email addresses. Use 'CURRENT_SESSION_ID' as a placeholder to target the current session's ID."),
+			"target_secret":    prop("string", "HMAC key to sign requests to a webhook target with (optional)"),
+			"timeout":          prop("string", "Maximum run time as a duration, e.g. '30s' or '5m' (default: no limit)"),
+			"retries":          prop("integer", "How often to retry a failed LLM call or message delivery (default 0)"),
+			"concurrency":      enumProp("What to do when the previous run is still active (default allow)", "allow", "skip", "queue"),
+			"catch_up":         enumProp("What to do with runs missed while the server was down: none (default), once (run once) or all (replay every missed run)", "none", "once", "all"),
+			"catch_up_window":  prop("string", "How far back missed runs are caught up, e.g. '6h' (default 24h)"),
+			"notify_on":        enumProp("When to send the result: always (default), failure (script exit code != 0), change (output differs from the last run, a diff is sent), match (output matches notify_pattern) or judge (the LLM checks notify_condition)", "always", "failure", "change", "match", "judge"),
+			"notify_pattern":   prop("string", "Regular expression for notify_on=match, e.g. 'ERROR|CRITICAL'"),
+			"notify_condition": prop("string", "Condition for notify_on=judge, e.g. 'any disk is more than 90% full'"),
+			"notify_resolved":  prop("boolean", "Also send a message when the failure, match or judged condition clears"),
+			"include": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Make this a digest: names or IDs of other jobs whose outputs are combined into one message. The prompt then says how to combine them (optional). Disable the included jobs if they should no longer send on their own.",
+			},
+			"include_scripts": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Scripts whose outputs are added to the digest",
+			},
+		}, []string{"name"})
 		addTool("remove", "Remove an existing cron job", map[string]interface{}{
-			"name": prop("string", "Name of the cron job"),
+			"name": prop("string", "Name or ID of the cron job"),
+		}, []string{"name"})
+		addTool("run", "Force run a specific cron job now", map[string]interface{}{
+			"name": prop("string", "Name or ID of the cron job (obtain via list action)"),
+		}, []string{"name"})
+		addTool("show", "Show the full configuration of a cron job with its next and last run", map[string]interface{}{
+			"name": prop("string", "Name or ID of the cron job"),
+		}, []string{"name"})
+		addTool("edit", "Change fields of an existing cron job. Only the given fields are changed.", map[string]interface{}{
+			"name":             prop("string", "Name or ID of the cron job to edit"),
+			"new_name":         prop("string", "New name for the job"),
+			"schedule":         prop("string", "New cron schedule expression, e.g. '0 9 * * *'"),
+			"at":               prop("string", "Run once at this time instead: an RFC3339 timestamp or a duration from now like '2h'"),
+			"when":             prop("string", "New schedule in plain English, e.g. 'every monday at 9' or 'tomorrow at 18:00'"),
+			"on_file":          prop("string", "Run on file changes under this workspace path instead (replaces the schedule or trigger)"),
+			"file_pattern":     prop("string", "Only react to file names matching this glob (with on_file)"),
+			"on_webhook":       prop("boolean", "Run when the webhook POST /hooks/<name> is called instead (replaces the schedule or trigger)"),
+			"webhook_secret":   prop("string", "Secret webhook callers must send (with on_webhook)"),
+			"on_message":       prop("string", "Run when an incoming chat message matches this regular expression instead (replaces the schedule or trigger)"),
+			"prompt":           prop("string", "New prompt to send to the LLM"),
+			"script":           prop("string", "New script to execute"),
+			"use_history":      prop("boolean", "Whether to use target session history state"),
+			"use_tools":        prop("boolean", "Let a stateless prompt use tools (web search, fetch, skills) in an ephemeral session that does not touch the chat history. Use for prompts like 'check the weather and tell me'."),
+			"log_session":      prop("string", "Session ID to append use_tools runs to for later review (optional)"),
+			"target_provider":  prop("string", "Replace the targets with this messaging provider, or local, file, webhook or email. Use 'CURRENT_PROVIDER' for the current session's provider."),
+			"target_id":        prop("string", "Replace the targets with this chat/session ID, file path, URL or email addresses. Use 'CURRENT_SESSION_ID' for the current session's ID."),
+			"target_secret":    prop("string", "HMAC key for a webhook target"),
+			"timeout":          prop("string", "Maximum run time as a duration, e.g. '30s' or '5m' (default: no limit)"),
+			"retries":          prop("integer", "How often to retry a failed LLM call or message delivery (default 0)"),
+			"concurrency":      enumProp("What to do when the previous run is still active (default allow)", "allow", "skip", "queue"),
+			"catch_up":         enumProp("What to do with runs missed while the server was down: none (default), once (run once) or all (replay every missed run)", "none", "once", "all"),
+			"catch_up_window":  prop("string", "How far back missed runs are caught up, e.g. '6h' (default 24h)"),
+			"notify_on":        enumProp("When to send the result: always (default), failure (script exit code != 0), change (output differs from the last run, a diff is sent), match (output matches notify_pattern) or judge (the LLM checks notify_condition)", "always", "failure", "change", "match", "judge"),
+			"notify_pattern":   prop("string", "Regular expression for notify_on=match, e.g. 'ERROR|CRITICAL'"),
+			"notify_condition": prop("string", "Condition for notify_on=judge, e.g. 'any disk is more than 90% full'"),
+			"notify_resolved":  prop("boolean", "Also send a message when the failure, match or judged condition clears"),
+			"include": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Replace the digest sources with these jobs (names or IDs)",
+			},
+			"include_scripts": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Replace the digest sources with these scripts (together with include)",
+			},
+		}, []string{"name"})
+		addTool("enable", "Enable a disabled cron job so it runs on schedule again", map[string]interface{}{
+			"name": prop("string", "Name or ID of the cron job"),
+		}, []string{"name"})
+		addTool("disable", "Pause a cron job without removing it", map[string]interface{}{
+			"name": prop("string", "Name or ID of the cron job"),
+		}, []string{"name"})
+		addTool("history", "Show recent runs of a cron job: status, duration, script exit code and delivery results, plus the next scheduled run", map[string]interface{}{
+			"name":  prop("string", "Name or ID of the cron job"),
+			"limit": prop("integer", "Number of runs to show (default 10)"),
 		}, []string{"name"})
-		addTool("run", "Force run a specific cron job by its index", map[string]interface{}{
-			"index": prop("integer", "The index of the job to run (obtain via list action)"),
-		}, []string{"index"})
 
 	case "file-manager", "file_manager", "file":
 		addTool("read", "Read content of a file", map[string]interface{}{
@@ -100,14 +190,59 @@ func GetBuiltinToolSchemas(skillName, description string) []llm.Tool {
 
 	case "fetch":
 		addTool("", "", map[string]interface{}{
-			"url": prop("string", "The HTTP/HTTPS URL to fetch."),
+			"url":      prop("string", "The HTTP/HTTPS URL to fetch."),
+			"raw":      prop("boolean", "Return the raw response body instead of the extracted readable Markdown."),
+			"selector": prop("string", "Optional CSS selector to extract only specific elements, e.g. 'article' or 'div.content'."),
+			"offset":   prop("integer", "Character offset to continue reading a long page from. Use the value given in the truncation notice."),
+			"method":   prop("string", "HTTP method: GET (default), POST, PUT, PATCH, DELETE or HEAD."),
+			"headers": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "Request headers as name/value pairs, e.g. {\"Accept\": \"application/json\"}.",
+			},
+			"query": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "Query parameters as key/value pairs.",
+			},
+			"body":     prop("string", "Request body. JSON bodies are sent with Content-Type application/json."),
+			"auth":     prop("string", "Name of a configured auth profile to authenticate with (never put tokens in headers yourself)."),
+			"no_cache": prop("boolean", "Bypass the HTTP cache, e.g. when the page is known to have just changed."),
 		}, []string{"url"})
 
 	case "websearch":
 		addTool("", "", map[string]interface{}{
-			"query": prop("string", "The search query to search the web for."),
+			"query":     prop("string", "The search query to search the web for."),
+			"freshness": enumProp("Only return results published within this period.", "day", "week", "month", "year"),
+			"sites": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Restrict results to these domains, e.g. [\"go.dev\"].",
+			},
+			"exclude_sites": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Exclude results from these domains.",
+			},
+			"language":   prop("string", "Result language as an ISO 639-1 code, e.g. 'en'."),
+			"region":     prop("string", "Result region as an ISO 3166-1 alpha-2 code, e.g. 'us'."),
+			"safesearch": enumProp("Safe search level.", "off", "moderate", "strict"),
+			"category":   enumProp("Kind of results to return (default web).", "web", "news", "images", "videos"),
+			"no_cache":   prop("boolean", "Ignore cached results from recent identical searches."),
 		}, []string{"query"})
 
+	case "research":
+		addTool("", "", map[string]interface{}{
+			"question":    prop("string", "The research question to answer from several web sources."),
+			"max_sources": prop("integer", "Number of pages to read and cite (default 5)."),
+			"freshness":   enumProp("Only use sources published within this period.", "day", "week", "month", "year"),
+			"sites": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Restrict sources to these domains.",
+			},
+		}, []string{"question"})
+
 	case "prompt":
 		addTool("", "", map[string]interface{}{
 			"message": prop("string", "The prompt or message to immediately pass to the LLM statelessly."),
@@ -140,6 +275,76 @@ func GetBuiltinToolSchemas(skillName, description string) []llm.Tool {
 	return tools
 }
 
+// cronPolicyArgs maps the execution policy and notify parameters shared by cron add and edit.
+func cronPolicyArgs(rawArgs map[string]interface{}) []string {
+	var args []string
+	if timeout, ok := rawArgs["timeout"].(string); ok && timeout != "" {
+		args = append(args, "--timeout", timeout)
+	}
+	if retries, ok := rawArgs["retries"].(float64); ok {
+		args = append(args, "--retries", fmt.Sprintf("%d", int(retries)))
+	}
+	if concurrency, ok := rawArgs["concurrency"].(string); ok && concurrency != "" {
+		args = append(args, "--concurrency", concurrency)
+	}
+	if catchUp, ok := rawArgs["catch_up"].(string); ok && catchUp != "" {
+		args = append(args, "--catch-up", catchUp)
+	}
+	if window, ok := rawArgs["catch_up_window"].(string); ok && window != "" {
+		args = append(args, "--catch-up-window", window)
+	}
+	if notifyOn, ok := rawArgs["notify_on"].(string); ok && notifyOn != "" {
+		args = append(args, "--notify-on", notifyOn)
+		if pattern, ok := rawArgs["notify_pattern"].(string); ok && pattern != "" {
+			args = append(args, "--notify-pattern", pattern)
+		}
+		if condition, ok := rawArgs["notify_condition"].(string); ok && condition != "" {
+			args = append(args, "--notify-condition", condition)
+		}
+		if resolved, ok := rawArgs["notify_resolved"].(bool); ok && resolved {
+			args = append(args, "--notify-resolved")
+		}
+	}
+	return args
+}
+
+// cronDigestArgs maps the digest sources shared by cron add and edit.
+func cronDigestArgs(rawArgs map[string]interface{}) []string {
+	var args []string
+	for _, f := range []struct{ key, flag string }{
+		{"include", "--include"},
+		{"include_scripts", "--include-script"},
+	} {
+		if list, ok := rawArgs[f.key].([]interface{}); ok {
+			for _, v := range list {
+				args = append(args, f.flag, fmt.Sprintf("%v", v))
+			}
+		}
+	}
+	return args
+}
+
+// cronTriggerArgs maps the event trigger parameters shared by cron add and edit.
+func cronTriggerArgs(rawArgs map[string]interface{}) []string {
+	var args []string
+	if onFile, ok := rawArgs["on_file"].(string); ok && onFile != "" {
+		args = append(args, "--on-file", onFile)
+		if pattern, ok := rawArgs["file_pattern"].(string); ok && pattern != "" {
+			args = append(args, "--file-pattern", pattern)
+		}
+	}
+	if onWebhook, ok := rawArgs["on_webhook"].(bool); ok && onWebhook {
+		args = append(args, "--on-webhook")
+		if secret, ok := rawArgs["webhook_secret"].(string); ok && secret != "" {
+			args = append(args, "--webhook-secret", secret)
+		}
+	}
+	if onMessage, ok := rawArgs["on_message"].(string); ok && onMessage != "" {
+		args = append(args, "--on-message", onMessage)
+	}
+	return args
+}
+
 // BuildBuiltinCommandArgs converts the newly structured granular tool calls back into CLI strings.
 func BuildBuiltinCommandArgs(toolName string, rawArgs map[string]interface{}) ([]string, error) {
 	baseName := strings.TrimPrefix(toolName, "yaocc_")
@@ -166,6 +371,14 @@ func BuildBuiltinCommandArgs(toolName string, rawArgs map[string]interface{}) ([
 			if schedule, ok := rawArgs["schedule"].(string); ok && schedule != "" {
 				args = append(args, "--schedule", schedule)
 			}
+			if at, ok := rawArgs["at"].(string); ok && at != "" {
+				args = append(args, "--at", at)
+			}
+			if when, ok := rawArgs["when"].(string); ok && when != "" {
+				args = append(args, "--when", when)
+			}
+			args = append(args, cronTriggerArgs(rawArgs)...)
+			args = append(args, cronDigestArgs(rawArgs)...)
 			if prompt, ok := rawArgs["prompt"].(string); ok && prompt != "" {
 				args = append(args, "--prompt", prompt)
 			}
@@ -175,26 +388,75 @@ func BuildBuiltinCommandArgs(toolName string, rawArgs map[string]interface{}) ([
 			if useHistory, ok := rawArgs["use_history"].(bool); ok && useHistory {
 				args = append(args, "--use-history")
 			}
+			if useTools, ok := rawArgs["use_tools"].(bool); ok && useTools {
+				args = append(args, "--use-tools")
+			}
+			if logSession, ok := rawArgs["log_session"].(string); ok && logSession != "" {
+				args = append(args, "--log-session", logSession)
+			}
 			if targetProvider, ok := rawArgs["target_provider"].(string); ok && targetProvider != "" {
 				args = append(args, "--target-provider", targetProvider)
 			}
 			if targetID, ok := rawArgs["target_id"].(string); ok && targetID != "" {
 				args = append(args, "--target-id", targetID)
 			}
-		case "remove":
+			if targetSecret, ok := rawArgs["target_secret"].(string); ok && targetSecret != "" {
+				args = append(args, "--target-secret", targetSecret)
+			}
+			args = append(args, cronPolicyArgs(rawArgs)...)
+		case "remove", "run", "show", "enable", "disable":
 			if name, ok := rawArgs["name"].(string); ok {
 				args = append(args, name)
 			}
-		case "run":
-			indexFloat, ok := rawArgs["index"].(float64)
-			if !ok {
-				if indexInt, ok2 := rawArgs["index"].(int); ok2 {
-					indexFloat = float64(indexInt)
-					ok = true
-				}
+		case "edit":
+			if name, ok := rawArgs["name"].(string); ok {
+				args = append(args, name)
+			}
+			if newName, ok := rawArgs["new_name"].(string); ok && newName != "" {
+				args = append(args, "--name", newName)
+			}
+			if schedule, ok := rawArgs["schedule"].(string); ok && schedule != "" {
+				args = append(args, "--schedule", schedule)
+			}
+			if at, ok := rawArgs["at"].(string); ok && at != "" {
+				args = append(args, "--at", at)
+			}
+			if when, ok := rawArgs["when"].(string); ok && when != "" {
+				args = append(args, "--when", when)
+			}
+			args = append(args, cronTriggerArgs(rawArgs)...)
+			args = append(args, cronDigestArgs(rawArgs)...)
+			if prompt, ok := rawArgs["prompt"].(string); ok {
+				args = append(args, "--prompt", prompt)
+			}
+			if script, ok := rawArgs["script"].(string); ok {
+				args = append(args, "--script", script)
+			}
+			if useHistory, ok := rawArgs["use_history"].(bool); ok {
+				args = append(args, fmt.Sprintf("--use-history=%t", useHistory))
+			}
+			if useTools, ok := rawArgs["use_tools"].(bool); ok {
+				args = append(args, fmt.Sprintf("--use-tools=%t", useTools))
+			}
+			if logSession, ok := rawArgs["log_session"].(string); ok {
+				args = append(args, "--log-session", logSession)
+			}
+			if targetProvider, ok := rawArgs["target_provider"].(string); ok && targetProvider != "" {
+				args = append(args, "--target-provider", targetProvider)
+			}
+			if targetID, ok := rawArgs["target_id"].(string); ok && targetID != "" {
+				args = append(args, "--target-id", targetID)
 			}
-			if ok {
-				args = append(args, fmt.Sprintf("%d", int(indexFloat)))
+			if targetSecret, ok := rawArgs["target_secret"].(string); ok && targetSecret != "" {
+				args = append(args, "--target-secret", targetSecret)
+			}
+			args = append(args, cronPolicyArgs(rawArgs)...)
+		case "history":
+			if limit, ok := rawArgs["limit"].(float64); ok && limit > 0 {
+				args = append(args, "--limit", fmt.Sprintf("%d", int(limit)))
+			}
+			if name, ok := rawArgs["name"].(string); ok {
+				args = append(args, name)
 			}
 		}
 		return args, nil
@@ -228,17 +490,95 @@ func BuildBuiltinCommandArgs(toolName string, rawArgs map[string]interface{}) ([
 		return args, nil
 
 	case baseName == "fetch":
-		if url, ok := rawArgs["url"].(string); ok {
-			return []string{"fetch", url}, nil
+		url, ok := rawArgs["url"].(string)
+		if !ok {
+			return []string{"fetch"}, fmt.Errorf("missing url")
+		}
+		args := []string{"fetch"}
+		if raw, ok := rawArgs["raw"].(bool); ok && raw {
+			args = append(args, "--raw")
+		}
+		if selector, ok := rawArgs["selector"].(string); ok && selector != "" {
+			args = append(args, "--selector", selector)
+		}
+		if offset, ok := rawArgs["offset"].(float64); ok && offset > 0 {
+			args = append(args, "--offset", fmt.Sprintf("%d", int(offset)))
+		}
+		if method, ok := rawArgs["method"].(string); ok && method != "" {
+			args = append(args, "--method", method)
+		}
+		if headers, ok := rawArgs["headers"].(map[string]interface{}); ok {
+			for _, k := range sortedKeys(headers) {
+				args = append(args, "--header", fmt.Sprintf("%s: %v", k, headers[k]))
+			}
+		}
+		if query, ok := rawArgs["query"].(map[string]interface{}); ok {
+			for _, k := range sortedKeys(query) {
+				args = append(args, "--query", fmt.Sprintf("%s=%v", k, query[k]))
+			}
+		}
+		if body, ok := rawArgs["body"].(string); ok && body != "" {
+			args = append(args, "--data", body)
+		}
+		if auth, ok := rawArgs["auth"].(string); ok && auth != "" {
+			args = append(args, "--auth", auth)
+		}
+		if noCache, ok := rawArgs["no_cache"].(bool); ok && noCache {
+			args = append(args, "--no-cache")
 		}
-		return []string{"fetch"}, fmt.Errorf("missing url")
+		return append(args, url), nil
 
 	case baseName == "websearch":
 		if query, ok := rawArgs["query"].(string); ok {
-			return []string{"websearch", query}, nil
+			args := []string{"websearch"}
+			for _, f := range []struct{ key, flag string }{
+				{"freshness", "--freshness"},
+				{"language", "--lang"},
+				{"region", "--region"},
+				{"safesearch", "--safesearch"},
+				{"category", "--category"},
+			} {
+				if v, ok := rawArgs[f.key].(string); ok && v != "" {
+					args = append(args, f.flag, v)
+				}
+			}
+			for _, f := range []struct{ key, flag string }{
+				{"sites", "--site"},
+				{"exclude_sites", "--exclude-site"},
+			} {
+				if list, ok := rawArgs[f.key].([]interface{}); ok {
+					for _, v := range list {
+						args = append(args, f.flag, fmt.Sprintf("%v", v))
+					}
+				}
+			}
+			if noCache, ok := rawArgs["no_cache"].(bool); ok && noCache {
+				args = append(args, "--no-cache")
+			}
+			// "--" keeps queries that start with a dash from being parsed as flags
+			return append(args, "--", query), nil
 		}
 		return []string{"websearch"}, fmt.Errorf("missing query")
 
+	case baseName == "research":
+		question, ok := rawArgs["question"].(string)
+		if !ok || question == "" {
+			return []string{"research"}, fmt.Errorf("missing question")
+		}
+		args := []string{"research"}
+		if n, ok := rawArgs["max_sources"].(float64); ok && n > 0 {
+			args = append(args, "--max-sources", fmt.Sprintf("%d", int(n)))
+		}
+		if freshness, ok := rawArgs["freshness"].(string); ok && freshness != "" {
+			args = append(args, "--freshness", freshness)
+		}
+		if sites, ok := rawArgs["sites"].([]interface{}); ok {
+			for _, site := range sites {
+				args = append(args, "--site", fmt.Sprintf("%v", site))
+			}
+		}
+		return append(args, "--", question), nil
+
 	case baseName == "prompt":
 		if msg, ok := rawArgs["message"].(string); ok {
 			return []string{"prompt", msg}, nil
@@ -275,3 +615,12 @@ func BuildBuiltinCommandArgs(toolName string, rawArgs map[string]interface{}) ([
 
 	return nil, fmt.Errorf("no string builder mapped for %s", toolName)
 }
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
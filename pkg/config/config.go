@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -19,20 +20,30 @@ type Config struct {
 	Server    ServerConfig              `json:"server"`
 	Skills    SkillsConfig              `json:"skills"`
 	WebSearch WebSearchConfig           `json:"websearch"`
+	Fetch     FetchConfig               `json:"fetch,omitempty"`
+	Egress    EgressConfig              `json:"egress,omitempty"`
+	Cache     CacheConfig               `json:"cache,omitempty"`
+	Research  ResearchConfig            `json:"research,omitempty"`
 	Storage   StorageConfig             `json:"storage"`
 	Session   SessionConfig             `json:"session"`
+	SMTP      SMTPConfig                `json:"smtp,omitempty"` // Mail server for "email" cron targets
 
 	UseNativeToolCalling bool                       `json:"useNativeToolCalling"` // default true
 	MCPServers           map[string]MCPServerConfig `json:"mcpServers,omitempty"`
 
 	Timezone string `json:"timezone,omitempty"` // e.g. "Europe/Berlin"
 	MaxTurns int    `json:"maxTurns,omitempty"` // Global max turns (default: 5)
+
+	CronMaxConcurrent int `json:"cronMaxConcurrent,omitempty"` // Cap on cron jobs running at once (default: unlimited)
 }
 
 type MCPServerConfig struct {
-	Command string            `json:"command"`
-	Args    []string          `json:"args,omitempty"`
-	Env     map[string]string `json:"env,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	URL       string            `json:"url,omitempty"`       // Streamable HTTP endpoint, used instead of Command
+	Headers   map[string]string `json:"headers,omitempty"`   // HTTP: added to every request, e.g. Authorization
+	TimeoutMs int               `json:"timeoutMs,omitempty"` // Per-request timeout (default 60s)
 }
 
 type SessionConfig struct {
@@ -45,6 +56,52 @@ type StorageConfig struct {
 	TempDir string `json:"tempDir"`
 }
 
+type FetchConfig struct {
+	MaxChars     int                    `json:"maxChars,omitempty"`     // Character budget per page of extracted content (default 10000)
+	MaxBodyBytes int                    `json:"maxBodyBytes,omitempty"` // Maximum response body size read into memory (default 5MB)
+	TimeoutMs    int                    `json:"timeoutMs,omitempty"`    // Request timeout (default 30s)
+	Auth         map[string]AuthProfile `json:"auth,omitempty"`         // Named credentials referenced via --auth <name>
+}
+
+// AuthProfile holds credentials for fetch. Use ${ENV} placeholders for secrets;
+// the model only ever sees the profile name.
+type AuthProfile struct {
+	Type     string   `json:"type"`               // "bearer", "basic" or "header"
+	Token    string   `json:"token,omitempty"`    // bearer
+	Username string   `json:"username,omitempty"` // basic
+	Password string   `json:"password,omitempty"` // basic
+	Header   string   `json:"header,omitempty"`   // header: name, e.g. "X-API-Key"
+	Value    string   `json:"value,omitempty"`    // header: value
+	Hosts    []string `json:"hosts"`              // Hosts this profile may be sent to (supports "*.domain")
+}
+
+// EgressConfig restricts which network destinations fetch, websearch and other
+// outbound HTTP clients may reach. Private, loopback and link-local ranges are denied by default.
+type EgressConfig struct {
+	AllowPrivate bool     `json:"allowPrivate,omitempty"` // Allow loopback/private/link-local destinations
+	AllowOnly    bool     `json:"allowOnly,omitempty"`    // If true, only hosts in AllowHosts may be reached
+	AllowHosts   []string `json:"allowHosts,omitempty"`   // Hostnames, "*.domain" wildcards, IPs or CIDRs that are always allowed
+	DenyHosts    []string `json:"denyHosts,omitempty"`    // Hostnames, wildcards, IPs or CIDRs that are always denied
+	Schemes      []string `json:"schemes,omitempty"`      // Allowed URL schemes (default: http, https)
+	Ports        []int    `json:"ports,omitempty"`        // Allowed destination ports (default: any)
+}
+
+// CacheConfig controls the on-disk HTTP cache kept under Storage.TempDir/cache.
+type CacheConfig struct {
+	Disabled         bool `json:"disabled,omitempty"`         // Turn off caching for fetch and websearch
+	MaxSizeMB        int  `json:"maxSizeMB,omitempty"`        // Size bound before least recently used entries are evicted (default 100)
+	SearchTTLMinutes int  `json:"searchTtlMinutes,omitempty"` // How long websearch results are reused (default 60)
+}
+
+// ResearchConfig bounds the `yaocc research` pipeline.
+type ResearchConfig struct {
+	Model         string `json:"model,omitempty"`         // "provider/modelID" used for planning and synthesis (default: selected model)
+	MaxSubQueries int    `json:"maxSubQueries,omitempty"` // Search queries derived from the question (default 3)
+	MaxSources    int    `json:"maxSources,omitempty"`    // Pages fetched and cited (default 5)
+	TimeoutMs     int    `json:"timeoutMs,omitempty"`     // Wall-clock budget for the whole run (default 120s)
+	MaxTokens     int    `json:"maxTokens,omitempty"`     // Approximate token budget for extracted passages (default 6000)
+}
+
 type WebSearchConfig struct {
 	Provider  string                    `json:"provider"`
 	Providers map[string]SearchProvider `json:"providers"`
@@ -52,12 +109,26 @@ type WebSearchConfig struct {
 
 type SearchProvider struct {
 	Name       string         `json:"name"`
-	Type       string         `json:"type"` // e.g., "searxng"
+	Type       string         `json:"type"` // "searxng", "brave", "perplexity", "tavily", "google", "duckduckgo" or "meta"
 	Endpoint   string         `json:"endpoint"`
 	APIKey     string         `json:"apiKey,omitempty"`
 	FreeTier   FreeTierConfig `json:"freeTier,omitempty"`
 	Fallback   string         `json:"fallback,omitempty"`   // General fallback provider name
 	MaxResults int            `json:"maxResults,omitempty"` // Max results to return (default 5)
+	Providers  []string       `json:"providers,omitempty"`  // meta: names of the providers to query and fuse
+	EngineID   string         `json:"engineId,omitempty"`   // google: Programmable Search Engine ID (cx)
+	Quota      QuotaConfig    `json:"quota,omitempty"`      // Request limits; the fallback is used once a window is exhausted
+}
+
+// QuotaConfig limits how often a search provider is called. Zero means unlimited.
+type QuotaConfig struct {
+	PerSecond int `json:"perSecond,omitempty"` // Requests are delayed to stay under this rate
+	PerDay    int `json:"perDay,omitempty"`
+	PerMonth  int `json:"perMonth,omitempty"`
+}
+
+func (q QuotaConfig) IsZero() bool {
+	return q.PerSecond == 0 && q.PerDay == 0 && q.PerMonth == 0
 }
 
 type FreeTierConfig struct {
@@ -106,30 +177,97 @@ type MessagingProviderConfig struct {
 }
 
 type TelegramConfig struct {
-	Enabled      bool     `json:"enabled"`
-	BotToken     string   `json:"botToken"`
-	AllowedUsers []string `json:"allowedUsers"`
+	Enabled       bool     `json:"enabled"`
+	BotToken      string   `json:"botToken"`
+	AllowedUsers  []string `json:"allowedUsers"`
+	StatusUpdates bool     `json:"statusUpdates,omitempty"` // Show a status message while the agent uses tools
 }
 
 type CronJob struct {
+	ID         string       `json:"id,omitempty"` // Stable identifier, assigned when the job is created
 	Name       string       `json:"name"`
+	Enabled    *bool        `json:"enabled,omitempty"` // Defaults to true
 	Schedule   string       `json:"schedule"`
-	Type       string       `json:"type"` // "prompt" or "script"
+	At         string       `json:"at,omitempty"`      // One-shot jobs: RFC3339 time to run once, after which the job is removed
+	Trigger    *CronTrigger `json:"trigger,omitempty"` // Event jobs: run on a file change, webhook or message instead of on time
+	Type       string       `json:"type"`              // "prompt", "script" or "digest"
 	Prompt     string       `json:"prompt,omitempty"`
 	Script     string       `json:"script,omitempty"`
 	SessionID  string       `json:"sessionId,omitempty"`
 	UseHistory bool         `json:"useHistory,omitempty"` // If true, execute in context of target session. If false, stateless.
+	UseTools   bool         `json:"useTools,omitempty"`   // Stateless prompts run the full agent loop with tools in an ephemeral session
+	LogSession string       `json:"logSession,omitempty"` // Session that ephemeral runs are appended to, for review (optional)
 	Targets    []CronTarget `json:"targets,omitempty"`
+
+	Digest []CronDigestSource `json:"digest,omitempty"` // Digest jobs: run these concurrently and combine their outputs in one prompt
+
+	TimeoutMs   int    `json:"timeoutMs,omitempty"`   // Maximum run time (default: no limit)
+	Retries     int    `json:"retries,omitempty"`     // Extra attempts for failed LLM calls and deliveries
+	BackoffMs   int    `json:"backoffMs,omitempty"`   // Delay before the first retry, doubled after each one (default 2s)
+	Concurrency string `json:"concurrency,omitempty"` // While the previous run is active: "allow" (default), "skip" or "queue"
+
+	CatchUp         string `json:"catchUp,omitempty"`         // Runs missed while the server was down: "none" (default), "once" or "all"
+	CatchUpWindowMs int    `json:"catchUpWindowMs,omitempty"` // How far back missed runs are caught up (default 24h)
+
+	Notify *CronNotifyConfig `json:"notify,omitempty"` // When to send the result (default: always)
+}
+
+// CronNotifyConfig decides whether a run's result is sent. Conditions are checked against the
+// script output, or the LLM response for jobs without a script.
+type CronNotifyConfig struct {
+	On        string `json:"on"`                  // "always", "failure" (exit code != 0), "change", "match" or "judge"
+	Pattern   string `json:"pattern,omitempty"`   // Regular expression for "match"
+	Condition string `json:"condition,omitempty"` // Condition the LLM checks for "judge", e.g. "a disk is over 90% full"
+	Resolved  bool   `json:"resolved,omitempty"`  // Send a message once a failure, match or judged condition clears
+}
+
+// CronTrigger makes a job run on an event instead of a schedule.
+type CronTrigger struct {
+	Type         string `json:"type"`                   // "file", "webhook" or "message"
+	Path         string `json:"path,omitempty"`         // file: file or directory to watch, relative to the config dir
+	Pattern      string `json:"pattern,omitempty"`      // file: glob on file names; message: regular expression
+	Secret       string `json:"secret,omitempty"`       // webhook: shared secret or HMAC-SHA256 key callers must present
+	RequireToken bool   `json:"requireToken,omitempty"` // webhook: callers must send an API token with the cron scope (always without a secret)
+	DebounceMs   int    `json:"debounceMs,omitempty"`   // file: wait until changes settle for this long (default 2s)
 }
 
+// CronDigestSource is one input of a digest job: another job or a script.
+type CronDigestSource struct {
+	Label  string `json:"label,omitempty"`  // Heading of the output in the prompt (default: the job name or script)
+	Job    string `json:"job,omitempty"`    // ID or name of a job whose output is included; it is not delivered on its own
+	Script string `json:"script,omitempty"` // Script whose output is included
+}
+
+// CronTarget is where a job's result goes. Besides messaging providers, the built-in kinds are
+// "local" (the inbox shown by `yaocc inbox`), "file", "webhook" and "email".
 type CronTarget struct {
-	Provider string `json:"provider"` // "telegram", "local"
-	ID       string `json:"id"`       // chat_id or session_id
+	Provider string `json:"provider"`         // "telegram", "local", "file", "webhook" or "email"
+	ID       string `json:"id"`               // chat_id, session_id, file path ({date}, {month} and {job} are filled in), URL or email addresses
+	Secret   string `json:"secret,omitempty"` // webhook: HMAC-SHA256 key for the X-Hub-Signature-256 header
+}
+
+// SMTPConfig is the mail server used to send email targets.
+type SMTPConfig struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"` // Default 587 (STARTTLS); 465 uses implicit TLS
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"` // Sender address (default: the username)
 }
 
 type ServerConfig struct {
-	Port      int    `json:"port"`
-	AuthToken string `json:"authToken"`
+	Port           int      `json:"port"`
+	AuthToken      string   `json:"authToken"`                // Bearer token with every scope
+	APIKeys        []APIKey `json:"apiKeys,omitempty"`        // Additional named keys with limited scopes
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"` // Other browser origins that may open WebSockets, e.g. "https://dash.example.com"
+}
+
+// APIKey is a named bearer token for the server API. Scopes are "chat", "exec", "cron" and
+// "admin"; admin grants all of them.
+type APIKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
 }
 
 type SkillsConfig struct {
@@ -335,3 +473,25 @@ func (c *Config) GetCmdConfig(name string) *CmdConfig {
 	}
 	return nil
 }
+
+// ResolveModel looks up a "provider/modelID" reference (empty means the selected model)
+// and returns the provider config together with the model entry.
+func (c *Config) ResolveModel(ref string) (ProviderConfig, ModelConfig, error) {
+	if ref == "" {
+		ref = c.Models.Selected
+	}
+	providerKey, modelID, ok := strings.Cut(ref, "/")
+	if !ok || modelID == "" {
+		return ProviderConfig{}, ModelConfig{}, fmt.Errorf("invalid model string '%s' (format: provider/modelID)", ref)
+	}
+	provider, ok := c.Models.Providers[providerKey]
+	if !ok {
+		return ProviderConfig{}, ModelConfig{}, fmt.Errorf("provider '%s' not found in config", providerKey)
+	}
+	for _, m := range provider.Models {
+		if m.ID == modelID {
+			return provider, m, nil
+		}
+	}
+	return ProviderConfig{}, ModelConfig{}, fmt.Errorf("model ID '%s' not found in provider '%s' configuration", modelID, providerKey)
+}
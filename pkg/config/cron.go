@@ -0,0 +1,406 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// IsEnabled reports whether the job should be scheduled. Jobs are enabled unless disabled explicitly.
+func (j CronJob) IsEnabled() bool {
+	return j.Enabled == nil || *j.Enabled
+}
+
+// JobID returns the job's ID. Jobs created before IDs existed get one derived from their
+// name, which EnsureCronJobIDs persists on the next config update so it survives renames.
+func (j CronJob) JobID() string {
+	if j.ID != "" {
+		return j.ID
+	}
+	sum := sha1.Sum([]byte(strings.ToLower(j.Name)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// NewCronJobID returns a random job ID that is not used by any of jobs.
+func NewCronJobID(jobs []CronJob) string {
+	for {
+		b := make([]byte, 4)
+		rand.Read(b)
+		id := hex.EncodeToString(b)
+		if FindCronJob(jobs, id) < 0 {
+			return id
+		}
+	}
+}
+
+// EnsureCronJobIDs assigns an ID to every job that lacks one.
+func EnsureCronJobIDs(jobs []CronJob) {
+	for i := range jobs {
+		if jobs[i].ID != "" {
+			continue
+		}
+		id := jobs[i].JobID()
+		if idx := FindCronJob(jobs, id); idx >= 0 && idx != i {
+			id = NewCronJobID(jobs)
+		}
+		jobs[i].ID = id
+	}
+}
+
+// FindCronJob returns the index of the job whose ID or (case-insensitive) name matches ref, or -1.
+func FindCronJob(jobs []CronJob, ref string) int {
+	for i, job := range jobs {
+		if job.JobID() == ref {
+			return i
+		}
+	}
+	for i, job := range jobs {
+		if strings.EqualFold(job.Name, ref) {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsOneShot reports whether the job runs once at a fixed time instead of on a schedule.
+func (j CronJob) IsOneShot() bool {
+	return j.At != ""
+}
+
+// IsDigest reports whether the job combines the outputs of other jobs and scripts.
+func (j CronJob) IsDigest() bool {
+	return len(j.Digest) > 0
+}
+
+// IsTriggered reports whether the job runs on events instead of on time.
+func (j CronJob) IsTriggered() bool {
+	return j.Trigger != nil
+}
+
+// Validate checks the job's timing and execution policy.
+func (j CronJob) Validate() error {
+	switch {
+	case j.Trigger != nil:
+		if j.Schedule != "" || j.At != "" {
+			return fmt.Errorf("a triggered job cannot also have a schedule or an 'at' time")
+		}
+		if err := j.Trigger.Validate(); err != nil {
+			return err
+		}
+	case j.Schedule != "" && j.At != "":
+		return fmt.Errorf("a job has either a schedule or an 'at' time, not both")
+	case j.Schedule == "" && j.At == "":
+		return fmt.Errorf("a job needs a schedule, an 'at' time or a trigger")
+	case j.At != "":
+		if _, err := time.Parse(time.RFC3339, j.At); err != nil {
+			return fmt.Errorf("invalid 'at' time '%s' (use RFC3339, e.g. 2026-01-02T15:04:05+01:00)", j.At)
+		}
+	}
+	switch j.Concurrency {
+	case "", "skip", "queue", "allow":
+	default:
+		return fmt.Errorf("invalid concurrency '%s' (use allow, skip or queue)", j.Concurrency)
+	}
+	if j.IsDigest() {
+		if j.Script != "" {
+			return fmt.Errorf("a digest job runs its sources instead of a script")
+		}
+		for _, src := range j.Digest {
+			if (src.Job == "") == (src.Script == "") {
+				return fmt.Errorf("each digest source needs either a job or a script")
+			}
+		}
+	}
+	if j.UseHistory && j.UseTools {
+		return fmt.Errorf("useHistory and useTools cannot be combined (history-aware jobs already use tools)")
+	}
+	switch j.CatchUp {
+	case "", "none", "once", "all":
+	default:
+		return fmt.Errorf("invalid catch-up policy '%s' (use none, once or all)", j.CatchUp)
+	}
+	if j.TimeoutMs < 0 || j.Retries < 0 || j.BackoffMs < 0 || j.CatchUpWindowMs < 0 {
+		return fmt.Errorf("timeout, retries, backoff and catch-up window cannot be negative")
+	}
+	for _, t := range j.Targets {
+		if t.Provider == "file" && !IsWorkspacePath(t.ID) {
+			return fmt.Errorf("file target '%s' must be a relative path inside the config dir", t.ID)
+		}
+	}
+	if j.Notify != nil {
+		return j.Notify.Validate()
+	}
+	return nil
+}
+
+// IsWorkspacePath reports whether path is relative and stays inside the directory it is
+// resolved against. A leading ~ is refused too, since ResolvePath would expand it.
+func IsWorkspacePath(path string) bool {
+	if path == "" || filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, "~") {
+		return false
+	}
+	clean := filepath.Clean(path)
+	return clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+// Validate checks that the condition has what it needs.
+func (n CronNotifyConfig) Validate() error {
+	switch n.On {
+	case "", "always", "failure", "change":
+	case "match":
+		if n.Pattern == "" {
+			return fmt.Errorf("notify on match requires a pattern")
+		}
+		if _, err := regexp.Compile(n.Pattern); err != nil {
+			return fmt.Errorf("invalid notify pattern: %w", err)
+		}
+	case "judge":
+		if strings.TrimSpace(n.Condition) == "" {
+			return fmt.Errorf("notify on judge requires a condition")
+		}
+	default:
+		return fmt.Errorf("invalid notify condition '%s' (use always, failure, change, match or judge)", n.On)
+	}
+	return nil
+}
+
+// Validate checks that the trigger has what its type needs.
+func (t CronTrigger) Validate() error {
+	switch t.Type {
+	case "file":
+		if strings.TrimSpace(t.Path) == "" {
+			return fmt.Errorf("file trigger requires a path")
+		}
+		if !IsWorkspacePath(t.Path) {
+			return fmt.Errorf("file trigger path '%s' must be a relative path inside the config dir", t.Path)
+		}
+		if t.Pattern != "" {
+			if _, err := filepath.Match(t.Pattern, ""); err != nil {
+				return fmt.Errorf("invalid file pattern: %w", err)
+			}
+		}
+	case "webhook":
+		// The payload ends up in the job's prompt, so anonymous callers must not fire it
+		if t.Secret == "" && !t.RequireToken {
+			return fmt.Errorf("webhook trigger requires a secret or requireToken")
+		}
+	case "message":
+		if t.Pattern == "" {
+			return fmt.Errorf("message trigger requires a pattern")
+		}
+		if _, err := regexp.Compile(t.Pattern); err != nil {
+			return fmt.Errorf("invalid message pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid trigger type '%s' (use file, webhook or message)", t.Type)
+	}
+	if t.DebounceMs < 0 {
+		return fmt.Errorf("trigger debounce cannot be negative")
+	}
+	return nil
+}
+
+// CronJobPatch holds the fields of an edit; nil fields are left unchanged.
+type CronJobPatch struct {
+	Name       *string       `json:"name,omitempty"`
+	Schedule   *string       `json:"schedule,omitempty"` // Replaces an 'at' time or trigger
+	At         *string       `json:"at,omitempty"`       // Replaces the schedule or trigger
+	Trigger    *CronTrigger  `json:"trigger,omitempty"`  // Replaces the schedule or 'at' time
+	Prompt     *string       `json:"prompt,omitempty"`
+	Script     *string       `json:"script,omitempty"`
+	SessionID  *string       `json:"sessionId,omitempty"`
+	UseHistory *bool         `json:"useHistory,omitempty"`
+	UseTools   *bool         `json:"useTools,omitempty"`
+	LogSession *string       `json:"logSession,omitempty"`
+	Targets    *[]CronTarget `json:"targets,omitempty"`
+	Enabled    *bool         `json:"enabled,omitempty"`
+
+	Digest *[]CronDigestSource `json:"digest,omitempty"` // Replaces the sources; empty turns the digest back into a prompt job
+
+	TimeoutMs   *int    `json:"timeoutMs,omitempty"`
+	Retries     *int    `json:"retries,omitempty"`
+	BackoffMs   *int    `json:"backoffMs,omitempty"`
+	Concurrency *string `json:"concurrency,omitempty"`
+
+	CatchUp         *string `json:"catchUp,omitempty"`
+	CatchUpWindowMs *int    `json:"catchUpWindowMs,omitempty"`
+
+	Notify *CronNotifyConfig `json:"notify,omitempty"` // Replaces the notification settings; On "always" clears them
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p CronJobPatch) IsEmpty() bool {
+	return p == CronJobPatch{}
+}
+
+// Apply updates job with the fields set in the patch. The job type follows from whether it has
+// digest sources or a script.
+func (p CronJobPatch) Apply(job *CronJob) error {
+	if p.Name != nil {
+		if strings.TrimSpace(*p.Name) == "" {
+			return fmt.Errorf("job name cannot be empty")
+		}
+		job.Name = *p.Name
+	}
+	if p.Schedule != nil {
+		job.Schedule, job.At, job.Trigger = *p.Schedule, "", nil
+	}
+	if p.At != nil {
+		job.At, job.Schedule, job.Trigger = *p.At, "", nil
+	}
+	if p.Trigger != nil {
+		trigger := *p.Trigger
+		job.Trigger, job.Schedule, job.At = &trigger, "", ""
+	}
+	if p.Prompt != nil {
+		job.Prompt = *p.Prompt
+	}
+	if p.Script != nil {
+		job.Script = *p.Script
+	}
+	if p.SessionID != nil {
+		job.SessionID = *p.SessionID
+	}
+	if p.UseHistory != nil {
+		job.UseHistory = *p.UseHistory
+	}
+	if p.UseTools != nil {
+		job.UseTools = *p.UseTools
+	}
+	if p.LogSession != nil {
+		job.LogSession = *p.LogSession
+	}
+	if p.Targets != nil {
+		job.Targets = *p.Targets
+	}
+	if p.Digest != nil {
+		job.Digest = *p.Digest
+	}
+	if p.Enabled != nil {
+		enabled := *p.Enabled
+		job.Enabled = &enabled
+	}
+	if p.TimeoutMs != nil {
+		job.TimeoutMs = *p.TimeoutMs
+	}
+	if p.Retries != nil {
+		job.Retries = *p.Retries
+	}
+	if p.BackoffMs != nil {
+		job.BackoffMs = *p.BackoffMs
+	}
+	if p.Concurrency != nil {
+		job.Concurrency = *p.Concurrency
+	}
+	if p.CatchUp != nil {
+		job.CatchUp = *p.CatchUp
+	}
+	if p.CatchUpWindowMs != nil {
+		job.CatchUpWindowMs = *p.CatchUpWindowMs
+	}
+	if p.Notify != nil {
+		notify := *p.Notify
+		job.Notify = &notify
+		if notify.On == "" || notify.On == "always" {
+			job.Notify = nil
+		}
+	}
+
+	job.setType()
+	if job.Prompt == "" && job.Script == "" && !job.IsDigest() {
+		return fmt.Errorf("job needs a prompt or a script")
+	}
+	return job.Validate()
+}
+
+// setType derives the job type from whether it has digest sources or a script.
+func (j *CronJob) setType() {
+	switch {
+	case j.IsDigest():
+		j.Type = "digest"
+	case j.Script != "":
+		j.Type = "script"
+	default:
+		j.Type = "prompt"
+	}
+}
+
+// UpdateCronJob applies patch to the job matching ref (ID or name) in the config file at path
+// and returns the updated job. Jobs without an ID get one persisted along the way.
+func UpdateCronJob(path, ref string, patch CronJobPatch) (CronJob, error) {
+	var updated CronJob
+	err := UpdateConfigRawWithPath(path, func(cfg *Config) error {
+		EnsureCronJobIDs(cfg.Cron)
+		idx := FindCronJob(cfg.Cron, ref)
+		if idx < 0 {
+			return fmt.Errorf("job '%s' not found", ref)
+		}
+
+		job := cfg.Cron[idx]
+		if err := patch.Apply(&job); err != nil {
+			return err
+		}
+		for i, other := range cfg.Cron {
+			if i != idx && strings.EqualFold(other.Name, job.Name) {
+				return fmt.Errorf("job with name '%s' already exists", job.Name)
+			}
+		}
+		cfg.Cron[idx] = job
+		updated = job
+		return nil
+	})
+	return updated, err
+}
+
+// AddCronJob validates job, gives it a new ID and appends it to the config file at path. Job
+// names must be unique. Without a type, it follows from whether the job has digest sources or a
+// script.
+func AddCronJob(path string, job CronJob) (CronJob, error) {
+	if strings.TrimSpace(job.Name) == "" {
+		return CronJob{}, fmt.Errorf("job name cannot be empty")
+	}
+	if job.Type == "" {
+		job.setType()
+	}
+	if job.Prompt == "" && job.Script == "" && !job.IsDigest() {
+		return CronJob{}, fmt.Errorf("job needs a prompt or a script")
+	}
+	if err := job.Validate(); err != nil {
+		return CronJob{}, err
+	}
+	err := UpdateConfigRawWithPath(path, func(cfg *Config) error {
+		for _, other := range cfg.Cron {
+			if strings.EqualFold(other.Name, job.Name) {
+				return fmt.Errorf("job with name '%s' already exists", job.Name)
+			}
+		}
+		EnsureCronJobIDs(cfg.Cron)
+		job.ID = NewCronJobID(cfg.Cron)
+		cfg.Cron = append(cfg.Cron, job)
+		return nil
+	})
+	return job, err
+}
+
+// RemoveCronJob deletes the job matching ref (ID or name) from the config file at path and
+// returns it.
+func RemoveCronJob(path, ref string) (CronJob, error) {
+	var removed CronJob
+	err := UpdateConfigRawWithPath(path, func(cfg *Config) error {
+		idx := FindCronJob(cfg.Cron, ref)
+		if idx < 0 {
+			return fmt.Errorf("job '%s' not found", ref)
+		}
+
+		EnsureCronJobIDs(cfg.Cron)
+		removed = cfg.Cron[idx]
+		cfg.Cron = append(cfg.Cron[:idx], cfg.Cron[idx+1:]...)
+		return nil
+	})
+	return removed, err
+}
@@ -19,6 +19,39 @@ func UpdateConfigRaw(modifier func(*Config) error) error {
 // applies the modifier function, and writes it back.
 // This preserves environment variable placeholders like "${SERVER_TOKEN}".
 func UpdateConfigRawWithPath(path string, modifier func(*Config) error) error {
+	configPath := resolveRawPath(path)
+	cfg, err := readConfigRaw(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Apply modifier
+	if err := modifier(cfg); err != nil {
+		return err
+	}
+
+	// Marshal back with indentation
+	updatedData, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	// Write back
+	if err := os.WriteFile(configPath, updatedData, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadConfigRaw reads the configuration file like UpdateConfigRawWithPath, without expanding
+// env vars, so placeholders like "${SERVER_TOKEN}" are returned as written.
+func LoadConfigRaw(path string) (*Config, error) {
+	return readConfigRaw(resolveRawPath(path))
+}
+
+// resolveRawPath finds the config file: path as given, else inside the config dir.
+func resolveRawPath(path string) string {
 	// Load .env to ensure YAOCC_CONFIG_DIR is available
 	_ = godotenv.Load()
 
@@ -42,37 +75,23 @@ func UpdateConfigRawWithPath(path string, modifier func(*Config) error) error {
 			// But if it fails, maybe we just use the relative path (CWD) and let ReadFile fail.
 		}
 	}
+	return configPath
+}
 
+func readConfigRaw(configPath string) (*Config, error) {
 	// Read the file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("config file not found at %s", configPath)
+			return nil, fmt.Errorf("config file not found at %s", configPath)
 		}
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	// Unmarshal directly without os.ExpandEnv
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
-
-	// Apply modifier
-	if err := modifier(&cfg); err != nil {
-		return err
-	}
-
-	// Marshal back with indentation
-	updatedData, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	// Write back
-	if err := os.WriteFile(configPath, updatedData, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-
-	return nil
+	return &cfg, nil
 }
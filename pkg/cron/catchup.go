@@ -0,0 +1,146 @@
+package cron
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultCatchUpWindow = 24 * time.Hour
+	maxCatchUpRuns       = 100 // Most recent missed runs replayed with catch-up "all"
+)
+
+// Fire is the last time the scheduler fired a job, or started watching it.
+type Fire struct {
+	At       time.Time `json:"at"`
+	Schedule string    `json:"schedule"` // Schedule at that time; catch-up is skipped if it changed since
+}
+
+func (h *History) firesPath() string {
+	return filepath.Join(h.Dir, "fires.json")
+}
+
+func (h *History) loadFires() (map[string]Fire, error) {
+	fires := make(map[string]Fire)
+	data, err := os.ReadFile(h.firesPath())
+	if os.IsNotExist(err) {
+		return fires, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fires); err != nil {
+		return nil, err
+	}
+	return fires, nil
+}
+
+// LastFire returns when the job last fired on schedule, if that was recorded.
+func (h *History) LastFire(jobID string) (Fire, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fires, err := h.loadFires()
+	if err != nil {
+		return Fire{}, false
+	}
+	fire, ok := fires[jobID]
+	return fire, ok
+}
+
+// RecordFire persists the job's last fire time in <dir>/fires.json.
+func (h *History) RecordFire(jobID string, fire Fire) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fires, err := h.loadFires()
+	if err != nil {
+		fires = make(map[string]Fire) // Start over from a corrupt file
+	}
+	fires[jobID] = fire
+	if err := os.MkdirAll(h.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fires, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := h.firesPath()
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *Scheduler) recordFire(job config.CronJob, at time.Time) {
+	if err := s.History.RecordFire(job.JobID(), Fire{At: at, Schedule: job.Schedule}); err != nil {
+		log.Printf("Job %s: failed to record fire time: %v", job.Name, err)
+	}
+}
+
+// catchUp replays the runs of job that were missed since it last fired, following the
+// job's catch-up policy. Only called when the scheduler starts, not on reloads.
+func (s *Scheduler) catchUp(job config.CronJob) {
+	last, ok := s.History.LastFire(job.JobID())
+	now := time.Now()
+	s.recordFire(job, now) // From here on, downtime is measured from now
+	if !ok || last.Schedule != job.Schedule || job.CatchUp == "" || job.CatchUp == "none" {
+		return
+	}
+
+	missed, dropped := missedRuns(job, s.Cron.Location(), last.At, now)
+	if dropped > 0 {
+		log.Printf("Job %s: skipping %d missed run(s) outside the catch-up window", job.Name, dropped)
+	}
+	if len(missed) == 0 {
+		return
+	}
+	if job.CatchUp == "once" {
+		missed = missed[len(missed)-1:]
+	}
+
+	log.Printf("Job %s: catching up %d missed run(s) since %s", job.Name, len(missed), last.At.Format(time.RFC3339))
+	go func() {
+		for _, at := range missed {
+			s.runJobAt(job, "catch-up", at)
+		}
+	}()
+}
+
+// missedRuns lists the fire times of job after last and up to now that fall inside its
+// catch-up window, oldest first, along with the number of older ones that are dropped.
+func missedRuns(job config.CronJob, loc *time.Location, last, now time.Time) (missed []time.Time, dropped int) {
+	sched, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return nil, 0
+	}
+	window := defaultCatchUpWindow
+	if job.CatchUpWindowMs > 0 {
+		window = time.Duration(job.CatchUpWindowMs) * time.Millisecond
+	}
+	oldest := now.Add(-window)
+
+	from := last.In(loc)
+	if first := sched.Next(from); !first.IsZero() && first.Before(oldest) {
+		// Count what is dropped without walking a long downtime fire by fire
+		for t := first; !t.IsZero() && t.Before(oldest) && dropped < maxCatchUpRuns; t = sched.Next(t) {
+			dropped++
+		}
+		from = oldest.Add(-time.Second).In(loc)
+	}
+	for t := sched.Next(from); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		if !t.Before(oldest) {
+			missed = append(missed, t)
+		}
+	}
+	if len(missed) > maxCatchUpRuns {
+		dropped += len(missed) - maxCatchUpRuns
+		missed = missed[len(missed)-maxCatchUpRuns:]
+	}
+	return missed, dropped
+}
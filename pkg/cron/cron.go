@@ -2,12 +2,15 @@ package cron
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/agent"
@@ -18,12 +21,33 @@ import (
 )
 
 type Scheduler struct {
-	Config    *config.Config
-	ConfigDir string
-	Agent     *agent.Agent
-	Providers map[string]messaging.Provider
-	Cron      *cron.Cron
-	Quit      chan struct{}
+	Config     *config.Config
+	ConfigDir  string
+	ConfigPath string // Config file to remove fired one-shot jobs from; they stay in it if empty
+	Agent      *agent.Agent
+	Providers  map[string]messaging.Provider
+	Cron       *cron.Cron
+	History    *History
+	Inbox      *Inbox // Messages for "local" targets
+	Quit       chan struct{}
+
+	mu      sync.Mutex
+	entries map[string]cron.EntryID // Job ID -> scheduled entry
+	states  map[string]*jobState    // Job ID -> active runs
+	slots   chan struct{}           // Global concurrency cap, nil if unlimited
+	fileMu  sync.Mutex              // Serializes config edits for fired one-shot jobs and writes to file targets
+	started bool                    // Start ran before; later calls are reloads
+
+	lifeMu  sync.Mutex // Serializes Start, Stop and Reload
+	stopped bool       // Stop ran; later reloads are ignored
+}
+
+// JobStatus describes a configured job together with its schedule state.
+type JobStatus struct {
+	config.CronJob
+	NextRun *time.Time `json:"nextRun,omitempty"`
+	LastRun *Run       `json:"lastRun,omitempty"`
+	Running bool       `json:"running,omitempty"`
 }
 
 func NewScheduler(cfg *config.Config, configDir string, a *agent.Agent, providers map[string]messaging.Provider) *Scheduler {
@@ -44,35 +68,107 @@ func NewScheduler(cfg *config.Config, configDir string, a *agent.Agent, provider
 		Agent:     a,
 		Providers: providers,
 		Cron:      cron.New(opts...),
+		History:   NewHistory(configDir),
+		Inbox:     NewInbox(configDir),
 		Quit:      make(chan struct{}),
+		entries:   make(map[string]cron.EntryID),
+		states:    make(map[string]*jobState),
+		slots:     newSlots(cfg),
 	}
 }
 
 func (s *Scheduler) Start() {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.start()
+}
+
+func (s *Scheduler) start() {
+	s.mu.Lock()
+	prev := s.entries
+	s.entries = make(map[string]cron.EntryID)
+	startup := !s.started
+	s.started = true
+	s.mu.Unlock()
+
 	// Cron Jobs
 	for _, job := range s.Config.Cron {
+		if !job.IsEnabled() {
+			log.Printf("Skipping disabled cron job: %s", job.Name)
+			continue
+		}
+		if job.IsOneShot() {
+			s.scheduleOnce(job)
+			continue
+		}
+		if job.IsTriggered() {
+			if job.Trigger.Type == "file" {
+				go s.watchFiles(job, s.Quit)
+			}
+			log.Printf("Registered %s trigger for job: %s", job.Trigger.Type, job.Name)
+			continue
+		}
 		jobCopy := job // Capture for closure
-		_, err := s.Cron.AddFunc(job.Schedule, func() {
-			s.RunJob(jobCopy)
+		id, err := s.Cron.AddFunc(job.Schedule, func() {
+			s.recordFire(jobCopy, time.Now())
+			s.runJob(jobCopy, "schedule")
 		})
 		if err != nil {
 			log.Printf("Error scheduling job %s: %v", job.Name, err)
 		} else {
+			s.mu.Lock()
+			s.entries[job.JobID()] = id
+			s.mu.Unlock()
 			log.Printf("Scheduled cron job: %s (%s)", job.Name, job.Schedule)
 		}
+
+		// Missed runs are only caught up after downtime. Jobs that are new, re-enabled or
+		// rescheduled by a reload start measuring from now.
+		if startup {
+			s.catchUp(job)
+		} else if last, ok := s.History.LastFire(job.JobID()); !ok || last.Schedule != job.Schedule {
+			s.recordFire(job, time.Now())
+		} else if _, ok := prev[job.JobID()]; !ok {
+			s.recordFire(job, time.Now())
+		}
 	}
 
 	s.Cron.Start()
 }
 
+// Stop stops scheduling for good: later calls and reloads do nothing. Runs in progress
+// are not waited for.
 func (s *Scheduler) Stop() {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.halt()
+}
+
+func (s *Scheduler) halt() {
 	close(s.Quit)
 	s.Cron.Stop()
 }
 
+// Reload reschedules the jobs of newCfg. It is safe to call concurrently; after Stop it
+// does nothing.
 func (s *Scheduler) Reload(newCfg *config.Config) {
-	s.Stop()
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.stopped {
+		log.Println("Scheduler stopped; ignoring reload.")
+		return
+	}
+	s.halt()
+	s.mu.Lock()
 	s.Config = newCfg
+	s.mu.Unlock()
 
 	// Re-initialize cron with new timezone if applicable
 	var opts []cron.Option
@@ -85,24 +181,197 @@ func (s *Scheduler) Reload(newCfg *config.Config) {
 			log.Printf("Cron scheduler using timezone: %s", s.Config.Timezone)
 		}
 	}
+	s.mu.Lock()
 	s.Cron = cron.New(opts...)
 	s.Quit = make(chan struct{})
-	s.Start()
+	s.slots = newSlots(s.Config)
+	s.mu.Unlock()
+	s.start()
 	log.Println("Scheduler reloaded.")
 }
 
+// Jobs returns every configured job with its next fire time and most recent run.
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.Config.Cron))
+	for _, job := range s.Config.Cron {
+		st := JobStatus{CronJob: job}
+		st.ID = job.JobID()
+		if id, ok := s.entries[st.ID]; ok {
+			if next := s.Cron.Entry(id).Next; !next.IsZero() {
+				st.NextRun = &next
+			}
+		}
+		if last, err := s.History.Last(st.ID); err == nil {
+			st.LastRun = last
+		}
+		if state, ok := s.states[st.ID]; ok {
+			st.Running = state.running.Load() > 0
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// FindJob returns the configured job with the given ID or name.
+func (s *Scheduler) FindJob(ref string) (config.CronJob, bool) {
+	s.mu.Lock()
+	jobs := s.Config.Cron
+	s.mu.Unlock()
+	idx := config.FindCronJob(jobs, ref)
+	if idx < 0 {
+		return config.CronJob{}, false
+	}
+	return jobs[idx], true
+}
+
+// Status returns the schedule state of a single job.
+func (s *Scheduler) Status(ref string) (JobStatus, bool) {
+	job, ok := s.FindJob(ref)
+	if !ok {
+		return JobStatus{}, false
+	}
+	for _, st := range s.Jobs() {
+		if st.ID == job.JobID() {
+			return st, true
+		}
+	}
+	return JobStatus{}, false
+}
+
+// ValidateSchedule checks that schedule is a cron expression or descriptor the scheduler accepts.
+func ValidateSchedule(schedule string) error {
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("invalid schedule '%s': %w", schedule, err)
+	}
+	return nil
+}
+
+// NextFire computes when schedule fires next after the given time, in timezone (local if empty).
+func NextFire(schedule, timezone string, after time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			after = after.In(loc)
+		}
+	}
+	return sched.Next(after), nil
+}
+
 // RunJob executes a cron job immediately. Exported for use by the server API.
-func (s *Scheduler) RunJob(job config.CronJob) {
+func (s *Scheduler) RunJob(job config.CronJob) Run {
+	return s.runJob(job, "manual")
+}
+
+// runJob executes job and records the run in the history.
+func (s *Scheduler) runJob(job config.CronJob, trigger string) Run {
+	return s.runJobAt(job, trigger, time.Time{})
+}
+
+// runJobAt is runJob for a run that was due at scheduled, e.g. a missed run caught up later.
+func (s *Scheduler) runJobAt(job config.CronJob, trigger string, scheduled time.Time) Run {
+	run := Run{Trigger: trigger}
+	if !scheduled.IsZero() {
+		run.ScheduledFor = &scheduled
+	}
+	return s.runJobWith(job, run, nil)
+}
+
+// runJobWith runs job, starting from run for the trigger details. ev is the event that
+// fired a triggered job, nil otherwise.
+func (s *Scheduler) runJobWith(job config.CronJob, run Run, ev *Event) Run {
 	log.Printf("Running job: %s (Type: %s)", job.Name, job.Type)
 
+	run.JobID, run.Job, run.StartedAt = job.JobID(), job.Name, time.Now()
+
+	// Overlap control: the previous run of this job may still be active
+	st := s.state(run.JobID)
+	switch job.Concurrency {
+	case "queue":
+		st.queue.Lock()
+		defer st.queue.Unlock()
+		st.running.Add(1)
+	case "skip":
+		if !st.running.CompareAndSwap(0, 1) {
+			log.Printf("Skipping job %s: previous run still active", job.Name)
+			run.Status = "skipped"
+			run.FinishedAt = run.StartedAt
+			s.record(run)
+			return run
+		}
+	default:
+		st.running.Add(1)
+	}
+	defer st.running.Add(-1)
+
+	// Global cap on concurrently running jobs
+	s.mu.Lock()
+	slots := s.slots
+	s.mu.Unlock()
+	if slots != nil {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	timeout := jobTimeout(job)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	s.execute(ctx, job, &run, ev)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		run.Error = fmt.Sprintf("timed out after %s", timeout)
+	}
+	run.finish(time.Now())
+
+	s.record(run)
+	log.Printf("Job %s finished: %s (%dms)", job.Name, run.Status, run.DurationMs)
+	return run
+}
+
+func (s *Scheduler) record(run Run) {
+	if s.History == nil {
+		return
+	}
+	if err := s.History.Append(run); err != nil {
+		log.Printf("Error recording run of job %s: %v", run.Job, err)
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job config.CronJob, run *Run, ev *Event) {
 	var output string
 	var err error
 
-	// 1. Execute Script if present
-	if job.Script != "" {
-		output, err = executeScript(s.ConfigDir, job.Script)
+	// 1. Execute Script if present; digests run all of their sources instead
+	if job.IsDigest() {
+		if job.Prompt == "" {
+			job.Prompt = defaultDigestPrompt
+		}
+		output, err = s.runDigest(ctx, job, run)
+		run.Output = excerpt(output)
+		if err != nil {
+			log.Printf("Digest %s failed: %v", job.Name, err)
+			run.Error = err.Error()
+			return
+		}
+	} else if job.Script != "" {
+		var exitCode int
+		output, exitCode, err = executeScript(ctx, s.ConfigDir, job.Script, ev)
+		run.ExitCode = &exitCode
+		run.Output = excerpt(output)
 		if err != nil {
 			log.Printf("Job %s failed execution: %v. Output: %s", job.Name, err, output)
+			run.Error = err.Error()
 			// Decide: do we want to notify anyway? Maybe only if prompt is present?
 			// For now, let's treat script failure as meaningful output if there is any.
 			if output == "" {
@@ -114,6 +383,9 @@ func (s *Scheduler) RunJob(job config.CronJob) {
 	// 2. Determine Action based on Job Configuration
 	// Default targets if none specified
 	targets := job.Targets
+	if len(targets) == 0 && ev != nil && ev.Reply != nil {
+		targets = []config.CronTarget{*ev.Reply} // Answer in the chat the message came from
+	}
 	if len(targets) == 0 {
 		sid := job.SessionID
 		if sid == "" {
@@ -122,15 +394,33 @@ func (s *Scheduler) RunJob(job config.CronJob) {
 		targets = []config.CronTarget{{Provider: "local", ID: sid}}
 	}
 
+	// Notify conditions on the script output decide whether anything is sent at all
+	var decision notifyDecision
+	if job.Script != "" {
+		decision = s.checkNotify(ctx, job, run, output)
+		if decision.Resolved {
+			s.deliverAll(ctx, job, run, targets, resolvedMessage(job))
+			return
+		}
+		if !decision.Send {
+			return
+		}
+	}
+
 	// ACTION A: Script ONLY (No Prompt) -> Send Raw Output
 	if job.Script != "" && job.Prompt == "" {
-		if output == "" {
+		if output == "" && decision.Diff == "" {
 			return // Nothing to say
 		}
 		contextMsg := fmt.Sprintf("SYSTEM REPORT [%s]:\n%s", job.Name, output)
+		if decision.Diff != "" {
+			contextMsg = fmt.Sprintf("SYSTEM REPORT [%s]: %s\n```diff\n%s\n```", job.Name, decision.Reason, decision.Diff)
+		} else if decision.Reason != "" {
+			contextMsg = fmt.Sprintf("SYSTEM REPORT [%s]: %s\n%s", job.Name, decision.Reason, output)
+		}
 
 		for _, target := range targets {
-			s.sendToTarget(target, contextMsg)
+			run.Deliveries = append(run.Deliveries, s.deliver(ctx, job, run, target, contextMsg, ""))
 		}
 		return
 	}
@@ -139,9 +429,21 @@ func (s *Scheduler) RunJob(job config.CronJob) {
 	if job.Prompt != "" {
 		// If there is script output, attach it
 		contextMsg := output // can be empty if type is just "prompt"
-		finalPrompt := job.Prompt
+		finalPrompt, templated, err := renderPrompt(job.Prompt, ev)
+		if err != nil {
+			log.Printf("Job %s: %v", job.Name, err)
+			run.Error = err.Error()
+			return
+		}
+		if ev != nil && !templated && ev.Text != "" {
+			eventMsg := fmt.Sprintf("Event (%s):\n%s", ev.Trigger, ev.Text)
+			contextMsg = strings.TrimSpace(eventMsg + "\n\n" + contextMsg)
+		}
+		if decision.Diff != "" {
+			contextMsg = fmt.Sprintf("%s\n\nChanges since the last run:\n%s", contextMsg, decision.Diff)
+		}
 		if contextMsg != "" {
-			finalPrompt = fmt.Sprintf("Context:\n%s\n\nInstruction:\n%s", contextMsg, job.Prompt)
+			finalPrompt = fmt.Sprintf("Context:\n%s\n\nInstruction:\n%s", contextMsg, finalPrompt)
 		}
 
 		// STATEFUL EXECUTION (History Aware)
@@ -168,67 +470,134 @@ func (s *Scheduler) RunJob(job config.CronJob) {
 				// User wants "load all the context as usual when interacting with the agent".
 				// So `Agent.Run` is appropriate.
 				log.Printf("Running stateful cron for target %s (Session: %s)", target.ID, sessionID)
-				response, err := s.Agent.Run(sessionID, provider, target.ID, finalPrompt)
+				// Not retried: Agent.Run has already stored the prompt in the session by the time the LLM fails
+				response, err := s.Agent.Run(ctx, sessionID, provider, target.ID, finalPrompt)
 				if err != nil {
 					log.Printf("Error running stateful agent for target %s: %v", target.ID, err)
+					run.Deliveries = append(run.Deliveries, Delivery{Provider: target.Provider, ID: target.ID, Error: err.Error()})
 					continue
 				}
 
 				// Agent.Run does NOT send the message to the provider automatically (it returns the string).
 				// (Wait, `Run` returns string, but does it send? logic in `server.go` calls `Run` then sends result.)
 				// So we must send it here.
-				s.sendToTarget(target, response)
+				run.Deliveries = append(run.Deliveries, s.deliver(ctx, job, run, target, response, excerpt(response)))
 			}
 			return
 		}
 
 		// STATELESS EXECUTION (No History) - Default
 		// 1. Generate Response once (Stateless)
-		sysPrompt := s.Agent.GetBaseSystemPrompt()
+		var response string
+		if job.UseTools {
+			response, err = s.runEphemeral(ctx, job, run, targets[0], finalPrompt)
+		} else {
+			sysPrompt := s.Agent.GetBaseSystemPrompt()
 
-		messages := []llm.Message{
-			{Role: "system", Content: sysPrompt},
-			{Role: "user", Content: finalPrompt},
-		}
+			messages := []llm.Message{
+				{Role: "system", Content: sysPrompt},
+				{Role: "user", Content: finalPrompt},
+			}
 
-		response, _, err := s.Agent.LLM.Chat(messages, nil)
+			err = retry(ctx, job, run, "LLM call", func() error {
+				var err error
+				response, _, err = s.Agent.LLM.Chat(ctx, messages, nil)
+				return err
+			})
+		}
 		if err != nil {
 			log.Printf("Error running stateless cron job %s: %v", job.Name, err)
+			run.Error = err.Error()
 			return
 		}
+		run.Response = excerpt(response)
+
+		// Without a script, notify conditions apply to the response
+		if job.Script == "" && job.Notify != nil {
+			decision = s.checkNotify(ctx, job, run, response)
+			if decision.Resolved {
+				s.deliverAll(ctx, job, run, targets, resolvedMessage(job))
+				return
+			}
+			if !decision.Send {
+				return
+			}
+		}
 
 		// Send Agent Response to Targets
 		for _, target := range targets {
-			s.sendToTarget(target, response)
+			run.Deliveries = append(run.Deliveries, s.deliver(ctx, job, run, target, response, ""))
 		}
 		return
 	}
 }
 
-func (s *Scheduler) sendToTarget(target config.CronTarget, message string) {
+// runEphemeral runs prompt through the agent loop with tools in an ephemeral session. Tools
+// see the first target as the current chat, but its history is neither read nor written.
+func (s *Scheduler) runEphemeral(ctx context.Context, job config.CronJob, run *Run, target config.CronTarget, prompt string) (string, error) {
+	provider := s.Providers[target.Provider] // nil for local targets
+	input := fmt.Sprintf("[Scheduled task '%s'. Your final answer is sent to the user as a message.]\n\n%s", job.Name, prompt)
+
+	var response string
+	err := retry(ctx, job, run, "agent run", func() error {
+		var err error
+		response, err = s.Agent.RunEphemeral(ctx, provider, target.ID, input, job.LogSession)
+		return err
+	})
+	return response, err
+}
+
+func (s *Scheduler) deliverAll(ctx context.Context, job config.CronJob, run *Run, targets []config.CronTarget, message string) {
+	for _, target := range targets {
+		run.Deliveries = append(run.Deliveries, s.deliver(ctx, job, run, target, message, ""))
+	}
+}
+
+// deliver sends message to target, retrying per the job's policy, and reports the outcome for the run history.
+func (s *Scheduler) deliver(ctx context.Context, job config.CronJob, run *Run, target config.CronTarget, message, response string) Delivery {
+	d := Delivery{Provider: target.Provider, ID: target.ID, Response: response}
+	err := retry(ctx, job, run, "delivery to "+target.Provider, func() error {
+		return s.sendToTarget(job, target, message)
+	})
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+func (s *Scheduler) sendToTarget(job config.CronJob, target config.CronTarget, message string) error {
 	if target.Provider == "local" {
-		// Log to console/file? Agent.RunTask already logs to history if used.
-		// If raw output, maybe log it?
 		log.Printf("[LOCAL TARGET %s] %s", target.ID, message)
-	} else {
-		if provider, ok := s.Providers[target.Provider]; ok {
-			if err := provider.SendMessage(target.ID, message); err != nil {
-				log.Printf("Error sending message to %s via %s: %v", target.ID, target.Provider, err)
-			}
-		} else {
-			log.Printf("Unknown or uninitialized provider: %s", target.Provider)
+	}
+	if handled, err := s.builtinTarget(job, target, message); handled {
+		if err != nil {
+			log.Printf("Error sending message to %s target %s: %v", target.Provider, target.ID, err)
 		}
+		return err
+	}
+	provider, ok := s.Providers[target.Provider]
+	if !ok {
+		log.Printf("Unknown or uninitialized provider: %s", target.Provider)
+		return fmt.Errorf("unknown or uninitialized provider: %s", target.Provider)
 	}
+	if err := provider.SendMessage(target.ID, message); err != nil {
+		log.Printf("Error sending message to %s via %s: %v", target.ID, target.Provider, err)
+		return err
+	}
+	return nil
 }
 
-func executeScript(configDir string, scriptString string) (string, error) {
+// executeScript runs a job's script and returns its output and exit code (-1 if it could not start).
+// The process is killed when ctx ends. For triggered jobs, the event text is passed on stdin and
+// its type in YAOCC_TRIGGER.
+func executeScript(ctx context.Context, configDir string, scriptString string, ev *Event) (string, int, error) {
 	// 1. Parse potentially quoted arguments
 	// For simplicity, we'll try a basic split for now, but usually we need true shell parsing.
 	// Since we don't want to add big dependencies, let's assume standard space separation for now.
 	// If the user wants complex args, they might need to wrap in a shell script.
 	parts := strings.Fields(scriptString)
 	if len(parts) == 0 {
-		return "", fmt.Errorf("empty script command")
+		return "", -1, fmt.Errorf("empty script command")
 	}
 
 	command := parts[0]
@@ -251,35 +620,35 @@ func executeScript(configDir string, scriptString string) (string, error) {
 	if strings.HasSuffix(resolvedCommand, ".js") {
 		// Use node for .js files
 		nodeArgs := append([]string{resolvedCommand}, args...)
-		cmd = exec.Command("node", nodeArgs...)
+		cmd = exec.CommandContext(ctx, "node", nodeArgs...)
 		cmd.Dir = configDir
 	} else if strings.HasSuffix(resolvedCommand, ".py") {
 		// Use python for .py files
 		pyArgs := append([]string{resolvedCommand}, args...)
 		// Try "python" first, widely used alias
 		// If explicit "python3" is needed user might need to symlink or wrapper
-		cmd = exec.Command("python", pyArgs...)
+		cmd = exec.CommandContext(ctx, "python", pyArgs...)
 		cmd.Dir = configDir
 	} else if runtime.GOOS == "windows" {
 		// Windows Logic
 		if strings.HasSuffix(resolvedCommand, ".ps1") {
 			psArgs := append([]string{"-File", resolvedCommand}, args...)
-			cmd = exec.Command("powershell", psArgs...)
+			cmd = exec.CommandContext(ctx, "powershell", psArgs...)
 		} else if strings.HasSuffix(resolvedCommand, ".bat") {
 			batArgs := append([]string{"/C", resolvedCommand}, args...)
-			cmd = exec.Command("cmd", batArgs...)
+			cmd = exec.CommandContext(ctx, "cmd", batArgs...)
 		} else {
 			// Executable
-			cmd = exec.Command(resolvedCommand, args...)
+			cmd = exec.CommandContext(ctx, resolvedCommand, args...)
 		}
 		cmd.Dir = configDir // Run in config dir
 	} else {
 		// Unix Logic
 		if strings.HasSuffix(resolvedCommand, ".sh") {
 			shArgs := append([]string{resolvedCommand}, args...)
-			cmd = exec.Command("sh", shArgs...)
+			cmd = exec.CommandContext(ctx, "sh", shArgs...)
 		} else {
-			cmd = exec.Command(resolvedCommand, args...)
+			cmd = exec.CommandContext(ctx, resolvedCommand, args...)
 		}
 		cmd.Dir = configDir
 	}
@@ -288,11 +657,23 @@ func executeScript(configDir string, scriptString string) (string, error) {
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
+	if ev != nil {
+		cmd.Env = triggerEnv(ev)
+		cmd.Stdin = strings.NewReader(ev.Text)
+	}
+	killProcessGroup(cmd)
+	// Don't wait forever on children that inherited the output pipes after a kill
+	cmd.WaitDelay = 5 * time.Second
 
 	err := cmd.Run()
 	if err != nil {
-		return out.String(), fmt.Errorf("%v: %s", err, stderr.String())
+		exitCode := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		return out.String(), exitCode, fmt.Errorf("%v: %s", err, stderr.String())
 	}
 
-	return out.String(), nil
+	return out.String(), 0, nil
 }
@@ -0,0 +1,135 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/llm"
+)
+
+// defaultDigestPrompt is used for digest jobs without a prompt of their own.
+const defaultDigestPrompt = "Combine these reports into one short briefing for the user. Lead with anything that needs attention and skip sections with nothing noteworthy."
+
+// SourceRun is the outcome of one source of a digest run.
+type SourceRun struct {
+	Label      string `json:"label"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runDigest runs the sources of a digest job concurrently and returns their outputs as labeled
+// sections, in the configured order. Failed sources are included with their error so the
+// briefing can mention them; the run only fails if every source did.
+func (s *Scheduler) runDigest(ctx context.Context, job config.CronJob, run *Run) (string, error) {
+	outputs := make([]string, len(job.Digest))
+	results := make([]SourceRun, len(job.Digest))
+
+	var wg sync.WaitGroup
+	for i, src := range job.Digest {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			out, err := s.runSource(ctx, src)
+			results[i] = SourceRun{Label: s.sourceLabel(src), DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				log.Printf("Digest %s: source %s failed: %v", job.Name, results[i].Label, err)
+				results[i].Error = err.Error()
+			}
+			outputs[i] = strings.TrimSpace(out)
+		}()
+	}
+	wg.Wait()
+	run.Sources = results
+
+	var sb strings.Builder
+	failed := 0
+	for i, res := range results {
+		heading := "## " + res.Label
+		if res.Error != "" {
+			failed++
+			heading += fmt.Sprintf(" (failed: %s)", firstLine(res.Error))
+		}
+		body := outputs[i]
+		if body == "" {
+			body = "(no output)"
+		}
+		fmt.Fprintf(&sb, "%s\n%s\n\n", heading, body)
+	}
+	if failed == len(results) {
+		return "", fmt.Errorf("all %d digest sources failed", failed)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// runSource produces the output of one digest source without delivering it: a script's output,
+// or for a job, its script output or, if it has a prompt, the LLM response. Sources are not
+// retried; the digest job's timeout applies to all of them together.
+func (s *Scheduler) runSource(ctx context.Context, src config.CronDigestSource) (string, error) {
+	if src.Script != "" {
+		out, _, err := executeScript(ctx, s.ConfigDir, src.Script, nil)
+		return out, err
+	}
+
+	job, ok := s.FindJob(src.Job)
+	if !ok {
+		return "", fmt.Errorf("job '%s' not found", src.Job)
+	}
+	if job.IsDigest() {
+		return "", fmt.Errorf("job '%s' is a digest itself", job.Name)
+	}
+
+	var output string
+	if job.Script != "" {
+		out, _, err := executeScript(ctx, s.ConfigDir, job.Script, nil)
+		if err != nil || job.Prompt == "" {
+			return out, err
+		}
+		output = out
+	}
+
+	prompt := job.Prompt
+	if output != "" {
+		prompt = fmt.Sprintf("Context:\n%s\n\nInstruction:\n%s", output, job.Prompt)
+	}
+	if job.UseTools {
+		return s.Agent.RunEphemeral(ctx, nil, "", prompt, job.LogSession)
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: s.Agent.GetBaseSystemPrompt()},
+		{Role: "user", Content: prompt},
+	}
+	text, _, err := s.Agent.LLM.Chat(ctx, messages, nil)
+	return text, err
+}
+
+// sourceLabel names a source in the digest: its label, the job's name or the script's file name.
+func (s *Scheduler) sourceLabel(src config.CronDigestSource) string {
+	switch {
+	case src.Label != "":
+		return src.Label
+	case src.Job != "":
+		if job, ok := s.FindJob(src.Job); ok {
+			return job.Name
+		}
+		return src.Job
+	}
+	if fields := strings.Fields(src.Script); len(fields) > 0 {
+		return filepath.Base(fields[0])
+	}
+	return src.Script
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
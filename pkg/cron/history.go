@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	maxRunsPerJob = 100  // Older runs are dropped from the log
+	maxExcerpt    = 2000 // Characters kept of script output and responses
+)
+
+// Delivery is the result of sending a job's message to one target.
+type Delivery struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+	Response string `json:"response,omitempty"` // Per-target response of history-aware jobs
+	Error    string `json:"error,omitempty"`
+}
+
+// Run is one execution of a cron job.
+type Run struct {
+	JobID        string      `json:"jobId"`
+	Job          string      `json:"job"`
+	Trigger      string      `json:"trigger"`                // "schedule", "manual", "catch-up", "file", "webhook" or "message"
+	ScheduledFor *time.Time  `json:"scheduledFor,omitempty"` // When a caught-up run was originally due
+	Event        string      `json:"event,omitempty"`        // Excerpt of the event that fired a triggered job
+	Status       string      `json:"status"`                 // "success", "partial", "failed" or "skipped"
+	StartedAt    time.Time   `json:"startedAt"`
+	FinishedAt   time.Time   `json:"finishedAt"`
+	DurationMs   int64       `json:"durationMs"`
+	ExitCode     *int        `json:"exitCode,omitempty"` // Script exit code, if the job has a script
+	Output       string      `json:"output,omitempty"`   // Script output excerpt
+	Response     string      `json:"response,omitempty"` // LLM response excerpt
+	Error        string      `json:"error,omitempty"`
+	Retries      int         `json:"retries,omitempty"`      // Retried LLM calls and deliveries
+	Notification string      `json:"notification,omitempty"` // Outcome of the notify condition, if the job has one
+	Sources      []SourceRun `json:"sources,omitempty"`      // Sources of a digest job
+	Deliveries   []Delivery  `json:"deliveries,omitempty"`
+}
+
+// finish fills in the timing and derives the overall status.
+func (r *Run) finish(now time.Time) {
+	r.FinishedAt = now
+	r.DurationMs = now.Sub(r.StartedAt).Milliseconds()
+
+	failed := 0
+	for _, d := range r.Deliveries {
+		if d.Error != "" {
+			failed++
+		}
+	}
+	switch {
+	case r.Error != "" || (failed > 0 && failed == len(r.Deliveries)):
+		r.Status = "failed"
+	case failed > 0:
+		r.Status = "partial"
+	default:
+		r.Status = "success"
+	}
+}
+
+// History persists the runs of each job as JSON lines in <configDir>/cron/history/<job ID>.jsonl,
+// so renaming a job keeps its history.
+type History struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewHistory(configDir string) *History {
+	return &History{Dir: filepath.Join(configDir, "cron", "history")}
+}
+
+func (h *History) path(jobID string) string {
+	// Sanitize the ID to prevent path traversal
+	safe := filepath.Base(filepath.Clean(jobID))
+	if safe == "." || safe == "/" {
+		safe = "unnamed"
+	}
+	return filepath.Join(h.Dir, safe+".jsonl")
+}
+
+// Append records a run, keeping at most maxRunsPerJob entries per job.
+func (h *History) Append(run Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(h.Dir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	path := h.path(run.JobID)
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+	lines = append(lines, line)
+	if len(lines) > maxRunsPerJob {
+		lines = lines[len(lines)-maxRunsPerJob:]
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(bytes.Join(lines, []byte("\n")), '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Runs returns up to limit runs of the job, newest first. A limit <= 0 returns all of them.
+func (h *History) Runs(jobID string, limit int) ([]Run, error) {
+	h.mu.Lock()
+	lines, err := readLines(h.path(jobID))
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]Run, 0, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if limit > 0 && len(runs) >= limit {
+			break
+		}
+		var run Run
+		if err := json.Unmarshal(lines[i], &run); err != nil {
+			continue // Skip a line torn by a crash
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Last returns the most recent run of the job, or nil if it never ran.
+func (h *History) Last(jobID string) (*Run, error) {
+	runs, err := h.Runs(jobID, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func readLines(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, append([]byte(nil), line...))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run history %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// excerpt shortens s to maxExcerpt characters.
+func excerpt(s string) string {
+	r := []rune(s)
+	if len(r) <= maxExcerpt {
+		return s
+	}
+	return string(r[:maxExcerpt]) + "... [truncated]"
+}
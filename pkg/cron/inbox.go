@@ -0,0 +1,138 @@
+package cron
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/utils"
+)
+
+const maxInboxMessages = 500 // Older messages are dropped
+
+// InboxMessage is a result delivered to a "local" target.
+type InboxMessage struct {
+	Time    time.Time `json:"time"`
+	JobID   string    `json:"jobId,omitempty"`
+	Job     string    `json:"job,omitempty"`
+	Session string    `json:"session,omitempty"` // ID of the local target
+	Message string    `json:"message"`
+	Read    bool      `json:"read,omitempty"`
+}
+
+// Inbox keeps the messages of local targets as JSON lines in <configDir>/cron/inbox.jsonl.
+// The server appends to it and `yaocc inbox` reads it, so access is guarded by a lock file.
+type Inbox struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewInbox(configDir string) *Inbox {
+	return &Inbox{Path: filepath.Join(configDir, "cron", "inbox.jsonl")}
+}
+
+// Add appends a message, keeping at most maxInboxMessages.
+func (in *Inbox) Add(msg InboxMessage) error {
+	return in.update(func(msgs []InboxMessage) []InboxMessage {
+		msgs = append(msgs, msg)
+		if len(msgs) > maxInboxMessages {
+			msgs = msgs[len(msgs)-maxInboxMessages:]
+		}
+		return msgs
+	})
+}
+
+// Messages returns up to limit messages, oldest first. A limit <= 0 returns all of them.
+func (in *Inbox) Messages(unreadOnly bool, limit int) ([]InboxMessage, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	all, err := in.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []InboxMessage
+	for _, msg := range all {
+		if !unreadOnly || !msg.Read {
+			msgs = append(msgs, msg)
+		}
+	}
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[len(msgs)-limit:]
+	}
+	return msgs, nil
+}
+
+// MarkRead marks the shown messages, as returned by Messages, as read. Others stay unread, such
+// as older ones left out by a limit or ones that arrived since.
+func (in *Inbox) MarkRead(shown []InboxMessage) error {
+	return in.update(func(msgs []InboxMessage) []InboxMessage {
+		for i := range msgs {
+			for _, s := range shown {
+				if msgs[i].Time.Equal(s.Time) && msgs[i].Session == s.Session && msgs[i].JobID == s.JobID {
+					msgs[i].Read = true
+					break
+				}
+			}
+		}
+		return msgs
+	})
+}
+
+// Clear removes all messages.
+func (in *Inbox) Clear() error {
+	return in.update(func([]InboxMessage) []InboxMessage { return nil })
+}
+
+func (in *Inbox) update(fn func([]InboxMessage) []InboxMessage) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(in.Path), 0755); err != nil {
+		return err
+	}
+	unlock, err := utils.LockFile(in.Path, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	msgs, err := in.load()
+	if err != nil {
+		return err
+	}
+	msgs = fn(msgs)
+
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := in.Path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, in.Path)
+}
+
+func (in *Inbox) load() ([]InboxMessage, error) {
+	lines, err := readLines(in.Path)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]InboxMessage, 0, len(lines))
+	for _, line := range lines {
+		var msg InboxMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue // Skip a line torn by a crash
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
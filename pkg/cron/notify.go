@@ -0,0 +1,298 @@
+package cron
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/llm"
+)
+
+const (
+	maxStoredOutput = 64 * 1024 // Output kept for the diff of "change"
+	maxDiffLines    = 40
+)
+
+// NotifyState is what a job's notify condition remembers between runs.
+type NotifyState struct {
+	LastOutput string    `json:"lastOutput"`           // At most maxStoredOutput bytes
+	OutputHash string    `json:"outputHash,omitempty"` // Of the full normalized output, for "change"
+	Active     bool      `json:"active"`               // The condition held on the last run (for resolved messages)
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// LoadState returns the job's notify state, or nil before its first checked run.
+func (h *History) LoadState(jobID string) (*NotifyState, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := os.ReadFile(h.statePath(jobID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state NotifyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveState stores the job's notify state next to its run history.
+func (h *History) SaveState(jobID string, state NotifyState) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(h.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := h.statePath(jobID)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (h *History) statePath(jobID string) string {
+	return strings.TrimSuffix(h.path(jobID), ".jsonl") + ".state.json"
+}
+
+// notifyDecision is the outcome of checking a job's notify condition.
+type notifyDecision struct {
+	Send     bool   // Deliver the result
+	Resolved bool   // Send a resolved message instead
+	Reason   string // Why the condition holds, e.g. "exit code 2"
+	Diff     string // Output diff for "change"
+}
+
+// checkNotify evaluates the job's notify condition against subject (script output or LLM
+// response) and remembers the outcome for the next run.
+func (s *Scheduler) checkNotify(ctx context.Context, job config.CronJob, run *Run, subject string) notifyDecision {
+	n := job.Notify
+	if n == nil || n.On == "" || n.On == "always" {
+		return notifyDecision{Send: true}
+	}
+
+	prev, err := s.History.LoadState(run.JobID)
+	if err != nil {
+		log.Printf("Job %s: failed to load notify state: %v", job.Name, err)
+	}
+
+	var d notifyDecision
+	active := false
+	switch n.On {
+	case "failure":
+		if run.ExitCode != nil && *run.ExitCode != 0 {
+			active = true
+			d.Reason = fmt.Sprintf("exit code %d", *run.ExitCode)
+		}
+	case "change":
+		// The first run only records a baseline
+		if prev != nil && outputChanged(prev, subject) {
+			active = true
+			d.Reason = "output changed"
+			d.Diff = lineDiff(prev.LastOutput, truncateOutput(subject))
+			if d.Diff == "" {
+				d.Diff = fmt.Sprintf("(changes after the first %d KB of output)", maxStoredOutput/1024)
+			}
+		}
+	case "match":
+		if re, err := regexp.Compile(n.Pattern); err == nil && re.MatchString(subject) {
+			active = true
+			d.Reason = fmt.Sprintf("matched /%s/", n.Pattern)
+		}
+	case "judge":
+		held, reason, err := s.judge(ctx, job, run, n.Condition, subject)
+		if err != nil {
+			// Fail open: a missed alert is worse than an extra message
+			log.Printf("Job %s: failed to judge condition: %v", job.Name, err)
+			held, reason = true, "condition could not be checked: "+err.Error()
+		}
+		active = held
+		d.Reason = reason
+	}
+
+	state := NotifyState{
+		LastOutput: truncateOutput(subject),
+		OutputHash: hashOutput(subject),
+		Active:     active && n.On != "change",
+		CheckedAt:  time.Now(),
+	}
+	if err := s.History.SaveState(run.JobID, state); err != nil {
+		log.Printf("Job %s: failed to save notify state: %v", job.Name, err)
+	}
+
+	switch {
+	case active:
+		d.Send = true
+		run.Notification = "sent: " + d.Reason
+	case n.Resolved && prev != nil && prev.Active:
+		d.Resolved = true
+		run.Notification = "resolved"
+	default:
+		run.Notification = "suppressed: condition not met"
+	}
+	return d
+}
+
+// judge asks the LLM whether condition holds for output.
+func (s *Scheduler) judge(ctx context.Context, job config.CronJob, run *Run, condition, output string) (bool, string, error) {
+	if s.Agent == nil || s.Agent.LLM == nil {
+		return false, "", fmt.Errorf("no LLM configured")
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: "You check monitoring output against a condition. Reply with YES or NO on the first line, followed by one short sentence explaining why."},
+		{Role: "user", Content: fmt.Sprintf("Condition: %s\n\nOutput:\n%s", condition, excerpt(output))},
+	}
+
+	var reply string
+	err := retry(ctx, job, run, "condition check", func() error {
+		var err error
+		reply, _, err = s.Agent.LLM.Chat(ctx, messages, nil)
+		return err
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return parseJudgement(reply)
+}
+
+// parseJudgement reads a YES/NO verdict and the explanation that follows it.
+func parseJudgement(reply string) (bool, string, error) {
+	reply = strings.TrimSpace(reply)
+	first, rest, _ := strings.Cut(reply, "\n")
+	verdict := strings.ToUpper(strings.Trim(strings.TrimSpace(first), "*.:!"))
+	reason := strings.TrimSpace(rest)
+
+	switch {
+	case strings.HasPrefix(verdict, "YES"):
+		if reason == "" {
+			reason = "condition met"
+		}
+		return true, reason, nil
+	case strings.HasPrefix(verdict, "NO"):
+		return false, reason, nil
+	}
+	return false, "", fmt.Errorf("unexpected verdict: %q", first)
+}
+
+// outputChanged compares subject with the output of the previous run. States saved before
+// OutputHash existed only have the stored, possibly truncated output to compare with.
+func outputChanged(prev *NotifyState, subject string) bool {
+	if prev.OutputHash != "" {
+		return prev.OutputHash != hashOutput(subject)
+	}
+	return normalizeOutput(prev.LastOutput) != normalizeOutput(truncateOutput(subject))
+}
+
+func hashOutput(s string) string {
+	sum := sha256.Sum256([]byte(normalizeOutput(s)))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateOutput cuts s to maxStoredOutput bytes without splitting a UTF-8 character.
+func truncateOutput(s string) string {
+	if len(s) <= maxStoredOutput {
+		return s
+	}
+	i := maxStoredOutput
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i]
+}
+
+func normalizeOutput(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineDiff returns a unified-style diff of the lines that differ between old and new.
+func lineDiff(old, new string) string {
+	a := strings.Split(normalizeOutput(old), "\n")
+	b := strings.Split(normalizeOutput(new), "\n")
+	if len(a)*len(b) > 1000000 {
+		// Too large to diff cheaply, show the new output instead
+		return truncateLines(prefixLines("+ ", b))
+	}
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out = append(out, "+ "+b[j])
+			j++
+		default:
+			out = append(out, "- "+a[i])
+			i++
+		}
+	}
+
+	return truncateLines(out)
+}
+
+func prefixLines(prefix string, lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = prefix + l
+	}
+	return out
+}
+
+func truncateLines(lines []string) string {
+	if len(lines) > maxDiffLines {
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("... (%d more changed lines)", len(lines)-maxDiffLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolvedMessage announces that a job's condition no longer holds.
+func resolvedMessage(job config.CronJob) string {
+	what := "the condition"
+	switch job.Notify.On {
+	case "failure":
+		what = "the script failure"
+	case "match":
+		what = fmt.Sprintf("/%s/", job.Notify.Pattern)
+	case "judge":
+		what = fmt.Sprintf("%q", job.Notify.Condition)
+	}
+	return fmt.Sprintf("RESOLVED [%s]: %s no longer applies.", job.Name, what)
+}
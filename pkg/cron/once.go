@@ -0,0 +1,106 @@
+package cron
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/robfig/cron/v3"
+)
+
+// onceSchedule fires a single time. Once it has passed, Next returns the zero time,
+// which the cron runner never fires.
+type onceSchedule struct {
+	at time.Time
+}
+
+func (o onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(o.at) {
+		return o.at
+	}
+	return time.Time{}
+}
+
+// scheduleOnce schedules a one-shot job, removing it from the config after it fired. A job
+// whose time passed while the server was down runs right away, unless it already ran.
+func (s *Scheduler) scheduleOnce(job config.CronJob) {
+	at, err := time.Parse(time.RFC3339, job.At)
+	if err != nil {
+		log.Printf("Error scheduling job %s: invalid 'at' time '%s'", job.Name, job.At)
+		return
+	}
+	id := job.JobID()
+
+	fire := func() {
+		s.runJob(job, "schedule")
+		s.removeOneShot(job)
+	}
+
+	if !at.After(time.Now()) {
+		if s.state(id).running.Load() > 0 {
+			return // Firing right now, e.g. during a reload
+		}
+		if last, err := s.History.Last(id); err == nil && last != nil && !last.StartedAt.Before(at) {
+			go s.removeOneShot(job) // Already ran, only the removal is missing
+			return
+		}
+		log.Printf("One-shot job %s was due at %s, running it now", job.Name, at.Format(time.RFC3339))
+		go fire()
+		return
+	}
+
+	entry := s.Cron.Schedule(onceSchedule{at: at}, cron.FuncJob(fire))
+	s.mu.Lock()
+	s.entries[id] = entry
+	s.mu.Unlock()
+	log.Printf("Scheduled one-shot job: %s (at %s)", job.Name, job.At)
+}
+
+// removeOneShot deletes a fired one-shot job from the config file. The config watcher then
+// reloads the scheduler, so the lock is not taken.
+func (s *Scheduler) removeOneShot(job config.CronJob) {
+	id := job.JobID()
+	s.mu.Lock()
+	if entry, ok := s.entries[id]; ok {
+		s.Cron.Remove(entry)
+		delete(s.entries, id)
+	}
+	s.mu.Unlock()
+
+	if s.ConfigPath == "" {
+		return
+	}
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	err := config.UpdateConfigRawWithPath(s.ConfigPath, func(cfg *config.Config) error {
+		if idx := config.FindCronJob(cfg.Cron, id); idx >= 0 && cfg.Cron[idx].IsOneShot() {
+			cfg.Cron = append(cfg.Cron[:idx], cfg.Cron[idx+1:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error removing one-shot job %s: %v", job.Name, err)
+		return
+	}
+	log.Printf("Removed one-shot job: %s", job.Name)
+}
+
+// NextJobFire computes when job fires next after the given time: its 'at' time for one-shot
+// jobs, otherwise the next time of its schedule in timezone. Triggered jobs have no fire time.
+func NextJobFire(job config.CronJob, timezone string, after time.Time) (time.Time, error) {
+	if job.IsTriggered() {
+		return time.Time{}, fmt.Errorf("runs on %s events", job.Trigger.Type)
+	}
+	if !job.IsOneShot() {
+		return NextFire(job.Schedule, timezone, after)
+	}
+	at, err := time.Parse(time.RFC3339, job.At)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !at.After(after) {
+		return time.Time{}, fmt.Errorf("already due")
+	}
+	return at, nil
+}
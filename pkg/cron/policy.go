@@ -0,0 +1,70 @@
+package cron
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+const defaultBackoff = 2 * time.Second
+
+// jobState tracks the active runs of one job across reloads.
+type jobState struct {
+	running atomic.Int32
+	queue   sync.Mutex // Serializes runs of jobs with concurrency "queue"
+}
+
+func (s *Scheduler) state(jobID string) *jobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[jobID]
+	if !ok {
+		st = &jobState{}
+		s.states[jobID] = st
+	}
+	return st
+}
+
+// newSlots returns the semaphore enforcing CronMaxConcurrent, or nil if unlimited.
+func newSlots(cfg *config.Config) chan struct{} {
+	if cfg.CronMaxConcurrent <= 0 {
+		return nil
+	}
+	return make(chan struct{}, cfg.CronMaxConcurrent)
+}
+
+// jobTimeout returns the job's maximum run time, or 0 if it may run as long as it takes.
+func jobTimeout(job config.CronJob) time.Duration {
+	if job.TimeoutMs > 0 {
+		return time.Duration(job.TimeoutMs) * time.Millisecond
+	}
+	return 0
+}
+
+// retry calls fn until it succeeds, the job's retries are used up or ctx ends,
+// waiting with exponential backoff in between. Retries are counted in run.
+func retry(ctx context.Context, job config.CronJob, run *Run, what string, fn func() error) error {
+	backoff := defaultBackoff
+	if job.BackoffMs > 0 {
+		backoff = time.Duration(job.BackoffMs) * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= job.Retries || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Job %s: %s failed (%v), retrying in %s", job.Name, what, err, backoff)
+		run.Retries++
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package cron
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup makes cancellation kill the script together with any children
+// it started, so a timed-out `sh script.sh` does not leave a `sleep` holding its output open.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
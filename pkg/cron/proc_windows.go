@@ -0,0 +1,8 @@
+//go:build windows
+
+package cron
+
+import "os/exec"
+
+// killProcessGroup is a no-op on Windows; cancellation kills the direct process only.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,208 @@
+package cron
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+	"github.com/dev-dhg/yaocc/pkg/utils"
+)
+
+const targetTimeout = 30 * time.Second
+
+// builtinTarget sends message to one of the target kinds that are not messaging providers.
+// handled is false for other kinds.
+func (s *Scheduler) builtinTarget(job config.CronJob, target config.CronTarget, message string) (handled bool, err error) {
+	switch target.Provider {
+	case "local":
+		return true, s.Inbox.Add(InboxMessage{Time: time.Now(), JobID: job.JobID(), Job: job.Name, Session: target.ID, Message: message})
+	case "file":
+		return true, s.appendToFile(job, target, message)
+	case "webhook":
+		return true, s.postWebhook(job, target, message)
+	case "email":
+		return true, s.sendEmail(job, target, message)
+	}
+	return false, nil
+}
+
+// appendToFile appends message under a timestamped heading to the target's file, relative to
+// the config dir; paths leading outside of it are refused. {date} (2006-01-02), {month} (2006-01) and {job} in the path are filled in.
+func (s *Scheduler) appendToFile(job config.CronJob, target config.CronTarget, message string) error {
+	now := time.Now().In(s.Cron.Location())
+	name := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{month}", now.Format("2006-01"),
+		"{job}", safeFileName(job.Name),
+	).Replace(target.ID)
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("file target needs a path")
+	}
+	if !config.IsWorkspacePath(name) {
+		return fmt.Errorf("file target '%s' is outside the config dir", name)
+	}
+	path, err := utils.ResolveSafePath(s.ConfigDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "## %s %s\n\n%s\n\n", now.Format("2006-01-02 15:04"), job.Name, strings.TrimSpace(message))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// WebhookMessage is the JSON body posted to webhook targets.
+type WebhookMessage struct {
+	JobID   string    `json:"jobId"`
+	Job     string    `json:"job"`
+	Message string    `json:"message"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// postWebhook posts the message as JSON to the target URL, subject to the egress policy. With a
+// secret, the body is signed in X-Hub-Signature-256 the same way inbound webhooks are checked.
+func (s *Scheduler) postWebhook(job config.CronJob, target config.CronTarget, message string) error {
+	body, err := json.Marshal(WebhookMessage{JobID: job.JobID(), Job: job.Name, Message: message, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, target.ID, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "yaocc-cron")
+	if target.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(target.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	policy, err := netguard.NewPolicy(s.Config.Egress)
+	if err != nil {
+		return fmt.Errorf("invalid egress policy: %w", err)
+	}
+	resp, err := netguard.HTTPClient(policy, targetTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// sendEmail mails message to the comma-separated addresses of the target through the configured
+// SMTP server. Port 465 uses implicit TLS; other ports upgrade with STARTTLS when offered.
+func (s *Scheduler) sendEmail(job config.CronJob, target config.CronTarget, message string) error {
+	cfg := s.Config.SMTP
+	if cfg.Host == "" {
+		return fmt.Errorf("email target requires smtp.host in the config")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	var to []string
+	for _, addr := range strings.Split(target.ID, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if from == "" || len(to) == 0 {
+		return fmt.Errorf("email target needs a sender (smtp.from) and a recipient")
+	}
+
+	var msg bytes.Buffer
+	header := strings.NewReplacer("\r", " ", "\n", " ") // No header injection through names or addresses
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: [yaocc] %s\r\nDate: %s\r\n",
+		header.Replace(from), header.Replace(strings.Join(to, ", ")), header.Replace(job.Name), time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	if port != 465 {
+		return smtp.SendMail(addr, auth, from, to, msg.Bytes())
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: targetTimeout}, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
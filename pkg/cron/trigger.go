@@ -0,0 +1,302 @@
+package cron
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/utils"
+)
+
+const (
+	filePollInterval     = time.Second
+	defaultFileDebounce  = 2 * time.Second
+	maxWatchedFiles      = 10000   // Larger trees are only watched partially
+	MaxWebhookBody       = 1 << 20 // Bytes of a webhook payload that are accepted
+	maxEventChangesShown = 50      // Changed files listed in a file event's text
+)
+
+// Event is what fired a triggered job. Its Text is given to the prompt as context and to
+// scripts on stdin; Data is available to prompt templates.
+type Event struct {
+	Trigger string             // "file", "webhook" or "message"
+	Text    string             // Changed files, webhook payload or message text
+	Data    map[string]any     // Template data, see the event constructors
+	Reply   *config.CronTarget // Chat a message came from; used when the job has no targets
+}
+
+// RunEvent runs a triggered job for ev and records the run in the history.
+func (s *Scheduler) RunEvent(job config.CronJob, ev Event) Run {
+	return s.runJobWith(job, Run{Trigger: ev.Trigger, Event: excerpt(ev.Text)}, &ev)
+}
+
+// WebhookEvent builds the event of an inbound webhook. Templates get the raw body as
+// .body, the decoded JSON payload (or the body if it is not JSON) as .payload and the
+// query parameters as .query.
+func WebhookEvent(body []byte, query url.Values) Event {
+	var payload any = string(body)
+	var decoded any
+	if json.Unmarshal(body, &decoded) == nil {
+		payload = decoded
+	}
+	params := make(map[string]string, len(query))
+	for k := range query {
+		params[k] = query.Get(k)
+	}
+	delete(params, "secret")
+
+	return Event{
+		Trigger: "webhook",
+		Text:    string(body),
+		Data:    map[string]any{"body": string(body), "payload": payload, "query": params},
+	}
+}
+
+// VerifyWebhook checks the caller of a webhook against the trigger's secret. Callers send the
+// secret in the X-Hook-Secret header or the secret query parameter, or sign the body with it
+// in X-Hub-Signature-256 (sha256=<hex HMAC>) like GitHub does. Without a secret nothing is
+// accepted here; such hooks are authenticated with an API token by the server instead.
+func VerifyWebhook(t config.CronTrigger, body []byte, header http.Header, query url.Values) bool {
+	if t.Secret == "" {
+		return false
+	}
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		mac := hmac.New(sha256.New, []byte(t.Secret))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(sig), []byte(want))
+	}
+	given := header.Get("X-Hook-Secret")
+	if given == "" {
+		given = query.Get("secret")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(t.Secret)) == 1
+}
+
+// MatchMessage runs every enabled message-triggered job whose pattern matches text, a message
+// received from chatID on provider. Jobs run in the background; the number started is returned.
+// Templates get .message, .provider, .chatId and the pattern's submatches as .match.
+func (s *Scheduler) MatchMessage(provider, chatID, text string) int {
+	s.mu.Lock()
+	jobs := s.Config.Cron
+	s.mu.Unlock()
+
+	started := 0
+	for _, job := range jobs {
+		if !job.IsEnabled() || job.Trigger == nil || job.Trigger.Type != "message" {
+			continue
+		}
+		re, err := regexp.Compile(job.Trigger.Pattern)
+		if err != nil {
+			continue
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		log.Printf("Message from %s/%s matched trigger of job %s", provider, chatID, job.Name)
+		ev := Event{
+			Trigger: "message",
+			Text:    text,
+			Data:    map[string]any{"message": text, "provider": provider, "chatId": chatID, "match": match},
+			Reply:   &config.CronTarget{Provider: provider, ID: chatID},
+		}
+		go s.RunEvent(job, ev)
+		started++
+	}
+	return started
+}
+
+// renderPrompt fills the event into the job's prompt. Prompts with template actions get the
+// event's data (plus .trigger and .text); other prompts get the event text as context instead,
+// which is reported as false.
+func renderPrompt(prompt string, ev *Event) (string, bool, error) {
+	if ev == nil || !strings.Contains(prompt, "{{") {
+		return prompt, false, nil
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=zero").Parse(prompt)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	data := map[string]any{"trigger": ev.Trigger, "text": ev.Text}
+	for k, v := range ev.Data {
+		data[k] = v
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", false, fmt.Errorf("rendering prompt template: %w", err)
+	}
+	return buf.String(), true, nil
+}
+
+// fileState is what a file watch compares between polls.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchFiles polls the path of a file-triggered job until quit is closed and runs the job once
+// changes have settled for the trigger's debounce time. Templates get the changed paths as
+// .files and "created", "modified" or "deleted" per path as .changes.
+func (s *Scheduler) watchFiles(job config.CronJob, quit <-chan struct{}) {
+	if !config.IsWorkspacePath(job.Trigger.Path) {
+		log.Printf("Job %s: file trigger path '%s' is outside the config dir, not watching", job.Name, job.Trigger.Path)
+		return
+	}
+	root, err := utils.ResolveSafePath(s.ConfigDir, job.Trigger.Path)
+	if err != nil {
+		log.Printf("Job %s: not watching %s: %v", job.Name, job.Trigger.Path, err)
+		return
+	}
+	debounce := defaultFileDebounce
+	if job.Trigger.DebounceMs > 0 {
+		debounce = time.Duration(job.Trigger.DebounceMs) * time.Millisecond
+	}
+
+	prev := s.snapshot(root, job.Trigger.Pattern)
+	pending := make(map[string]string) // Path -> change since the last run
+	var lastChange time.Time
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+
+		cur := s.snapshot(root, job.Trigger.Pattern)
+		if changes := diffSnapshots(prev, cur); len(changes) > 0 {
+			for path, change := range changes {
+				if pending[path] == "created" && change == "modified" {
+					continue
+				}
+				pending[path] = change
+			}
+			lastChange = time.Now()
+		}
+		prev = cur
+
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+		ev := fileEvent(root, pending)
+		pending = make(map[string]string)
+		log.Printf("Files changed for job %s: %d", job.Name, len(ev.Data["files"].([]string)))
+		s.RunEvent(job, ev)
+	}
+}
+
+// snapshot records the files under root whose names match pattern (all if empty). Hidden
+// files and the scheduler's own history are left out so a job cannot trigger itself.
+func (s *Scheduler) snapshot(root, pattern string) map[string]fileState {
+	files := make(map[string]fileState)
+	historyDir := ""
+	if s.History != nil {
+		historyDir, _ = filepath.Abs(s.History.Dir) // root is absolute too
+	}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Vanished or unreadable; deletions show up in the diff
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || filepath.Clean(path) == historyDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, d.Name()); !ok {
+				return nil
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		if len(files) >= maxWatchedFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return files
+}
+
+func diffSnapshots(prev, cur map[string]fileState) map[string]string {
+	changes := make(map[string]string)
+	for path, st := range cur {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			changes[path] = "created"
+		case !old.modTime.Equal(st.modTime) || old.size != st.size:
+			changes[path] = "modified"
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			changes[path] = "deleted"
+		}
+	}
+	return changes
+}
+
+// fileEvent describes pending changes with paths relative to the watched root.
+func fileEvent(root string, pending map[string]string) Event {
+	files := make([]string, 0, len(pending))
+	changes := make(map[string]string, len(pending))
+	for path, change := range pending {
+		rel := path
+		if r, err := filepath.Rel(root, path); err == nil && r != "." {
+			rel = filepath.ToSlash(r)
+		} else {
+			rel = filepath.Base(path)
+		}
+		files = append(files, rel)
+		changes[rel] = change
+	}
+	sort.Strings(files)
+
+	var text strings.Builder
+	for i, f := range files {
+		if i == maxEventChangesShown {
+			fmt.Fprintf(&text, "... and %d more\n", len(files)-i)
+			break
+		}
+		fmt.Fprintf(&text, "%s: %s\n", changes[f], f)
+	}
+	return Event{
+		Trigger: "file",
+		Text:    strings.TrimSpace(text.String()),
+		Data:    map[string]any{"files": files, "changes": changes},
+	}
+}
+
+// triggerEnv passes the event to a job's script.
+func triggerEnv(ev *Event) []string {
+	if ev == nil {
+		return nil
+	}
+	return append(os.Environ(), "YAOCC_TRIGGER="+ev.Trigger)
+}
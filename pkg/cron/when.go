@@ -0,0 +1,473 @@
+package cron
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHour = 9 // Time of day for schedules that name a day but no time
+
+// When is a parsed schedule: either a recurring cron spec or a single point in time.
+type When struct {
+	Schedule string
+	At       time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// Parts of the day and the hour they stand for
+var dayPeriods = map[string]int{"morning": 9, "afternoon": 15, "evening": 18, "night": 21, "tonight": 21}
+
+// Shorthands rewritten to "every ..." before parsing
+var recurringAliases = [][2]string{
+	{"hourly", "every hour"}, {"daily", "every day"}, {"weekly on", "every"}, {"monthly on", "every month on"},
+	{"on weekdays", "every weekday"}, {"weekdays", "every weekday"}, {"on weekends", "every weekend"}, {"weekends", "every weekend"},
+}
+
+var (
+	reCompactDuration = regexp.MustCompile(`^(?:\d+(?:\.\d+)?[smhdw])+$`)
+	reDurationPart    = regexp.MustCompile(`(\d+(?:\.\d+)?)([smhdw])`)
+	reMeridiem        = regexp.MustCompile(`(\d) ?(am|pm)\b`)
+	reClock           = regexp.MustCompile(`^(\d{1,2})(?:[:.h](\d{2}))?(am|pm)?$`)
+	reOrdinal         = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+// ParseAt parses the time of a one-shot job: an RFC3339 timestamp, a local date and time
+// ("2026-01-02 15:04") in loc, or a duration from now ("30m", "+2h", "1h30m", "2 hours").
+func ParseAt(text string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	if t, ok := parseTimestamp(text, loc); ok {
+		return future(t, now)
+	}
+
+	s := strings.TrimPrefix(strings.TrimPrefix(normalizeWhen(text), "in "), "+")
+	if d, err := parseDuration(s); err == nil {
+		return now.Add(d).In(loc).Truncate(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s' (use RFC3339 like 2026-01-02T15:04:05+01:00, '2026-01-02 15:04' or a duration like 30m)", text)
+}
+
+// ParseWhen parses a schedule written in plain English. Recurring schedules ("every weekday
+// at 8:30", "every 15 minutes") become cron specs, which the scheduler reads in the configured
+// timezone; one-off ones ("tomorrow at 9", "in 2 hours", "friday 6pm") become a time in loc.
+// Days without a time default to 9:00.
+func ParseWhen(text string, now time.Time, loc *time.Location) (When, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+
+	if t, ok := parseTimestamp(text, loc); ok {
+		at, err := future(t, now)
+		return When{At: at}, err
+	}
+	if at, err := ParseAt(text, now, loc); err == nil {
+		return When{At: at}, nil
+	}
+
+	s := normalizeWhen(text)
+	for _, alias := range recurringAliases {
+		if s == alias[0] || strings.HasPrefix(s, alias[0]+" ") {
+			s = alias[1] + strings.TrimPrefix(s, alias[0])
+			break
+		}
+	}
+
+	var err error
+	var w When
+	if rest, ok := strings.CutPrefix(s, "every "); ok {
+		w.Schedule, err = parseRecurring(rest)
+	} else if rest, ok := strings.CutPrefix(s, "each "); ok {
+		w.Schedule, err = parseRecurring(rest)
+	} else {
+		w.At, err = parseOneShot(s, now, loc)
+	}
+	if err != nil {
+		return When{}, fmt.Errorf("cannot understand '%s': %w", text, err)
+	}
+	return w, nil
+}
+
+// normalizeWhen lowercases text and strips the filler around a schedule.
+func normalizeWhen(text string) string {
+	s := strings.ToLower(strings.TrimSpace(text))
+	s = strings.NewReplacer(",", " ", "a.m.", "am", "p.m.", "pm", "o'clock", "", "remind me ", "").Replace(s)
+	s = strings.TrimRight(s, ".!")
+	s = reMeridiem.ReplaceAllString(s, "${1}${2}")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// parseTimestamp reads an RFC3339 timestamp or a date and time in loc.
+func parseTimestamp(s string, loc *time.Location) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func future(t, now time.Time) (time.Time, error) {
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("%s is in the past", t.Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+// parseDuration reads compact ("1h30m", "2d") or spelled-out ("2 hours and 30 minutes",
+// "an hour", "half an hour") durations.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.ReplaceAll(s, "half an hour", "30 minutes")
+	if reCompactDuration.MatchString(s) {
+		s = reDurationPart.ReplaceAllString(s, "$1 $2 ")
+	}
+
+	fields := strings.Fields(s)
+	var total time.Duration
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "and" {
+			continue
+		}
+		var n float64
+		switch fields[i] {
+		case "a", "an", "one":
+			n = 1
+		default:
+			var err error
+			if n, err = strconv.ParseFloat(fields[i], 64); err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+		}
+		if i+1 >= len(fields) {
+			return 0, fmt.Errorf("missing unit in '%s'", s)
+		}
+		i++
+		unit, ok := durationUnits[fields[i]]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit '%s'", fields[i])
+		}
+		total += time.Duration(n * float64(unit))
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return total, nil
+}
+
+// parseRecurring turns what follows "every" into a cron spec.
+func parseRecurring(s string) (string, error) {
+	// Intervals: "every 15 minutes", "every hour", "every 90 minutes"
+	if d, err := parseDuration(s); err == nil {
+		return intervalSpec(d)
+	}
+	if unit, ok := durationUnits[s]; ok && unit < 24*time.Hour {
+		return intervalSpec(unit)
+	}
+
+	days, clock, _ := strings.Cut(s, " at ")
+	if after, ok := strings.CutPrefix(s, "at "); ok {
+		days, clock = "day", after
+	}
+	hour, minute, err := parseClockWithPeriod(&days, clock)
+	if err != nil {
+		return "", err
+	}
+
+	dom, dow := "*", "*"
+	switch days {
+	case "", "day":
+	case "weekday", "weekdays", "workday", "workdays":
+		dow = "1-5"
+	case "weekend", "weekends":
+		dow = "6,0"
+	default:
+		if rest, ok := strings.CutPrefix(days, "month on "); ok {
+			rest = strings.TrimPrefix(strings.TrimPrefix(rest, "the "), "day ")
+			m := reOrdinal.FindStringSubmatch(rest)
+			if m == nil {
+				return "", fmt.Errorf("unknown day of the month '%s'", rest)
+			}
+			n, _ := strconv.Atoi(m[1])
+			if n < 1 || n > 31 {
+				return "", fmt.Errorf("invalid day of the month %d", n)
+			}
+			dom = strconv.Itoa(n)
+			break
+		}
+		days = strings.TrimPrefix(days, "week on ")
+		var list []string
+		for _, f := range strings.Fields(days) {
+			if f == "and" {
+				continue
+			}
+			day, ok := weekdays[strings.TrimSuffix(f, "s")]
+			if !ok {
+				day, ok = weekdays[f]
+			}
+			if !ok {
+				return "", fmt.Errorf("unknown day '%s'", f)
+			}
+			list = append(list, strconv.Itoa(int(day)))
+		}
+		if len(list) == 0 {
+			return "", fmt.Errorf("missing day")
+		}
+		dow = strings.Join(list, ",")
+	}
+	return fmt.Sprintf("%d %d %s * %s", minute, hour, dom, dow), nil
+}
+
+// intervalSpec returns a cron spec for a fixed interval, aligned to the clock where possible.
+func intervalSpec(d time.Duration) (string, error) {
+	switch {
+	case d < time.Minute:
+		return "", fmt.Errorf("intervals shorter than a minute are not supported")
+	case d == time.Minute:
+		return "* * * * *", nil
+	case d < time.Hour && d%time.Minute == 0 && 60%int(d.Minutes()) == 0:
+		return fmt.Sprintf("*/%d * * * *", int(d.Minutes())), nil
+	case d == time.Hour:
+		return "0 * * * *", nil
+	case d < 24*time.Hour && d%time.Hour == 0 && 24%int(d.Hours()) == 0:
+		return fmt.Sprintf("0 */%d * * *", int(d.Hours())), nil
+	case d >= 24*time.Hour:
+		return "", fmt.Errorf("say which day and time instead, e.g. 'every monday at 9'")
+	}
+	spec := strings.TrimSuffix(d.String(), "0s") // "1h30m0s" -> "1h30m"
+	if strings.HasSuffix(spec, "h0m") {
+		spec = strings.TrimSuffix(spec, "0m")
+	}
+	return "@every " + spec, nil
+}
+
+// parseOneShot resolves a single point in time like "tomorrow at 9" or "friday 6pm".
+func parseOneShot(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		d, err := parseDuration(rest)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d).Truncate(time.Second), nil
+	}
+
+	day, clock := splitClock(s)
+	hasClock := clock != ""
+	hour, minute, err := parseClockWithPeriod(&day, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	date := today
+	rollOver := false // Move to the next day if the time has passed today
+	day = strings.TrimPrefix(day, "on ")
+
+	switch {
+	case day == "" || day == "today":
+		rollOver = day == "" && hasClock
+		if !hasClock && day == "" {
+			return time.Time{}, fmt.Errorf("missing day or time")
+		}
+	case day == "tomorrow":
+		date = today.AddDate(0, 0, 1)
+	case day == "day after tomorrow" || day == "the day after tomorrow":
+		date = today.AddDate(0, 0, 2)
+	default:
+		var ok bool
+		if date, ok = parseWeekday(day, today); !ok {
+			if date, ok = parseDate(day, today, loc); !ok {
+				return time.Time{}, fmt.Errorf("unknown day '%s'", day)
+			}
+		}
+	}
+
+	at := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc)
+	if rollOver && !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return future(at, now)
+}
+
+// splitClock separates the time of day from the day in "tomorrow at 9", "at 9am tomorrow"
+// and "friday 6pm".
+func splitClock(s string) (day, clock string) {
+	if rest, ok := strings.CutPrefix(s, "at "); ok {
+		clock, day, _ = strings.Cut(rest, " ")
+		return day, clock
+	}
+	if i := strings.LastIndex(s, " at "); i >= 0 {
+		return s[:i], s[i+len(" at "):]
+	}
+	if i := strings.LastIndex(s, " "); i >= 0 && looksLikeClock(s[i+1:]) {
+		return s[:i], s[i+1:]
+	}
+	if looksLikeClock(s) {
+		return "", s
+	}
+	return s, ""
+}
+
+// looksLikeClock reports whether s is a time of day even without a preceding "at".
+func looksLikeClock(s string) bool {
+	if s == "noon" || s == "midnight" {
+		return true
+	}
+	return reClock.MatchString(s) && (strings.Contains(s, ":") || strings.HasSuffix(s, "m"))
+}
+
+// parseClockWithPeriod parses clock, taking a part of the day ("tomorrow morning") off *day.
+// Without a clock the period's hour is used, or defaultHour.
+func parseClockWithPeriod(day *string, clock string) (int, int, error) {
+	period := ""
+	if *day == "tonight" {
+		period, *day = "tonight", "today"
+	} else if i := strings.LastIndex(*day, " "); i >= 0 {
+		if _, ok := dayPeriods[(*day)[i+1:]]; ok {
+			period, *day = (*day)[i+1:], (*day)[:i]
+		}
+	} else if _, ok := dayPeriods[*day]; ok {
+		period, *day = *day, ""
+	}
+	if *day == "this" {
+		*day = "today" // "this evening"
+	}
+
+	if clock == "" {
+		if period != "" {
+			return dayPeriods[period], 0, nil
+		}
+		return defaultHour, 0, nil
+	}
+
+	hour, minute, meridiem, err := parseClock(clock)
+	if err != nil {
+		return 0, 0, err
+	}
+	// "tonight at 8" means 20:00
+	if meridiem == "" && hour < 12 && dayPeriods[period] >= 15 {
+		hour += 12
+	}
+	return hour, minute, nil
+}
+
+// parseClock reads "9", "9am", "9:30", "21:00", "noon" or "midnight".
+func parseClock(s string) (hour, minute int, meridiem string, err error) {
+	switch s {
+	case "noon":
+		return 12, 0, "pm", nil
+	case "midnight":
+		return 0, 0, "am", nil
+	}
+	m := reClock.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, "", fmt.Errorf("unknown time '%s'", s)
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	meridiem = m[3]
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, "", fmt.Errorf("invalid time '%s'", s)
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, "", fmt.Errorf("invalid time '%s'", s)
+	}
+	return hour, minute, meridiem, nil
+}
+
+// parseWeekday resolves "monday", "next monday" or "this monday" to the next such day after today.
+func parseWeekday(s string, today time.Time) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "next "), "this ")
+	day, ok := weekdays[s]
+	if !ok {
+		return time.Time{}, false
+	}
+	ahead := (int(day) - int(today.Weekday()) + 7) % 7
+	if ahead == 0 {
+		ahead = 7
+	}
+	return today.AddDate(0, 0, ahead), true
+}
+
+// parseDate resolves "2026-10-20", "oct 20", "october 20th" or "20 october", in the
+// current year unless that date has passed.
+func parseDate(s string, today time.Time, loc *time.Location) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(s, "the "))
+	if len(fields) == 3 && fields[1] == "of" {
+		fields = []string{fields[0], fields[2]}
+	}
+	if len(fields) != 2 {
+		return time.Time{}, false
+	}
+	month, ok := months[fields[0]]
+	dayField := fields[1]
+	if !ok {
+		month, ok = months[fields[1]]
+		dayField = fields[0]
+	}
+	m := reOrdinal.FindStringSubmatch(dayField)
+	if !ok || m == nil {
+		return time.Time{}, false
+	}
+	n, _ := strconv.Atoi(m[1])
+
+	date := time.Date(today.Year(), month, n, 0, 0, 0, 0, loc)
+	if date.Month() != month {
+		return time.Time{}, false // e.g. february 30
+	}
+	if date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
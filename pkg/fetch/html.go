@@ -0,0 +1,428 @@
+package fetch
+
+import (
+	"html"
+	"strings"
+)
+
+// Node is a minimal HTML DOM node. Text nodes have an empty Tag.
+type Node struct {
+	Tag      string
+	Attrs    map[string]string
+	Text     string
+	Children []*Node
+	Parent   *Node
+}
+
+// voidElements never have children or closing tags.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements contain unparsed text up to their closing tag.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true, "noscript": true,
+}
+
+// ParseHTML builds a lenient DOM tree from an HTML document.
+// It is not a spec-compliant parser, but handles the real-world markup we care about
+// (unclosed <p>/<li>, void elements, script/style bodies, comments).
+func ParseHTML(doc string) *Node {
+	root := &Node{Tag: "#document"}
+	stack := []*Node{root}
+	current := func() *Node { return stack[len(stack)-1] }
+
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		parent := current()
+		parent.Children = append(parent.Children, &Node{Text: html.UnescapeString(text), Parent: parent})
+	}
+
+	closeTag := func(tag string) {
+		for i := len(stack) - 1; i > 0; i-- {
+			if stack[i].Tag == tag {
+				stack = stack[:i]
+				return
+			}
+		}
+	}
+
+	i := 0
+	for i < len(doc) {
+		lt := strings.IndexByte(doc[i:], '<')
+		if lt == -1 {
+			appendText(doc[i:])
+			break
+		}
+		appendText(doc[i : i+lt])
+		i += lt
+
+		rest := doc[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end == -1 {
+				i = len(doc)
+			} else {
+				i += end + 3
+			}
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end == -1 {
+				i = len(doc)
+			} else {
+				i += end + 1
+			}
+			continue
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end == -1 {
+				i = len(doc)
+				continue
+			}
+			tag := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			if sp := strings.IndexAny(tag, " \t\n\r"); sp != -1 {
+				tag = tag[:sp]
+			}
+			closeTag(tag)
+			i += end + 1
+			continue
+		}
+
+		if len(rest) < 2 || !isTagNameStart(rest[1]) {
+			appendText("<")
+			i++
+			continue
+		}
+
+		tag, attrs, selfClosing, n := parseStartTag(rest)
+		i += n
+
+		// Implicitly close paragraphs and list items, as browsers do.
+		switch tag {
+		case "p", "div", "ul", "ol", "table", "pre", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6", "section", "article":
+			if current().Tag == "p" {
+				stack = stack[:len(stack)-1]
+			}
+		case "li":
+			closeImplicit(&stack, "li", "ul", "ol")
+		case "tr":
+			closeImplicit(&stack, "tr", "table", "tbody")
+		case "td", "th":
+			closeImplicit(&stack, "td", "tr", "table")
+			closeImplicit(&stack, "th", "tr", "table")
+		case "option":
+			closeImplicit(&stack, "option", "select", "")
+		}
+
+		parent := current()
+		node := &Node{Tag: tag, Attrs: attrs, Parent: parent}
+		parent.Children = append(parent.Children, node)
+
+		if rawTextElements[tag] && !selfClosing {
+			closing := "</" + tag
+			end := indexFold(doc[i:], closing)
+			var body string
+			if end == -1 {
+				body = doc[i:]
+				i = len(doc)
+			} else {
+				body = doc[i : i+end]
+				i += end
+				if gt := strings.IndexByte(doc[i:], '>'); gt != -1 {
+					i += gt + 1
+				} else {
+					i = len(doc)
+				}
+			}
+			if tag != "script" && tag != "style" && body != "" {
+				node.Children = append(node.Children, &Node{Text: html.UnescapeString(body), Parent: node})
+			}
+			continue
+		}
+
+		if !selfClosing && !voidElements[tag] {
+			stack = append(stack, node)
+		}
+	}
+
+	return root
+}
+
+// closeImplicit pops an open <tag> if it appears on the stack before any boundary element.
+func closeImplicit(stack *[]*Node, tag, boundary1, boundary2 string) {
+	s := *stack
+	for i := len(s) - 1; i > 0; i-- {
+		t := s[i].Tag
+		if t == tag {
+			*stack = s[:i]
+			return
+		}
+		if t == boundary1 || (boundary2 != "" && t == boundary2) {
+			return
+		}
+	}
+}
+
+func isTagNameStart(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// indexFold is a case-insensitive strings.Index for an ASCII substr. It folds ASCII only,
+// so the index is valid in s even when s is not UTF-8.
+func indexFold(s, substr string) int {
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		match := true
+		for j := 0; j < n; j++ {
+			if lowerASCII(s[i+j]) != lowerASCII(substr[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+func lowerASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// parseStartTag parses "<tag attr=value ...>" and returns the tag, its attributes,
+// whether it was self-closing and the number of bytes consumed.
+func parseStartTag(s string) (string, map[string]string, bool, int) {
+	i := 1
+	start := i
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	tag := strings.ToLower(s[start:i])
+	attrs := map[string]string{}
+	selfClosing := false
+
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			i++
+			return tag, attrs, selfClosing, i
+		}
+		if s[i] == '/' {
+			selfClosing = true
+			i++
+			continue
+		}
+
+		nameStart := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[nameStart:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				i++
+				valStart := i
+				for i < len(s) && s[i] != quote {
+					i++
+				}
+				value = s[valStart:i]
+				if i < len(s) {
+					i++
+				}
+			} else {
+				valStart := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[valStart:i]
+			}
+		}
+		if name != "" {
+			attrs[name] = html.UnescapeString(value)
+		}
+		selfClosing = false
+	}
+
+	return tag, attrs, selfClosing, len(s)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// Find returns the first descendant element with the given tag, or nil.
+func (n *Node) Find(tag string) *Node {
+	for _, c := range n.Children {
+		if c.Tag == tag {
+			return c
+		}
+		if found := c.Find(tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// TextContent returns the concatenated text of the node and all descendants.
+func (n *Node) TextContent() string {
+	var sb strings.Builder
+	n.walkText(&sb)
+	return sb.String()
+}
+
+func (n *Node) walkText(sb *strings.Builder) {
+	if n.Tag == "" {
+		sb.WriteString(n.Text)
+		return
+	}
+	if n.Tag == "script" || n.Tag == "style" {
+		return
+	}
+	for _, c := range n.Children {
+		c.walkText(sb)
+	}
+}
+
+// HasClass reports whether the element's class attribute contains name.
+func (n *Node) HasClass(name string) bool {
+	for _, c := range strings.Fields(n.Attrs["class"]) {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleSelector is one compound selector, e.g. "div.content#main".
+type simpleSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+func parseSimpleSelector(s string) simpleSelector {
+	var sel simpleSelector
+	var cur strings.Builder
+	kind := byte(0) // 0 = tag, '#' = id, '.' = class
+	flush := func() {
+		v := cur.String()
+		cur.Reset()
+		if v == "" {
+			return
+		}
+		switch kind {
+		case 0:
+			sel.tag = strings.ToLower(v)
+		case '#':
+			sel.id = v
+		case '.':
+			sel.classes = append(sel.classes, v)
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' || s[i] == '.' {
+			flush()
+			kind = s[i]
+			continue
+		}
+		cur.WriteByte(s[i])
+	}
+	flush()
+	if sel.tag == "*" {
+		sel.tag = ""
+	}
+	return sel
+}
+
+func (sel simpleSelector) matches(n *Node) bool {
+	if n.Tag == "" || n.Tag == "#document" {
+		return false
+	}
+	if sel.tag != "" && n.Tag != sel.tag {
+		return false
+	}
+	if sel.id != "" && n.Attrs["id"] != sel.id {
+		return false
+	}
+	for _, c := range sel.classes {
+		if !n.HasClass(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns all elements matching a basic CSS selector.
+// Supported: tag, #id, .class, compounds (div.post#main), descendant chains ("article .body p")
+// and comma-separated groups.
+func (n *Node) Select(selector string) []*Node {
+	var out []*Node
+	seen := map[*Node]bool{}
+	for _, group := range strings.Split(selector, ",") {
+		parts := strings.Fields(group)
+		if len(parts) == 0 {
+			continue
+		}
+		var chain []simpleSelector
+		for _, p := range parts {
+			chain = append(chain, parseSimpleSelector(p))
+		}
+		n.walkElements(func(el *Node) {
+			if seen[el] || !chain[len(chain)-1].matches(el) {
+				return
+			}
+			if matchAncestors(el.Parent, chain[:len(chain)-1]) {
+				seen[el] = true
+				out = append(out, el)
+			}
+		})
+	}
+	return out
+}
+
+func matchAncestors(n *Node, chain []simpleSelector) bool {
+	if len(chain) == 0 {
+		return true
+	}
+	for p := n; p != nil; p = p.Parent {
+		if chain[len(chain)-1].matches(p) {
+			return matchAncestors(p.Parent, chain[:len(chain)-1])
+		}
+	}
+	return false
+}
+
+func (n *Node) walkElements(fn func(*Node)) {
+	for _, c := range n.Children {
+		if c.Tag == "" {
+			continue
+		}
+		fn(c)
+		c.walkElements(fn)
+	}
+}
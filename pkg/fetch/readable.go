@@ -0,0 +1,442 @@
+package fetch
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxChars is the default character budget for a single page of fetched content.
+const DefaultMaxChars = 10000
+
+// Article is the readable content extracted from an HTML page.
+type Article struct {
+	Title    string
+	Markdown string
+}
+
+// skipElements are never rendered.
+var skipElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "form": true, "button": true, "input": true, "select": true,
+	"textarea": true, "nav": true, "aside": true, "footer": true, "head": true,
+	"canvas": true, "object": true, "embed": true, "dialog": true,
+}
+
+var (
+	unlikelyRe = regexp.MustCompile(`(?i)comment|sidebar|footer|nav|menu|share|social|advert|\bad-|promo|cookie|banner|popup|modal|related|newsletter|subscribe|breadcrumb|masthead`)
+	likelyRe   = regexp.MustCompile(`(?i)article|content|main|post|body|entry|story|text`)
+	spaceRe    = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankRe    = regexp.MustCompile(`\n{3,}`)
+)
+
+// ExtractReadable parses an HTML document and returns its main content as Markdown.
+// If selector is non-empty, the matching elements are rendered instead of the
+// heuristically detected article body. Relative links are resolved against baseURL.
+func ExtractReadable(doc string, baseURL string, selector string) (*Article, error) {
+	root := ParseHTML(doc)
+
+	article := &Article{}
+	if t := root.Find("title"); t != nil {
+		article.Title = strings.TrimSpace(spaceRe.ReplaceAllString(t.TextContent(), " "))
+	}
+
+	base, _ := url.Parse(baseURL)
+
+	var nodes []*Node
+	if selector != "" {
+		nodes = root.Select(selector)
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("selector %q matched no elements", selector)
+		}
+	} else {
+		nodes = []*Node{findContentNode(root)}
+	}
+
+	w := &mdWriter{base: base}
+	for _, n := range nodes {
+		w.render(n)
+		w.block()
+	}
+	article.Markdown = w.String()
+	return article, nil
+}
+
+// findContentNode picks the element most likely to hold the main article text.
+func findContentNode(root *Node) *Node {
+	body := root.Find("body")
+	if body == nil {
+		body = root
+	}
+
+	// Prefer explicit semantic containers when they hold a meaningful amount of text.
+	for _, tag := range []string{"article", "main"} {
+		var best *Node
+		bestLen := 0
+		root.walkElements(func(n *Node) {
+			if n.Tag == tag {
+				if l := len(strings.TrimSpace(n.TextContent())); l > bestLen {
+					best, bestLen = n, l
+				}
+			}
+		})
+		if best != nil && bestLen >= 500 {
+			return best
+		}
+	}
+	var roleMain *Node
+	root.walkElements(func(n *Node) {
+		if roleMain == nil && n.Attrs["role"] == "main" {
+			roleMain = n
+		}
+	})
+	if roleMain != nil {
+		return roleMain
+	}
+
+	// Score paragraph containers (readability-style).
+	scores := map[*Node]float64{}
+	root.walkElements(func(n *Node) {
+		if n.Tag != "p" && n.Tag != "pre" && n.Tag != "td" && n.Tag != "blockquote" {
+			return
+		}
+		if isUnlikely(n) {
+			return
+		}
+		text := strings.TrimSpace(n.TextContent())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ","))
+		if extra := float64(len(text)) / 100; extra < 3 {
+			score += extra
+		} else {
+			score += 3
+		}
+		if p := n.Parent; p != nil {
+			scores[p] += score
+			if gp := p.Parent; gp != nil {
+				scores[gp] += score / 2
+			}
+		}
+	})
+
+	var best *Node
+	bestScore := 0.0
+	for n, s := range scores {
+		if n.Tag == "#document" || isUnlikely(n) {
+			continue
+		}
+		if likelyRe.MatchString(n.Attrs["class"] + " " + n.Attrs["id"]) {
+			s *= 1.25
+		}
+		s *= 1 - linkDensity(n)
+		if s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil {
+		return body
+	}
+	return best
+}
+
+func isUnlikely(n *Node) bool {
+	for p := n; p != nil && p.Tag != "body"; p = p.Parent {
+		if skipElements[p.Tag] {
+			return true
+		}
+		hint := p.Attrs["class"] + " " + p.Attrs["id"]
+		if strings.TrimSpace(hint) != "" && unlikelyRe.MatchString(hint) && !likelyRe.MatchString(hint) {
+			return true
+		}
+	}
+	return false
+}
+
+func linkDensity(n *Node) float64 {
+	total := len(strings.TrimSpace(n.TextContent()))
+	if total == 0 {
+		return 0
+	}
+	linkLen := 0
+	n.walkElements(func(el *Node) {
+		if el.Tag == "a" {
+			linkLen += len(strings.TrimSpace(el.TextContent()))
+		}
+	})
+	return float64(linkLen) / float64(total)
+}
+
+// mdWriter renders a DOM subtree as Markdown.
+type mdWriter struct {
+	sb        strings.Builder
+	base      *url.URL
+	listStack []string // "ul" or "ol"
+	olIndex   []int
+	inPre     bool
+}
+
+func (w *mdWriter) String() string {
+	out := w.sb.String()
+	lines := strings.Split(out, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	out = strings.Join(lines, "\n")
+	out = blankRe.ReplaceAllString(out, "\n\n")
+	return strings.TrimSpace(out)
+}
+
+func (w *mdWriter) write(s string) {
+	w.sb.WriteString(s)
+}
+
+// block ensures the output ends with a blank line.
+func (w *mdWriter) block() {
+	s := w.sb.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		w.write("\n")
+	} else {
+		w.write("\n\n")
+	}
+}
+
+func (w *mdWriter) newline() {
+	s := w.sb.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		w.write("\n")
+	}
+}
+
+func (w *mdWriter) renderChildren(n *Node) {
+	for _, c := range n.Children {
+		w.render(c)
+	}
+}
+
+func (w *mdWriter) render(n *Node) {
+	if n.Tag == "" {
+		w.text(n.Text)
+		return
+	}
+	if skipElements[n.Tag] || strings.Contains(strings.ReplaceAll(n.Attrs["style"], " ", ""), "display:none") {
+		return
+	}
+	if _, hidden := n.Attrs["hidden"]; hidden {
+		return
+	}
+
+	switch n.Tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.block()
+		level := int(n.Tag[1] - '0')
+		w.write(strings.Repeat("#", level) + " ")
+		w.write(strings.TrimSpace(spaceRe.ReplaceAllString(n.TextContent(), " ")))
+		w.block()
+	case "p", "div", "section", "article", "main", "header", "figure", "table", "dl":
+		w.block()
+		w.renderChildren(n)
+		w.block()
+	case "br":
+		w.write("  \n")
+	case "hr":
+		w.block()
+		w.write("---")
+		w.block()
+	case "pre":
+		w.block()
+		w.write("```\n")
+		w.inPre = true
+		w.write(strings.Trim(n.TextContent(), "\n"))
+		w.inPre = false
+		w.write("\n```")
+		w.block()
+	case "code":
+		if w.inPre {
+			w.renderChildren(n)
+			return
+		}
+		w.write("`" + strings.TrimSpace(n.TextContent()) + "`")
+	case "strong", "b":
+		w.inline(n, "**")
+	case "em", "i":
+		w.inline(n, "_")
+	case "blockquote":
+		w.block()
+		inner := &mdWriter{base: w.base}
+		inner.renderChildren(n)
+		for _, line := range strings.Split(inner.String(), "\n") {
+			w.write("> " + line + "\n")
+		}
+		w.block()
+	case "ul", "ol":
+		if len(w.listStack) == 0 {
+			w.block()
+		} else {
+			w.newline()
+		}
+		w.listStack = append(w.listStack, n.Tag)
+		w.olIndex = append(w.olIndex, 0)
+		w.renderChildren(n)
+		w.listStack = w.listStack[:len(w.listStack)-1]
+		w.olIndex = w.olIndex[:len(w.olIndex)-1]
+		if len(w.listStack) == 0 {
+			w.block()
+		}
+	case "li":
+		w.newline()
+		depth := len(w.listStack)
+		if depth == 0 {
+			depth = 1
+		}
+		indent := strings.Repeat("  ", depth-1)
+		marker := "- "
+		if len(w.listStack) > 0 && w.listStack[len(w.listStack)-1] == "ol" {
+			w.olIndex[len(w.olIndex)-1]++
+			marker = fmt.Sprintf("%d. ", w.olIndex[len(w.olIndex)-1])
+		}
+		w.write(indent + marker)
+		w.renderChildren(n)
+		w.newline()
+	case "tr":
+		w.newline()
+		var cells []string
+		for _, c := range n.Children {
+			if c.Tag == "td" || c.Tag == "th" {
+				cells = append(cells, strings.TrimSpace(spaceRe.ReplaceAllString(c.TextContent(), " ")))
+			}
+		}
+		if len(cells) > 0 {
+			w.write("| " + strings.Join(cells, " | ") + " |")
+		}
+		w.newline()
+	case "a":
+		text := strings.TrimSpace(spaceRe.ReplaceAllString(n.TextContent(), " "))
+		href := w.resolve(n.Attrs["href"])
+		if text == "" {
+			return
+		}
+		if href == "" {
+			w.write(text)
+			return
+		}
+		w.write("[" + text + "](" + href + ")")
+	case "img":
+		alt := strings.TrimSpace(n.Attrs["alt"])
+		if alt == "" {
+			return
+		}
+		if src := w.resolve(n.Attrs["src"]); src != "" {
+			w.write("![" + alt + "](" + src + ")")
+		} else {
+			w.write(alt)
+		}
+	case "title":
+		// Reported separately as Article.Title.
+	default:
+		w.renderChildren(n)
+	}
+}
+
+func (w *mdWriter) inline(n *Node, marker string) {
+	inner := &mdWriter{base: w.base}
+	inner.renderChildren(n)
+	text := strings.TrimSpace(spaceRe.ReplaceAllString(inner.String(), " "))
+	if text == "" {
+		return
+	}
+	w.write(marker + text + marker)
+}
+
+func (w *mdWriter) text(s string) {
+	if w.inPre {
+		w.write(s)
+		return
+	}
+	s = spaceRe.ReplaceAllString(s, " ")
+	if s == " " {
+		cur := w.sb.String()
+		if cur == "" || strings.HasSuffix(cur, " ") || strings.HasSuffix(cur, "\n") {
+			return
+		}
+	}
+	if strings.HasSuffix(w.sb.String(), "\n") {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.write(s)
+}
+
+func (w *mdWriter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	lower := strings.ToLower(href)
+	if strings.HasPrefix(lower, "javascript:") || strings.HasPrefix(lower, "data:") {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if w.base != nil {
+		ref = w.base.ResolveReference(ref)
+	}
+	return ref.String()
+}
+
+// Page is a window into a longer piece of content.
+type Page struct {
+	Content    string
+	Offset     int // Character offset of Content within the full text
+	NextOffset int // Offset to request for the next page, or 0 if this is the last page
+	Total      int // Total number of characters
+}
+
+// Paginate returns up to maxChars characters of text starting at offset.
+// Offsets are counted in characters (runes), so multi-byte text is never split mid-rune.
+// When possible the page is cut at a paragraph or line break to keep Markdown intact.
+func Paginate(text string, offset, maxChars int) Page {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxChars
+	}
+	total := utf8.RuneCountInString(text)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total {
+		return Page{Offset: offset, Total: total}
+	}
+
+	runes := []rune(text)
+	end := offset + maxChars
+	if end >= total {
+		return Page{Content: string(runes[offset:]), Offset: offset, Total: total}
+	}
+
+	// Try to cut at a paragraph/line boundary in the last 20% of the window.
+	chunk := string(runes[offset:end])
+	minCut := len(chunk) * 4 / 5
+	if idx := strings.LastIndex(chunk, "\n\n"); idx >= minCut {
+		chunk = chunk[:idx]
+	} else if idx := strings.LastIndex(chunk, "\n"); idx >= minCut {
+		chunk = chunk[:idx]
+	}
+	next := offset + utf8.RuneCountInString(chunk)
+
+	return Page{Content: chunk, Offset: offset, NextOffset: next, Total: total}
+}
+
+// Footer returns a hint telling the model how to continue reading, or "" for the last page.
+func (p Page) Footer() string {
+	if p.NextOffset == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[Content truncated: showing characters %d-%d of %d. Run the same fetch with --offset %d to read more.]", p.Offset, p.NextOffset, p.Total, p.NextOffset)
+}
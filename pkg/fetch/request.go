@@ -0,0 +1,197 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+// Request describes an outbound HTTP call made on behalf of the agent.
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Query   map[string]string
+	Body    string
+	Auth    string // Name of an auth profile from config; secrets never pass through the model
+}
+
+// noisyHeaders are response headers that cost tokens without helping the model.
+var noisyHeaders = map[string]bool{
+	"Content-Security-Policy":             true,
+	"Content-Security-Policy-Report-Only": true,
+	"Set-Cookie":                          true,
+	"Report-To":                           true,
+	"Nel":                                 true,
+	"Strict-Transport-Security":           true,
+	"Permissions-Policy":                  true,
+	"Alt-Svc":                             true,
+	"Server-Timing":                       true,
+	"Cf-Ray":                              true,
+	"X-Xss-Protection":                    true,
+	"X-Content-Type-Options":              true,
+	"X-Frame-Options":                     true,
+	"Referrer-Policy":                     true,
+	"Cross-Origin-Opener-Policy":          true,
+	"Cross-Origin-Resource-Policy":        true,
+	"Cross-Origin-Embedder-Policy":        true,
+	"Origin-Trial":                        true,
+	"Accept-Ch":                           true,
+}
+
+// Build turns the request into an *http.Request, applying query parameters and the named auth profile.
+// It also returns the header names that carry credentials, so callers can strip them on cross-host redirects.
+func (r *Request) Build(profiles map[string]config.AuthProfile) (*http.Request, []string, error) {
+	method := strings.ToUpper(strings.TrimSpace(r.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if len(r.Query) > 0 {
+		q := u.Query()
+		for k, v := range r.Query {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	if r.Body != "" {
+		body = bytes.NewBufferString(r.Body)
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if r.Body != "" && req.Header.Get("Content-Type") == "" {
+		if json.Valid([]byte(r.Body)) {
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "yaocc-fetch/1.0")
+	}
+
+	var secretHeaders []string
+	if r.Auth != "" {
+		secretHeaders, err = applyAuth(req, r.Auth, profiles)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return req, secretHeaders, nil
+}
+
+func applyAuth(req *http.Request, name string, profiles map[string]config.AuthProfile) ([]string, error) {
+	profile, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("auth profile '%s' not found in config", name)
+	}
+
+	// Profiles are bound to hosts so a prompt-injected URL cannot exfiltrate credentials.
+	if len(profile.Hosts) == 0 {
+		return nil, fmt.Errorf("auth profile '%s' has no hosts configured", name)
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	allowed := false
+	for _, h := range profile.Hosts {
+		h = strings.ToLower(h)
+		if host == h || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("auth profile '%s' is not allowed for host %s", name, host)
+	}
+
+	switch strings.ToLower(profile.Type) {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+profile.Token)
+		return []string{"Authorization"}, nil
+	case "basic":
+		creds := base64.StdEncoding.EncodeToString([]byte(profile.Username + ":" + profile.Password))
+		req.Header.Set("Authorization", "Basic "+creds)
+		return []string{"Authorization"}, nil
+	case "header":
+		if profile.Header == "" {
+			return nil, fmt.Errorf("auth profile '%s' of type header requires a header name", name)
+		}
+		req.Header.Set(profile.Header, profile.Value)
+		return []string{profile.Header}, nil
+	default:
+		return nil, fmt.Errorf("auth profile '%s' has unsupported type '%s'", name, profile.Type)
+	}
+}
+
+// StripOnRedirect wraps a redirect policy so credential headers are not forwarded to another host.
+func StripOnRedirect(next func(*http.Request, []*http.Request) error, secretHeaders []string) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if next != nil {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		}
+		if len(via) > 0 && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			for _, h := range secretHeaders {
+				req.Header.Del(h)
+			}
+		}
+		return nil
+	}
+}
+
+// FormatResponseHead renders the status line and relevant headers of a structured result.
+// The body (see PrettyJSON) follows the returned "Body:" label.
+func FormatResponseHead(resp *http.Response) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Status: %s\n", resp.Status))
+
+	sb.WriteString("Headers:\n")
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		if !noisyHeaders[http.CanonicalHeaderKey(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", k, strings.Join(resp.Header[k], ", ")))
+	}
+
+	sb.WriteString("Body:\n")
+	return sb.String()
+}
+
+// PrettyJSON indents body if it is valid JSON and returns it unchanged otherwise.
+func PrettyJSON(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return body
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return body
+	}
+	return buf.String()
+}
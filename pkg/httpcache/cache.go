@@ -0,0 +1,180 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+// DefaultMaxBytes bounds the on-disk size of the cache when no limit is configured.
+const DefaultMaxBytes = 100 * 1024 * 1024
+
+// Entry is a cached HTTP response or arbitrary payload (e.g. search results).
+type Entry struct {
+	Key      string      `json:"key"`
+	StoredAt time.Time   `json:"storedAt"`
+	Expires  time.Time   `json:"expires"` // Zero means the entry must be revalidated before use
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body"`
+}
+
+// Fresh reports whether the entry can be served without revalidation.
+func (e *Entry) Fresh(now time.Time) bool {
+	return !e.Expires.IsZero() && now.Before(e.Expires)
+}
+
+// Cache is a size-bounded, least-recently-used file cache. Each entry is stored as a
+// JSON file named after the hash of its key, so several processes can share the directory.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+	mu       sync.Mutex
+}
+
+// New creates a cache rooted at dir. maxBytes <= 0 uses DefaultMaxBytes.
+func New(dir string, maxBytes int64) *Cache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	return &Cache{Dir: dir, MaxBytes: maxBytes}
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the entry stored under key. Reading an entry marks it as recently used.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	p := c.path(key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return &e, true
+}
+
+// Put stores an entry and evicts the least recently used entries if the cache grew too large.
+func (c *Cache) Put(e *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	if int64(len(data)) > c.MaxBytes/4 {
+		return nil // Too large to be worth caching
+	}
+
+	// Write atomically so concurrent readers never see a partial file
+	tmp, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), c.path(e.Key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Delete removes the entry stored under key.
+func (c *Cache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+func (c *Cache) evict() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	type fileInfo struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []fileInfo
+	var total int64
+	for _, de := range entries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{filepath.Join(c.Dir, de.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	if total <= c.MaxBytes {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// GetJSON decodes a fresh payload stored with PutJSON into v.
+func (c *Cache) GetJSON(key string, v interface{}) bool {
+	e, ok := c.Get(key)
+	if !ok || !e.Fresh(time.Now()) {
+		return false
+	}
+	return json.Unmarshal(e.Body, v) == nil
+}
+
+// PutJSON stores v under key for ttl.
+func (c *Cache) PutJSON(key string, v interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return c.Put(&Entry{Key: key, StoredAt: now, Expires: now.Add(ttl), Body: data})
+}
+
+// FromConfig opens the cache under tempDir/cache, or returns nil when caching is disabled.
+func FromConfig(cfg config.CacheConfig, tempDir string) *Cache {
+	if cfg.Disabled || tempDir == "" {
+		return nil
+	}
+	return New(filepath.Join(tempDir, "cache"), int64(cfg.MaxSizeMB)*1024*1024)
+}
@@ -0,0 +1,246 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+// StatusHeader is added to responses served through the Transport: HIT, REVALIDATED, STALE or MISS.
+const StatusHeader = "X-Yaocc-Cache"
+
+// Transport is an http.RoundTripper that caches GET responses following
+// Cache-Control, Expires, ETag and Last-Modified semantics (private cache).
+type Transport struct {
+	Cache *Cache
+	Base  http.RoundTripper
+	Now   func() time.Time // Overridable for tests
+}
+
+// NewTransport wraps base with the cache. A nil base uses http.DefaultTransport.
+func NewTransport(c *Cache, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Cache: c, Base: base, Now: time.Now}
+}
+
+// RequestKey identifies a request: method, URL and all request headers (so different
+// credentials or Accept headers never share an entry). It is a SHA-256 hash, since the
+// key is stored in the cache files and the headers may hold credentials.
+func RequestKey(req *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(req.Method + " " + req.URL.String())
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if k == "If-None-Match" || k == "If-Modified-Since" || k == "Cache-Control" {
+			continue
+		}
+		sb.WriteString("\n" + k + ": " + strings.Join(req.Header[k], ","))
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return "request:" + hex.EncodeToString(sum[:])
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || t.Cache == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	now := t.Now()
+	key := RequestKey(req)
+	cached, ok := t.Cache.Get(key)
+	reqDirectives := parseCacheControl(req.Header.Get("Cache-Control"))
+	_, forceRevalidate := reqDirectives["no-cache"]
+
+	if ok && !forceRevalidate && cached.Fresh(now) {
+		return cached.response(req, "HIT"), nil
+	}
+
+	outReq := req
+	if ok {
+		outReq = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lm := cached.Header.Get("Last-Modified"); lm != "" {
+			outReq.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := t.Base.RoundTrip(outReq)
+	if err != nil {
+		if ok && !errors.Is(err, netguard.ErrDenied) {
+			// Serve stale content rather than failing outright, unless the egress policy
+			// refused the request
+			return cached.response(req, "STALE"), nil
+		}
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		cached.StoredAt = now
+		cached.Expires = expiry(cached.Header, now)
+		_ = t.Cache.Put(cached)
+		return cached.response(req, "REVALIDATED"), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Header.Set(StatusHeader, "MISS")
+		return resp, nil
+	}
+
+	respDirectives := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if _, noStore := respDirectives["no-store"]; noStore {
+		resp.Header.Set(StatusHeader, "MISS")
+		return resp, nil
+	}
+	if _, noStore := reqDirectives["no-store"]; noStore {
+		resp.Header.Set(StatusHeader, "MISS")
+		return resp, nil
+	}
+
+	expires := expiry(resp.Header, now)
+	if expires.IsZero() && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		resp.Header.Set(StatusHeader, "MISS")
+		return resp, nil // Nothing that would let us reuse it
+	}
+
+	entry := &Entry{
+		Key:      key,
+		StoredAt: now,
+		Expires:  expires,
+		Status:   resp.StatusCode,
+		Header:   resp.Header.Clone(),
+	}
+	resp.Body = &teeBody{
+		src:   resp.Body,
+		limit: t.Cache.MaxBytes / 4,
+		done: func(body []byte) {
+			entry.Body = body
+			_ = t.Cache.Put(entry)
+		},
+	}
+	resp.Header.Set(StatusHeader, "MISS")
+	return resp, nil
+}
+
+func (e *Entry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(StatusHeader, status)
+	code := e.Status
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// expiry computes when a response stops being fresh. A zero time means "revalidate before use".
+func expiry(h http.Header, now time.Time) time.Time {
+	cc := parseCacheControl(h.Get("Cache-Control"))
+	if _, ok := cc["no-cache"]; ok {
+		return time.Time{}
+	}
+	if v, ok := cc["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return now.Add(time.Duration(secs) * time.Second)
+		}
+		return time.Time{}
+	}
+
+	date := now
+	if d, err := http.ParseTime(h.Get("Date")); err == nil {
+		date = d
+	}
+	if exp := h.Get("Expires"); exp != "" {
+		if t, err := http.ParseTime(exp); err == nil && t.After(date) {
+			return now.Add(t.Sub(date))
+		}
+		return time.Time{}
+	}
+
+	// Heuristic freshness (RFC 9111 4.2.2): 10% of the time since last modification, capped at a day
+	if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil && date.After(lm) {
+		ttl := date.Sub(lm) / 10
+		if ttl > 24*time.Hour {
+			ttl = 24 * time.Hour
+		}
+		return now.Add(ttl)
+	}
+	return time.Time{}
+}
+
+func parseCacheControl(v string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
+
+// teeBody copies what the caller reads and hands the full body to done at EOF,
+// unless it grew beyond limit or the caller stopped reading early.
+type teeBody struct {
+	src      io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	done     func([]byte)
+	finished bool
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.src.Read(p)
+	if n > 0 && !b.overflow {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.overflow = true
+			b.buf.Reset()
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.overflow && !b.finished {
+		b.finished = true
+		b.done(b.buf.Bytes())
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	return b.src.Close()
+}
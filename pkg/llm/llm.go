@@ -2,6 +2,7 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -111,7 +112,8 @@ func NewClient(cfg config.ProviderConfig, selectedModel string) *Client {
 }
 
 // Chat sends messages and optional tools. Returns the text response, any tool calls, and error.
-func (c *Client) Chat(messages []Message, tools []Tool) (string, []ToolCall, error) {
+// The request is aborted when ctx ends.
+func (c *Client) Chat(ctx context.Context, messages []Message, tools []Tool) (string, []ToolCall, error) {
 	reqBody := ChatRequest{
 		Model:     c.Model,
 		Messages:  messages,
@@ -146,7 +148,7 @@ func (c *Client) Chat(messages []Message, tools []Tool) (string, []ToolCall, err
 		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -10,8 +10,12 @@ import (
 	"os/exec"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// DefaultTimeout limits requests whose context has no deadline of its own.
+const DefaultTimeout = 60 * time.Second
+
 // Minimal JSON-RPC structures
 type Request struct {
 	JSONRPC string      `json:"jsonrpc"`
@@ -89,9 +93,11 @@ type Content struct {
 	// ignoring base64/data URI for now
 }
 
-// Client represents a connection to a specific MCP server via stdio.
+// Client represents a connection to a specific MCP server via stdio, or via HTTP if created
+// with NewHTTPClient.
 type Client struct {
 	name   string
+	http   *httpTransport // Set for HTTP servers; the stdio fields are then unused
 	cmd    *exec.Cmd
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
@@ -101,9 +107,12 @@ type Client struct {
 	pending    map[int64]chan Response
 	pendingMut sync.Mutex
 
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	Timeout time.Duration // Per-request timeout when ctx has no deadline (default DefaultTimeout)
 }
 
 // NewClient creates and starts an MCP server process.
@@ -168,11 +177,15 @@ func NewClient(name string, command string, args []string, env map[string]string
 	return c, nil
 }
 
-// Close shuts down the client and the underlying process.
+// Close shuts down the client and the underlying process or HTTP session.
 func (c *Client) Close() {
 	if c.cancel != nil {
 		c.cancel()
 	}
+	if c.http != nil {
+		c.closeOnce.Do(c.http.close)
+		return
+	}
 	if c.stdin != nil {
 		c.stdin.Close()
 	}
@@ -224,8 +237,19 @@ func (c *Client) readStdout() {
 	}
 }
 
-// Request sends a JSON-RPC request and waits for a response.
-func (c *Client) Request(method string, params interface{}) (json.RawMessage, error) {
+// Request sends a JSON-RPC request and waits for a response until ctx ends, or at most
+// c.Timeout if ctx has no deadline. An abandoned request is cancelled on the server.
+func (c *Client) Request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	id := atomic.AddInt64(&c.nextID, 1)
 
 	req := Request{
@@ -235,6 +259,24 @@ func (c *Client) Request(method string, params interface{}) (json.RawMessage, er
 		Params:  params,
 	}
 
+	if c.http != nil {
+		if c.ctx.Err() != nil {
+			return nil, fmt.Errorf("client closed")
+		}
+		resp, err := c.http.post(ctx, c.name, req, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.Notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": ctx.Err().Error()})
+				return nil, fmt.Errorf("%s on MCP server %s: %w", method, c.name, ctx.Err())
+			}
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("MCP error %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	}
+
 	data, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -245,17 +287,24 @@ func (c *Client) Request(method string, params interface{}) (json.RawMessage, er
 	c.pendingMut.Lock()
 	c.pending[id] = ch
 	c.pendingMut.Unlock()
-
-	if _, err := c.stdin.Write(data); err != nil {
+	abandon := func() {
 		c.pendingMut.Lock()
 		delete(c.pending, id)
 		c.pendingMut.Unlock()
+	}
+
+	if _, err := c.stdin.Write(data); err != nil {
+		abandon()
 		return nil, fmt.Errorf("failed to write request: %w", err)
 	}
 
 	select {
 	case <-c.ctx.Done():
 		return nil, fmt.Errorf("client closed")
+	case <-ctx.Done():
+		abandon()
+		c.Notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": ctx.Err().Error()})
+		return nil, fmt.Errorf("%s on MCP server %s: %w", method, c.name, ctx.Err())
 	case resp := <-ch:
 		if resp.Error != nil {
 			return nil, fmt.Errorf("MCP error %d: %s", resp.Error.Code, resp.Error.Message)
@@ -271,6 +320,12 @@ func (c *Client) Notify(method string, params interface{}) error {
 		Method:  method,
 		Params:  params,
 	}
+	if c.http != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := c.http.post(ctx, c.name, notif, 0)
+		return err
+	}
 	data, err := json.Marshal(notif)
 	if err != nil {
 		return fmt.Errorf("failed to marshal notification: %w", err)
@@ -282,7 +337,7 @@ func (c *Client) Notify(method string, params interface{}) error {
 }
 
 // Initialize performs the mandatory MCP initialization handshake.
-func (c *Client) Initialize() (*InitializeResult, error) {
+func (c *Client) Initialize(ctx context.Context) (*InitializeResult, error) {
 	params := InitializeRequestParams{
 		ProtocolVersion: "2024-11-05", // Latest MCP protocol version usually
 		ClientInfo: Implementation{
@@ -292,7 +347,7 @@ func (c *Client) Initialize() (*InitializeResult, error) {
 		Capabilities: ClientCapabilities{},
 	}
 
-	resultRaw, err := c.Request("initialize", params)
+	resultRaw, err := c.Request(ctx, "initialize", params)
 	if err != nil {
 		return nil, err
 	}
@@ -312,8 +367,8 @@ func (c *Client) Initialize() (*InitializeResult, error) {
 }
 
 // GetTools fetches the available tools from the MCP server.
-func (c *Client) GetTools() ([]Tool, error) {
-	resultRaw, err := c.Request("tools/list", map[string]interface{}{})
+func (c *Client) GetTools(ctx context.Context) ([]Tool, error) {
+	resultRaw, err := c.Request(ctx, "tools/list", map[string]interface{}{})
 	if err != nil {
 		return nil, err
 	}
@@ -327,13 +382,13 @@ func (c *Client) GetTools() ([]Tool, error) {
 }
 
 // CallTool executes a tool on the MCP server.
-func (c *Client) CallTool(name string, args interface{}) (*CallToolResult, error) {
+func (c *Client) CallTool(ctx context.Context, name string, args interface{}) (*CallToolResult, error) {
 	params := CallToolRequestParams{
 		Name:      name,
 		Arguments: args,
 	}
 
-	resultRaw, err := c.Request("tools/call", params)
+	resultRaw, err := c.Request(ctx, "tools/call", params)
 	if err != nil {
 		return nil, err
 	}
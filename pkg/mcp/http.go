@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport speaks the MCP Streamable HTTP transport: every message is POSTed to the
+// endpoint, and the server answers a request with JSON or a stream of server-sent events.
+type httpTransport struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	mu        sync.Mutex
+	sessionID string // Mcp-Session-Id assigned by the server on initialize
+}
+
+// NewHTTPClient connects to an MCP server over the Streamable HTTP transport. Requests are sent
+// with httpClient, so a client from netguard applies the egress policy. headers (e.g.
+// Authorization) are added to every request.
+func NewHTTPClient(name, endpoint string, headers map[string]string, httpClient *http.Client) (*Client, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("invalid MCP server URL %q", endpoint)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		name:    name,
+		http:    &httpTransport{endpoint: endpoint, headers: headers, client: httpClient},
+		pending: make(map[int64]chan Response),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// post sends msg and returns the response to the request with the given id. Notifications
+// (id 0) return nil.
+func (t *httpTransport) post(ctx context.Context, name string, msg interface{}, id int64) (*Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("MCP server %s answered %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if id == 0 {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readEventStream(resp.Body, name, id)
+	}
+	var res Response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("invalid response from MCP server %s: %w", name, err)
+	}
+	return &res, nil
+}
+
+// readEventStream reads server-sent events until the response to id arrives. Other messages
+// are server notifications and only logged.
+func readEventStream(body io.Reader, name string, id int64) (*Response, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	for {
+		more := scanner.Scan()
+		line := scanner.Text()
+		if more && strings.HasPrefix(line, "data:") {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if (line == "" || !more) && data.Len() > 0 {
+			var res Response
+			if err := json.Unmarshal([]byte(data.String()), &res); err == nil && res.ID == id {
+				return &res, nil
+			} else if err == nil && res.Method != "" {
+				log.Printf("[MCP %s Notification] Method: %s", name, res.Method)
+			}
+			data.Reset()
+		}
+		if !more {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("MCP server %s closed the stream without a response", name)
+		}
+	}
+}
+
+// close ends the session on the server, if it assigned one.
+func (t *httpTransport) close() {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.endpoint, nil)
+	if err != nil {
+		return
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	if resp, err := t.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
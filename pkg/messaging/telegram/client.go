@@ -2,7 +2,9 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/agent"
@@ -20,19 +23,25 @@ import (
 )
 
 type Client struct {
-	Token        string
-	AllowedUsers []string
-	Agent        *agent.Agent
-	Offset       int
-	HttpClient   *http.Client
+	Token         string
+	AllowedUsers  []string
+	Agent         *agent.Agent
+	Offset        int
+	HttpClient    *http.Client
+	OnMessage     func(provider, chatID, text string) // Called for each authorized message, e.g. to fire message triggers
+	StatusUpdates bool                                // Show what the agent is doing in a status message
+
+	mu     sync.Mutex
+	queues map[int64][]Update // Messages waiting per chat; a chat has a worker while it has an entry
 }
 
 func NewClient(cfg config.TelegramConfig, agt *agent.Agent) *Client {
 	return &Client{
-		Token:        cfg.BotToken,
-		AllowedUsers: cfg.AllowedUsers,
-		Agent:        agt,
-		Offset:       0,
+		Token:         cfg.BotToken,
+		AllowedUsers:  cfg.AllowedUsers,
+		Agent:         agt,
+		Offset:        0,
+		StatusUpdates: cfg.StatusUpdates,
 		HttpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -61,7 +70,7 @@ func (c *Client) StartPolling() {
 			if update.UpdateID >= c.Offset {
 				c.Offset = update.UpdateID + 1
 			}
-			c.handleUpdate(update)
+			c.dispatch(update)
 		}
 
 		time.Sleep(1 * time.Second)
@@ -140,22 +149,91 @@ func (c *Client) getUpdates() ([]Update, error) {
 	return result.Result, nil
 }
 
-func (c *Client) handleUpdate(update Update) {
+// dispatch handles /stop right away and queues other messages on their chat, so a long turn
+// neither blocks polling nor other chats, and the messages of one chat are answered in order.
+func (c *Client) dispatch(update Update) {
 	if update.Message == nil || update.Message.Text == "" {
 		return
 	}
+	if isStopCommand(update.Message.Text) {
+		c.handleStop(update)
+		return
+	}
 
+	chatID := update.Message.Chat.ID
+	c.mu.Lock()
+	if c.queues == nil {
+		c.queues = make(map[int64][]Update)
+	}
+	_, busy := c.queues[chatID]
+	c.queues[chatID] = append(c.queues[chatID], update)
+	c.mu.Unlock()
+	if !busy {
+		go c.work(chatID)
+	}
+}
+
+// work handles the queued messages of chatID one after another until the queue is empty.
+func (c *Client) work(chatID int64) {
+	for {
+		c.mu.Lock()
+		queue := c.queues[chatID]
+		if len(queue) == 0 {
+			delete(c.queues, chatID)
+			c.mu.Unlock()
+			return
+		}
+		update := queue[0]
+		c.queues[chatID] = queue[1:]
+		c.mu.Unlock()
+		c.handleUpdate(update)
+	}
+}
+
+func isStopCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "/stop" || strings.HasPrefix(text, "/stop@")
+}
+
+// handleStop stops the turn the agent is working on for the chat and drops its queued messages.
+func (c *Client) handleStop(update Update) {
+	if !c.isAllowed(update) {
+		return
+	}
+	chatID := update.Message.Chat.ID
+	c.mu.Lock()
+	dropped := 0
+	if queue, ok := c.queues[chatID]; ok {
+		dropped = len(queue)
+		c.queues[chatID] = nil
+	}
+	c.mu.Unlock()
+
+	stopped := c.Agent.Stop(fmt.Sprintf("telegram-%d", chatID))
+	log.Printf("Stop requested in chat %d: %d run(s) stopped, %d queued message(s) dropped", chatID, stopped, dropped)
+	if stopped == 0 && dropped == 0 {
+		c.sendMessageInt64(chatID, "Nothing to stop.")
+		return
+	}
+	c.sendMessageInt64(chatID, "Stopped.")
+}
+
+func (c *Client) isAllowed(update Update) bool {
 	userID := strconv.FormatInt(update.Message.From.ID, 10)
-	allowed := false
 	for _, allowedUser := range c.AllowedUsers {
 		if allowedUser == userID {
-			allowed = true
-			break
+			return true
 		}
 	}
+	log.Printf("Unauthorized access attempt from user %s", userID)
+	return false
+}
 
-	if !allowed {
-		log.Printf("Unauthorized access attempt from user %s", userID)
+func (c *Client) handleUpdate(update Update) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+	if !c.isAllowed(update) {
 		return
 	}
 
@@ -164,6 +242,10 @@ func (c *Client) handleUpdate(update Update) {
 
 	log.Printf("Received message from %s: %s", sessionID, update.Message.Text)
 
+	if c.OnMessage != nil {
+		c.OnMessage(c.Name(), strconv.FormatInt(chatID, 10), update.Message.Text)
+	}
+
 	// Start continuous typing action
 	done := make(chan struct{})
 	go func() {
@@ -185,8 +267,16 @@ func (c *Client) handleUpdate(update Update) {
 	// Ensure we stop the ticker when this function exits (success or error)
 	defer close(done)
 
+	if c.StatusUpdates && c.Agent.Events != nil {
+		stop := c.showStatus(chatID, sessionID)
+		defer stop()
+	}
+
 	// Process with Agent
-	response, err := c.Agent.Run(sessionID, c, strconv.FormatInt(chatID, 10), update.Message.Text)
+	response, err := c.Agent.Run(context.Background(), sessionID, c, strconv.FormatInt(chatID, 10), update.Message.Text)
+	if errors.Is(err, agent.ErrStopped) {
+		return // handleStop has replied
+	}
 	if err != nil {
 		log.Printf("Agent error: %v", err)
 		c.sendMessageInt64(chatID, fmt.Sprintf("Error: %v", err))
@@ -211,6 +301,74 @@ func (c *Client) handleUpdate(update Update) {
 	c.sendMessageInt64(chatID, response)
 }
 
+// showStatus keeps a status message in the chat up to date with the tools the agent runs for
+// sessionID. The returned function stops and deletes it.
+func (c *Client) showStatus(chatID int64, sessionID string) func() {
+	events, unsubscribe := c.Agent.Events.Subscribe(sessionID)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		var messageID int64
+		var last string
+		for ev := range events {
+			if ev.Type != agent.EventToolStart || ev.Status == "" {
+				continue
+			}
+			text := "⏳ " + ev.Status + "…"
+			if text == last {
+				continue
+			}
+			last = text
+			if messageID == 0 {
+				id, err := c.sendPlain(chatID, text)
+				if err != nil {
+					log.Printf("Error sending status message: %v", err)
+					continue
+				}
+				messageID = id
+			} else {
+				c.postJSON(c.apiURL("editMessageText"), map[string]interface{}{"chat_id": chatID, "message_id": messageID, "text": text})
+			}
+		}
+		if messageID != 0 {
+			c.postJSON(c.apiURL("deleteMessage"), map[string]interface{}{"chat_id": chatID, "message_id": messageID})
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-finished
+	}
+}
+
+// sendPlain sends text without formatting and returns the ID of the new message.
+func (c *Client) sendPlain(chatID int64, text string) (int64, error) {
+	jsonBody, _ := json.Marshal(map[string]interface{}{"chat_id": chatID, "text": text})
+	resp, err := c.HttpClient.Post(c.apiURL("sendMessage"), "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("telegram api error: %s", result.Description)
+	}
+	return result.Result.MessageID, nil
+}
+
+func (c *Client) apiURL(method string) string {
+	return fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.Token, method)
+}
+
 func (c *Client) SendChatAction(chatID int64, action string) error {
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendChatAction", c.Token)
 	body := map[string]interface{}{
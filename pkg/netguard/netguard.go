@@ -0,0 +1,323 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+// ErrDenied is wrapped by every error returned for a destination the policy refuses.
+var ErrDenied = errors.New("egress denied")
+
+// reservedRanges are non-public networks that are denied unless explicitly allowed.
+// net.IP helpers cover loopback, RFC1918, link-local and multicast; these add the rest.
+var reservedRanges = mustParseCIDRs(
+	"0.0.0.0/8",       // "this" network
+	"100.64.0.0/10",   // carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"240.0.0.0/4",     // reserved
+	"64:ff9b::/96",    // NAT64
+	"100::/64",        // discard
+	"2001:db8::/32",   // documentation
+)
+
+// Policy decides which network destinations outbound HTTP requests may reach.
+type Policy struct {
+	allowPrivate bool
+	restrict     bool
+	schemes      map[string]bool
+	ports        map[int]bool
+	allowHosts   []string
+	allowNets    []*net.IPNet
+	denyHosts    []string
+	denyNets     []*net.IPNet
+	resolver     *net.Resolver
+}
+
+// NewPolicy builds a Policy from configuration. Hosts entries may be hostnames,
+// wildcard domains ("*.example.com"), IP addresses or CIDR ranges.
+func NewPolicy(cfg config.EgressConfig) (*Policy, error) {
+	p := &Policy{
+		allowPrivate: cfg.AllowPrivate,
+		restrict:     cfg.AllowOnly,
+		schemes:      map[string]bool{},
+		ports:        map[int]bool{},
+		resolver:     net.DefaultResolver,
+	}
+
+	schemes := cfg.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		p.schemes[strings.ToLower(s)] = true
+	}
+	for _, port := range cfg.Ports {
+		p.ports[port] = true
+	}
+
+	var err error
+	if p.allowHosts, p.allowNets, err = splitHostsAndNets(cfg.AllowHosts); err != nil {
+		return nil, fmt.Errorf("invalid egress allowHosts: %w", err)
+	}
+	if p.denyHosts, p.denyNets, err = splitHostsAndNets(cfg.DenyHosts); err != nil {
+		return nil, fmt.Errorf("invalid egress denyHosts: %w", err)
+	}
+	return p, nil
+}
+
+// Trust returns a copy of the policy that additionally allows the given hosts.
+// It is used for endpoints configured by the operator (e.g. a self-hosted SearxNG on the LAN).
+func (p *Policy) Trust(hosts ...string) *Policy {
+	cp := *p
+	cp.allowHosts = append(append([]string{}, p.allowHosts...), hosts...)
+	return &cp
+}
+
+// CheckURL validates the scheme, port and host of a URL before any connection is made.
+// Resolved addresses are checked again at dial time (see Client).
+func (p *Policy) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrDenied, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrDenied)
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	if matchHost(p.denyHosts, host) {
+		return fmt.Errorf("%w: host %s is blocked", ErrDenied, host)
+	}
+	if p.restrict && !matchHost(p.allowHosts, host) {
+		if ip := net.ParseIP(host); ip == nil || !containsIP(p.allowNets, ip) {
+			return fmt.Errorf("%w: host %s is not in the allow list", ErrDenied, host)
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+	return nil
+}
+
+func (p *Policy) checkPort(port string) error {
+	if len(p.ports) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || !p.ports[n] {
+		return fmt.Errorf("%w: port %s is not allowed", ErrDenied, port)
+	}
+	return nil
+}
+
+// checkIP decides whether a connection to ip (resolved from host) is permitted.
+func (p *Policy) checkIP(host string, ip net.IP) error {
+	if containsIP(p.denyNets, ip) {
+		return fmt.Errorf("%w: address %s is blocked", ErrDenied, ip)
+	}
+	if matchHost(p.allowHosts, host) || containsIP(p.allowNets, ip) {
+		return nil
+	}
+	if !p.allowPrivate && IsPrivate(ip) {
+		return fmt.Errorf("%w: %s resolves to non-public address %s", ErrDenied, host, ip)
+	}
+	return nil
+}
+
+// IsPrivate reports whether ip is loopback, private, link-local, multicast or otherwise non-public.
+func IsPrivate(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	return containsIP(reservedRanges, ip)
+}
+
+// DialContext resolves the address itself, validates every candidate IP and dials the
+// validated IP literal. Because the checked address is the one connected to, a DNS answer
+// that changes between check and connect (DNS rebinding) cannot bypass the policy.
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkPort(port); err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := p.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		if err := p.checkIP(strings.ToLower(host), ip); err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// Client returns an HTTP client whose every request, redirect and connection is checked
+// against the policy. Environment proxies are ignored, since they would hide the real destination.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	transport := &http.Transport{
+		DialContext:           p.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &guardedTransport{policy: p, base: transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+// guardedTransport validates request URLs before handing them to the dialing transport.
+type guardedTransport struct {
+	policy *Policy
+	base   http.RoundTripper
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// HTTPClient is a convenience for callers that may not have a policy configured.
+// A nil policy yields a plain client, preserving the previous behavior.
+func HTTPClient(p *Policy, timeout time.Duration) *http.Client {
+	if p == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return p.Client(timeout)
+}
+
+func splitHostsAndNets(entries []string) ([]string, []*net.IPNet, error) {
+	var hosts []string
+	var nets []*net.IPNet
+	for _, e := range entries {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if strings.Contains(e, "/") {
+			_, n, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, nil, err
+			}
+			nets = append(nets, n)
+			continue
+		}
+		if ip := net.ParseIP(e); ip != nil {
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		hosts = append(hosts, e)
+	}
+	return hosts, nets, nil
+}
+
+// matchHost matches host against exact names and "*.domain" wildcards.
+func matchHost(patterns []string, host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "*.") {
+			suffix := p[1:]
+			if strings.HasSuffix(host, suffix) || host == p[2:] {
+				return true
+			}
+			continue
+		}
+		if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
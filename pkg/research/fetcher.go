@@ -0,0 +1,51 @@
+package research
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dev-dhg/yaocc/pkg/fetch"
+)
+
+// NewWebFetcher returns a Fetcher that downloads pages with client (which should enforce
+// the egress policy) and reduces HTML to its readable content.
+func NewWebFetcher(client *http.Client, maxBodyBytes int64) Fetcher {
+	return func(ctx context.Context, url string) (string, string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", "", err
+		}
+		req.Header.Set("User-Agent", "yaocc-fetch/1.0")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", "", fmt.Errorf("status %s", resp.Status)
+		}
+		contentType := resp.Header.Get("Content-Type")
+		if contentType != "" && !strings.Contains(contentType, "html") && !strings.HasPrefix(contentType, "text/") {
+			return "", "", fmt.Errorf("unsupported content type %s", contentType)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+		if err != nil {
+			return "", "", err
+		}
+
+		if !strings.Contains(contentType, "html") {
+			return "", string(body), nil
+		}
+		article, err := fetch.ExtractReadable(string(body), resp.Request.URL.String(), "")
+		if err != nil {
+			return "", "", err
+		}
+		return article.Title, article.Markdown, nil
+	}
+}
@@ -0,0 +1,110 @@
+package research
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minPassageChars skips headings, captions and other fragments too short to be evidence.
+const minPassageChars = 60
+
+var stopWords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"but": true, "can": true, "do": true, "does": true, "for": true, "from": true, "has": true,
+	"have": true, "how": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "not": true, "of": true, "on": true, "or": true, "than": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "which": true,
+	"who": true, "why": true, "will": true, "with": true,
+}
+
+// tokenize lowercases text and splits it into words without stop words.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if len(w) > 1 && !stopWords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func queryTerms(queries []string) map[string]bool {
+	terms := map[string]bool{}
+	for _, q := range queries {
+		for _, t := range tokenize(q) {
+			terms[t] = true
+		}
+	}
+	return terms
+}
+
+// SelectPassages picks the paragraphs of markdown that best match terms, up to budget
+// characters, and returns them in document order.
+func SelectPassages(markdown string, terms map[string]bool, budget int) []string {
+	type scored struct {
+		text  string
+		pos   int
+		score float64
+	}
+
+	if budget <= 0 {
+		return nil
+	}
+
+	var candidates []scored
+	for i, para := range strings.Split(markdown, "\n\n") {
+		para = strings.TrimSpace(para)
+		if len([]rune(para)) < minPassageChars {
+			continue
+		}
+		words := tokenize(para)
+		if len(words) == 0 {
+			continue
+		}
+		matched := map[string]bool{}
+		hits := 0
+		for _, w := range words {
+			if terms[w] {
+				hits++
+				matched[w] = true
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		// Distinct matched terms matter most; frequency helps with diminishing returns,
+		// normalized so long paragraphs are not favored just for their length.
+		score := float64(len(matched)) + math.Log1p(float64(hits))/math.Sqrt(float64(len(words))/50+1)
+		candidates = append(candidates, scored{para, i, score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var picked []scored
+	used := 0
+	for _, c := range candidates {
+		n := len([]rune(c.text))
+		if used+n > budget {
+			if len(picked) > 0 {
+				continue
+			}
+			// Always return something: trim the best passage to the budget
+			c.text = string([]rune(c.text)[:budget]) + "..."
+			n = budget
+		}
+		picked = append(picked, c)
+		used += n
+	}
+
+	sort.Slice(picked, func(i, j int) bool { return picked[i].pos < picked[j].pos })
+	out := make([]string, len(picked))
+	for i, p := range picked {
+		out[i] = p.text
+	}
+	return out
+}
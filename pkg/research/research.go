@@ -0,0 +1,347 @@
+package research
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/llm"
+	"github.com/dev-dhg/yaocc/pkg/websearch"
+)
+
+const (
+	DefaultMaxSubQueries = 3
+	DefaultMaxSources    = 5
+	DefaultTimeout       = 120 * time.Second
+	DefaultMaxTokens     = 6000
+
+	// charsPerToken is a rough estimate used to turn the token budget into a character budget.
+	charsPerToken = 4
+	// fetchConcurrency limits parallel page downloads.
+	fetchConcurrency = 4
+	// synthesisReserve is the share of the time budget kept back for the final LLM call.
+	synthesisReserve = 0.3
+)
+
+// Chatter is the subset of llm.Client used by the pipeline.
+type Chatter interface {
+	Chat(ctx context.Context, messages []llm.Message, tools []llm.Tool) (string, []llm.ToolCall, error)
+}
+
+// Fetcher downloads a page and returns its title and readable Markdown.
+type Fetcher func(ctx context.Context, url string) (title, markdown string, err error)
+
+// Options bound a research run. Zero values use the defaults above.
+type Options struct {
+	MaxSubQueries int
+	MaxSources    int
+	Timeout       time.Duration
+	MaxTokens     int
+	Search        websearch.SearchOptions
+}
+
+// Source is a cited page together with the passages that were given to the model.
+type Source struct {
+	N        int      `json:"n"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Passages []string `json:"passages,omitempty"`
+	Fetched  bool     `json:"fetched"` // False when only the search snippet was available
+}
+
+// Report is the result of a research run.
+type Report struct {
+	Question   string   `json:"question"`
+	Answer     string   `json:"answer"`
+	SubQueries []string `json:"subQueries"`
+	Sources    []Source `json:"sources"`
+	Notes      []string `json:"notes,omitempty"` // Degradations such as failed fetches or a hit time budget
+}
+
+// Researcher runs the search → fetch → extract → synthesize pipeline.
+type Researcher struct {
+	LLM     Chatter
+	Search  websearch.Provider
+	Fetch   Fetcher
+	Options Options
+}
+
+// Run answers question within the configured time and token budget. Failures of
+// individual stages degrade the result (and are listed in Notes) rather than aborting it.
+// Model calls and fetches are aborted when ctx ends.
+func (r *Researcher) Run(ctx context.Context, question string) (*Report, error) {
+	opts := r.withDefaults()
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	// Gathering must leave time for the synthesis call
+	gatherDeadline := start.Add(time.Duration(float64(opts.Timeout) * (1 - synthesisReserve)))
+
+	report := &Report{Question: question}
+
+	report.SubQueries = r.planQueries(ctx, question, opts.MaxSubQueries, report)
+
+	results, err := r.searchAll(report.SubQueries, opts.Search)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no search results for %q", question)
+	}
+	if len(results) > opts.MaxSources {
+		results = results[:opts.MaxSources]
+	}
+
+	gatherCtx, cancel := context.WithDeadline(ctx, gatherDeadline)
+	pages := r.fetchAll(gatherCtx, results, report)
+	cancel()
+
+	terms := queryTerms(append([]string{question}, report.SubQueries...))
+	perSource := opts.MaxTokens * charsPerToken / len(results)
+	for i, res := range results {
+		src := Source{N: i + 1, Title: res.Title, URL: res.Link}
+		if page := pages[i]; page != "" {
+			src.Passages = SelectPassages(page, terms, perSource)
+			src.Fetched = len(src.Passages) > 0
+		}
+		if len(src.Passages) == 0 && res.Snippet != "" {
+			src.Passages = []string{res.Snippet}
+		}
+		report.Sources = append(report.Sources, src)
+	}
+
+	if time.Now().After(deadline) {
+		report.Notes = append(report.Notes, "time budget exhausted before synthesis")
+		report.Answer = extractiveAnswer(report.Sources)
+		return report, nil
+	}
+
+	answer, err := r.synthesize(ctx, question, report.Sources)
+	if err != nil {
+		report.Notes = append(report.Notes, fmt.Sprintf("synthesis failed: %v", err))
+		answer = extractiveAnswer(report.Sources)
+	}
+	report.Answer = answer
+	return report, nil
+}
+
+func (r *Researcher) withDefaults() Options {
+	opts := r.Options
+	if opts.MaxSubQueries <= 0 {
+		opts.MaxSubQueries = DefaultMaxSubQueries
+	}
+	if opts.MaxSources <= 0 {
+		opts.MaxSources = DefaultMaxSources
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	return opts
+}
+
+// planQueries asks the model for complementary search queries. The question itself is always searched.
+func (r *Researcher) planQueries(ctx context.Context, question string, max int, report *Report) []string {
+	queries := []string{question}
+	if max <= 1 || r.LLM == nil {
+		return queries
+	}
+
+	prompt := fmt.Sprintf(`You plan web searches for a research question.
+Return a JSON array of at most %d short, distinct search engine queries that together cover the question (different angles, key entities, recent developments). Return only the JSON array.
+
+Question: %s`, max-1, question)
+
+	resp, _, err := r.LLM.Chat(ctx, []llm.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		report.Notes = append(report.Notes, fmt.Sprintf("query planning failed: %v", err))
+		return queries
+	}
+
+	for _, q := range parseQueries(resp) {
+		if len(queries) >= max {
+			break
+		}
+		if !containsFold(queries, q) {
+			queries = append(queries, q)
+		}
+	}
+	return queries
+}
+
+var jsonArrayRe = regexp.MustCompile(`(?s)\[.*\]`)
+
+// parseQueries accepts a JSON array (possibly wrapped in prose or a code fence) or one query per line.
+func parseQueries(resp string) []string {
+	var list []string
+	if m := jsonArrayRe.FindString(resp); m != "" && json.Unmarshal([]byte(m), &list) == nil {
+		return cleanQueries(list)
+	}
+	for _, line := range strings.Split(resp, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.) ")
+		if line != "" && !strings.HasPrefix(line, "```") {
+			list = append(list, line)
+		}
+	}
+	return cleanQueries(list)
+}
+
+func cleanQueries(list []string) []string {
+	var out []string
+	for _, q := range list {
+		q = strings.Trim(strings.TrimSpace(q), `"`)
+		if q != "" && len(q) <= 200 {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// searchAll runs every query concurrently and fuses the rankings.
+func (r *Researcher) searchAll(queries []string, opts websearch.SearchOptions) ([]websearch.SearchResult, error) {
+	lists := make([][]websearch.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			lists[i], errs[i] = r.Search.Search(q, opts)
+		}(i, q)
+	}
+	wg.Wait()
+
+	ranked := map[string][]websearch.SearchResult{}
+	var order []string
+	var failures []string
+	for i := range queries {
+		if errs[i] != nil {
+			failures = append(failures, errs[i].Error())
+			continue
+		}
+		key := fmt.Sprintf("q%d", i)
+		ranked[key] = lists[i]
+		order = append(order, key)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("search failed: %s", strings.Join(failures, "; "))
+	}
+
+	results := websearch.FuseResults(order, ranked)
+	for i := range results {
+		results[i].Sources = nil // Query keys are internal
+	}
+	return results, nil
+}
+
+// fetchAll downloads the result pages concurrently until ctx expires.
+func (r *Researcher) fetchAll(ctx context.Context, results []websearch.SearchResult, report *Report) []string {
+	pages := make([]string, len(results))
+	if r.Fetch == nil {
+		return pages
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fetchConcurrency)
+	for i, res := range results {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			_, markdown, err := r.Fetch(ctx, link)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Notes = append(report.Notes, fmt.Sprintf("could not fetch %s: %v", link, err))
+				return
+			}
+			pages[i] = markdown
+		}(i, res.Link)
+	}
+	wg.Wait()
+	sort.Strings(report.Notes)
+	return pages
+}
+
+func (r *Researcher) synthesize(ctx context.Context, question string, sources []Source) (string, error) {
+	if r.LLM == nil {
+		return "", fmt.Errorf("no model configured")
+	}
+
+	var sb strings.Builder
+	for _, s := range sources {
+		sb.WriteString(fmt.Sprintf("[%d] %s (%s)\n", s.N, s.Title, s.URL))
+		for _, p := range s.Passages {
+			sb.WriteString(p + "\n\n")
+		}
+	}
+
+	system := `You are a careful research assistant. Answer the question using only the numbered sources provided.
+Cite every factual claim with the source number in square brackets, e.g. [1] or [2][4].
+If the sources disagree, say so. If they do not answer the question, say what is missing instead of guessing.
+Be concise and do not list the sources at the end; they are appended automatically.`
+	user := fmt.Sprintf("Question: %s\n\nSources:\n%s", question, sb.String())
+
+	answer, _, err := r.LLM.Chat(ctx, []llm.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", fmt.Errorf("empty response")
+	}
+	return answer, nil
+}
+
+// extractiveAnswer is used when synthesis is unavailable: the best passage of each source, cited.
+func extractiveAnswer(sources []Source) string {
+	var sb strings.Builder
+	sb.WriteString("Could not synthesize an answer; most relevant passages:\n\n")
+	for _, s := range sources {
+		if len(s.Passages) > 0 {
+			sb.WriteString(fmt.Sprintf("- %s [%d]\n", s.Passages[0], s.N))
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Format renders the report as Markdown with a numbered source list.
+func (rep *Report) Format() string {
+	var sb strings.Builder
+	sb.WriteString(rep.Answer)
+	sb.WriteString("\n\nSources:\n")
+	for _, s := range rep.Sources {
+		sb.WriteString(fmt.Sprintf("[%d] %s - %s\n", s.N, s.Title, s.URL))
+	}
+	if len(rep.Notes) > 0 {
+		sb.WriteString("\nNotes:\n")
+		for _, n := range rep.Notes {
+			sb.WriteString("- " + n + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,488 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/llm"
+	"github.com/dev-dhg/yaocc/pkg/skills"
+	"github.com/dev-dhg/yaocc/pkg/utils"
+)
+
+// The admin API does what the yaocc CLI does on the server's machine: it edits the config file
+// with config.UpdateConfigRawWithPath, so ${VAR} placeholders survive, and applies the result
+// right away instead of waiting for the config watcher.
+
+// configFile is the config file the admin and cron endpoints edit.
+func (s *Server) configFile() string {
+	if s.ConfigPath == "" {
+		return "config.json"
+	}
+	return s.ConfigPath
+}
+
+// configDir is the directory registered skill scripts are resolved against.
+func (s *Server) configDir() string {
+	if s.Agent != nil && s.Agent.ConfigDir() != "" {
+		return s.Agent.ConfigDir()
+	}
+	return config.ResolveConfigDir()
+}
+
+// changeConfig runs update, which writes the config file, then reloads the file and applies it
+// to the agent, the scheduler and the API keys. On failure it writes the error response: update
+// errors are the client's (400), reload errors the server's (500). Edits run one at a time, so
+// concurrent requests cannot overwrite each other's changes.
+func (s *Server) changeConfig(w http.ResponseWriter, update func(path string) error) bool {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	path := s.configFile()
+	if err := config.AcquireConfigLock(); err != nil {
+		log.Printf("Warning: Failed to acquire config lock: %v", err)
+	}
+	if err := update(path); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	newCfg, _, _, err := config.LoadConfig(path)
+	if err != nil {
+		log.Printf("Error reloading config after update: %v", err)
+		http.Error(w, "Config updated, but it could not be reloaded", http.StatusInternalServerError)
+		return false
+	}
+	s.Config = newCfg
+	if s.Agent != nil {
+		s.Agent.UpdateConfig(newCfg)
+	}
+	if s.Scheduler != nil {
+		s.Scheduler.Reload(newCfg)
+	}
+	s.SetAuth(newCfg.Server)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ModelInfo is a configured model as listed by GET /models.
+type ModelInfo struct {
+	ID       string `json:"id"` // provider/modelID, as used by models.selected
+	Provider string `json:"provider"`
+	Name     string `json:"name,omitempty"`
+	Model    string `json:"model,omitempty"` // Name sent to the provider's API
+	Selected bool   `json:"selected"`
+}
+
+type modelRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt,omitempty"` // POST /models/test only
+}
+
+// modelTestTimeout limits the request of POST /models/test.
+const modelTestTimeout = 60 * time.Second
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	models := []ModelInfo{}
+	for key, provider := range s.Config.Models.Providers {
+		for _, m := range provider.Models {
+			id := key + "/" + m.ID
+			models = append(models, ModelInfo{ID: id, Provider: key, Name: m.Name, Model: m.Model, Selected: id == s.Config.Models.Selected})
+		}
+	}
+	sort.Slice(models, func(i, k int) bool { return models[i].ID < models[k].ID })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"selected": s.Config.Models.Selected, "models": models})
+}
+
+// handleModelSelect makes a configured model the one the agent uses.
+func (s *Server) handleModelSelect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req modelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+		http.Error(w, "Invalid request body: model is required", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := s.Config.ResolveModel(req.Model); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ok := s.changeConfig(w, func(path string) error {
+		return config.UpdateConfigRawWithPath(path, func(cfg *config.Config) error {
+			cfg.Models.Selected = req.Model
+			return nil
+		})
+	})
+	if !ok {
+		return
+	}
+	log.Printf("Selected model: %s", req.Model)
+	writeJSON(w, http.StatusOK, map[string]string{"selected": req.Model})
+}
+
+// handleModelTest sends a short prompt to a model (the selected one by default) and reports
+// whether and how fast it answered.
+func (s *Server) handleModelTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req modelRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Model == "" {
+		req.Model = s.Config.Models.Selected
+	}
+	if req.Prompt == "" {
+		req.Prompt = "Reply with the single word OK."
+	}
+	provider, model, err := s.Config.ResolveModel(req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelTestTimeout)
+	defer cancel()
+	start := time.Now()
+	response, _, err := llm.NewClient(provider, model.Model).Chat(ctx, []llm.Message{{Role: "user", Content: req.Prompt}}, nil)
+	result := map[string]interface{}{"model": req.Model, "ok": err == nil, "latencyMs": time.Since(start).Milliseconds()}
+	if err != nil {
+		result["error"] = err.Error()
+		writeJSON(w, http.StatusBadGateway, result)
+		return
+	}
+	result["response"] = response
+	writeJSON(w, http.StatusOK, result)
+}
+
+// SkillInfo describes a skill: one loaded from a SKILL.md, one registered as a script with
+// `yaocc skills register`, or both.
+type SkillInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	BuiltIn     bool     `json:"builtIn"`
+	Enabled     bool     `json:"enabled"`
+	Script      string   `json:"script,omitempty"`  // Registered skills: the script, relative to the config dir
+	Path        string   `json:"path,omitempty"`    // The SKILL.md, if any
+	Content     string   `json:"content,omitempty"` // The SKILL.md body; GET /skills/{name} only
+}
+
+// skillInfos lists the agent's skills and the registered scripts by name.
+func (s *Server) skillInfos() []SkillInfo {
+	byName := make(map[string]*SkillInfo)
+	if s.Agent != nil {
+		for _, sk := range s.Agent.Skills {
+			byName[sk.Name] = &SkillInfo{Name: sk.Name, Description: sk.Description, Tags: sk.Tags, BuiltIn: sk.IsBuiltIn(), Path: sk.Path}
+		}
+	}
+	for name, script := range s.Config.Skills.Registered {
+		info, ok := byName[name]
+		if !ok {
+			info = &SkillInfo{Name: name}
+			byName[name] = info
+		}
+		info.Script = script
+	}
+
+	infos := make([]SkillInfo, 0, len(byName))
+	for _, info := range byName {
+		info.Enabled = s.Config.IsCmdEnabled(info.Name)
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, k int) bool { return infos[i].Name < infos[k].Name })
+	return infos
+}
+
+func (s *Server) findSkill(name string) (SkillInfo, bool) {
+	for _, info := range s.skillInfos() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return SkillInfo{}, false
+}
+
+// handleSkills lists the skills (GET) or registers a script as a skill (POST).
+func (s *Server) handleSkills(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.skillInfos())
+
+	case http.MethodPost:
+		var req struct {
+			Name   string `json:"name"`
+			Script string `json:"script"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Script == "" {
+			http.Error(w, "Invalid request body: name and script are required", http.StatusBadRequest)
+			return
+		}
+		if err := skills.ValidateName(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resolved, err := utils.ResolveSafePath(s.configDir(), req.Script)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := os.Stat(resolved); err != nil {
+			http.Error(w, fmt.Sprintf("Script file '%s' not found", req.Script), http.StatusBadRequest)
+			return
+		}
+
+		_, replaced := s.Config.Skills.Registered[req.Name]
+		ok := s.changeConfig(w, func(path string) error {
+			return config.UpdateConfigRawWithPath(path, func(cfg *config.Config) error {
+				if cfg.Skills.Registered == nil {
+					cfg.Skills.Registered = make(map[string]string)
+				}
+				cfg.Skills.Registered[req.Name] = req.Script
+				return nil
+			})
+		})
+		if !ok {
+			return
+		}
+		log.Printf("Registered skill '%s' -> %s", req.Name, req.Script)
+		status := http.StatusCreated
+		if replaced {
+			status = http.StatusOK
+		}
+		info, _ := s.findSkill(req.Name)
+		writeJSON(w, status, info)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSkill shows a skill with its SKILL.md (GET) or unregisters a registered script (DELETE).
+func (s *Server) handleSkill(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		info, ok := s.findSkill(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Skill not found: %s", name), http.StatusNotFound)
+			return
+		}
+		if info.Path == "" && info.Script != "" {
+			// Registered scripts usually keep their SKILL.md next to them
+			if resolved, err := utils.ResolveSafePath(s.configDir(), info.Script); err == nil {
+				candidate := filepath.Join(filepath.Dir(resolved), "SKILL.md")
+				if _, err := os.Stat(candidate); err == nil {
+					info.Path = candidate
+				}
+			}
+		}
+		if info.Path != "" {
+			if content, err := os.ReadFile(info.Path); err == nil {
+				info.Content = string(content)
+			}
+		}
+		writeJSON(w, http.StatusOK, info)
+
+	case http.MethodDelete:
+		if _, ok := s.Config.Skills.Registered[name]; !ok {
+			http.Error(w, fmt.Sprintf("Skill '%s' is not a registered script", name), http.StatusNotFound)
+			return
+		}
+		info, _ := s.findSkill(name)
+		ok := s.changeConfig(w, func(path string) error {
+			return config.UpdateConfigRawWithPath(path, func(cfg *config.Config) error {
+				if _, exists := cfg.Skills.Registered[name]; !exists {
+					return fmt.Errorf("skill '%s' not found", name)
+				}
+				delete(cfg.Skills.Registered, name)
+				return nil
+			})
+		})
+		if !ok {
+			return
+		}
+		log.Printf("Unregistered skill '%s'", name)
+		writeJSON(w, http.StatusOK, info)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// redacted replaces secrets written literally in the config file. Values that only consist of
+// ${VAR} placeholders are shown, since they hold no secret themselves.
+const redacted = "********"
+
+// secretKeys are the config fields that hold credentials.
+var secretKeys = map[string]bool{
+	"apiKey": true, "authToken": true, "botToken": true, "key": true,
+	"password": true, "secret": true, "token": true, "value": true,
+}
+
+// redactSecrets masks the literal secrets in a config decoded as JSON. Every value of an "env"
+// map (MCP servers) counts as a secret.
+func redactSecrets(v interface{}, secret bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactSecrets(child, secretKeys[k] || k == "env")
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactSecrets(child, secret)
+		}
+	case string:
+		if secret && v != "" && os.Expand(v, func(string) string { return "" }) != "" {
+			return redacted
+		}
+	}
+	return v
+}
+
+// mergePatch applies an RFC 7386 JSON merge patch: objects are merged recursively, null removes
+// a field and anything else replaces it.
+func mergePatch(target, patch map[string]interface{}) {
+	for k, pv := range patch {
+		if pv == nil {
+			delete(target, k)
+			continue
+		}
+		if pm, ok := pv.(map[string]interface{}); ok {
+			tm, ok := target[k].(map[string]interface{})
+			if !ok {
+				tm = make(map[string]interface{})
+			}
+			mergePatch(tm, pm)
+			target[k] = tm
+			continue
+		}
+		target[k] = pv
+	}
+}
+
+func containsRedacted(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if containsRedacted(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if containsRedacted(child) {
+				return true
+			}
+		}
+	case string:
+		return v == redacted
+	}
+	return false
+}
+
+// rawConfigJSON returns the config file as written, placeholders unexpanded and secrets redacted.
+func (s *Server) rawConfigJSON() (map[string]interface{}, error) {
+	cfg, err := config.LoadConfigRaw(s.configFile())
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	redactSecrets(doc, false)
+	return doc, nil
+}
+
+// handleConfig returns the config file (GET) or changes it with a JSON merge patch (PATCH).
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var patch map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+			http.Error(w, "Invalid request body: expected a JSON object", http.StatusBadRequest)
+			return
+		}
+		if containsRedacted(patch) {
+			http.Error(w, "The patch contains redacted values ("+redacted+"); leave secrets out or set them", http.StatusBadRequest)
+			return
+		}
+		ok := s.changeConfig(w, func(path string) error {
+			return config.UpdateConfigRawWithPath(path, func(cfg *config.Config) error {
+				data, err := json.Marshal(cfg)
+				if err != nil {
+					return err
+				}
+				var doc map[string]interface{}
+				if err := json.Unmarshal(data, &doc); err != nil {
+					return err
+				}
+				mergePatch(doc, patch)
+				data, err = json.Marshal(doc)
+				if err != nil {
+					return err
+				}
+				var patched config.Config
+				if err := json.Unmarshal(data, &patched); err != nil {
+					var typeErr *json.UnmarshalTypeError
+					if errors.As(err, &typeErr) {
+						return fmt.Errorf("invalid value for %s: expected %s", typeErr.Field, typeErr.Type)
+					}
+					return fmt.Errorf("invalid config: %w", err)
+				}
+				for _, job := range patched.Cron {
+					if err := job.Validate(); err != nil {
+						return fmt.Errorf("cron job '%s': %w", job.Name, err)
+					}
+				}
+				*cfg = patched
+				return nil
+			})
+		})
+		if !ok {
+			return
+		}
+		log.Printf("Config updated through the API")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc, err := s.rawConfigJSON()
+	if err != nil {
+		log.Printf("Error reading config: %v", err)
+		http.Error(w, "Failed to read config", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
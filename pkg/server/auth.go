@@ -0,0 +1,191 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+)
+
+// API key scopes. ScopeAdmin grants all of them.
+const (
+	ScopeChat  = "chat"
+	ScopeExec  = "exec"
+	ScopeCron  = "cron"
+	ScopeAdmin = "admin"
+)
+
+var validScopes = []string{ScopeChat, ScopeExec, ScopeCron, ScopeAdmin}
+
+// authKey is a configured token with its scopes, hashed so every comparison takes the same time.
+type authKey struct {
+	name   string
+	hash   [32]byte
+	scopes []string
+}
+
+// authKeys returns the usable keys of cfg: server.authToken with every scope, then the API
+// keys. Keys that are empty (e.g. an unset environment variable) are skipped.
+func authKeys(cfg config.ServerConfig) []authKey {
+	var keys []authKey
+	if cfg.AuthToken != "" {
+		keys = append(keys, authKey{name: "authToken", hash: sha256.Sum256([]byte(cfg.AuthToken)), scopes: []string{ScopeAdmin}})
+	}
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" {
+			log.Printf("Warning: API key %q has no key and is ignored", k.Name)
+			continue
+		}
+		for _, scope := range k.Scopes {
+			if !slices.Contains(validScopes, scope) {
+				log.Printf("Warning: API key %q has unknown scope %q (valid: %s)", k.Name, scope, strings.Join(validScopes, ", "))
+			}
+		}
+		keys = append(keys, authKey{name: k.Name, hash: sha256.Sum256([]byte(k.Key)), scopes: k.Scopes})
+	}
+	return keys
+}
+
+// SetAuth replaces the keys accepted by the API, e.g. after the config was reloaded.
+func (s *Server) SetAuth(cfg config.ServerConfig) {
+	keys := authKeys(cfg)
+	s.authMu.Lock()
+	s.keys = keys
+	s.origins = cfg.AllowedOrigins
+	s.authMu.Unlock()
+	if len(keys) == 0 {
+		log.Printf("Warning: no server.authToken or server.apiKeys configured; the API only accepts requests from this machine")
+	}
+}
+
+// requireScope lets a request through if it carries a bearer token with scope. Without any
+// configured keys, only loopback clients are allowed, so a published port is not left open.
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.authMu.RLock()
+		keys := s.keys
+		s.authMu.RUnlock()
+
+		if len(keys) == 0 {
+			if !isLoopback(r.RemoteAddr) {
+				http.Error(w, "Forbidden: configure server.authToken to allow remote access", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="yaocc"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		key := matchKey(keys, token)
+		if key == nil {
+			log.Printf("Rejected %s %s from %s: invalid token", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="yaocc", error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !key.allows(scope) {
+			log.Printf("Rejected %s %s: key %q lacks scope %q", r.Method, r.URL.Path, key.name, scope)
+			http.Error(w, "Forbidden: key lacks the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// hasScope reports whether a request that requireScope let through also carries scope, for
+// handlers where only some requests need more than the route's scope.
+func (s *Server) hasScope(r *http.Request, scope string) bool {
+	s.authMu.RLock()
+	keys := s.keys
+	s.authMu.RUnlock()
+	if len(keys) == 0 {
+		return isLoopback(r.RemoteAddr)
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		return false
+	}
+	key := matchKey(keys, token)
+	return key != nil && key.allows(scope)
+}
+
+// allowQueryToken accepts the token in the access_token query parameter as well, for clients
+// like the browser EventSource and WebSocket APIs that cannot set headers.
+func allowQueryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next(w, r)
+	}
+}
+
+// allowedOrigin protects WebSockets, which browsers open across origins, against other web
+// pages: a request from a browser must come from the server's own origin or one listed in
+// server.allowedOrigins. Clients that send no Origin, such as scripts, are not affected.
+// Without any keys, the server's own origin must be a loopback name too, since a page can point
+// its own domain at 127.0.0.1 (DNS rebinding).
+func (s *Server) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	s.authMu.RLock()
+	defer s.authMu.RUnlock()
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		if len(s.keys) > 0 || u.Hostname() == "localhost" || isLoopback(u.Hostname()) {
+			return true
+		}
+	}
+	for _, allowed := range s.origins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *authKey) allows(scope string) bool {
+	return slices.Contains(k.scopes, scope) || slices.Contains(k.scopes, ScopeAdmin)
+}
+
+// matchKey compares token against every key in constant time and returns the match, if any.
+func matchKey(keys []authKey, token string) *authKey {
+	hash := sha256.Sum256([]byte(token))
+	var match *authKey
+	for i := range keys {
+		if subtle.ConstantTimeCompare(hash[:], keys[i].hash[:]) == 1 && match == nil {
+			match = &keys[i]
+		}
+	}
+	return match
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
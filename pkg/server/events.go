@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// handleSessionEvents streams the agent events of one session: as server-sent events, or as
+// WebSocket text messages if the client asks for an upgrade. Each message is an agent.Event.
+func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Agent == nil || s.Agent.Events == nil {
+		http.Error(w, "Events not available", http.StatusServiceUnavailable)
+		return
+	}
+	session := r.PathValue("id")
+
+	if isWebSocketRequest(r) {
+		if !s.allowedOrigin(r) {
+			log.Printf("Refused WebSocket for session %s from origin %s", session, r.Header.Get("Origin"))
+			http.Error(w, "Forbidden: origin not allowed", http.StatusForbidden)
+			return
+		}
+		events, unsubscribe := s.Agent.Events.Subscribe(session) // Before the handshake completes, so no event is missed
+		defer unsubscribe()
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+		defer ws.Close()
+
+		closed := make(chan struct{})
+		go ws.readLoop(closed)
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-s.closing:
+				ws.writeFrame(wsClose, nil)
+				return
+			case <-ticker.C:
+				if err := ws.writeFrame(wsPing, nil); err != nil {
+					return
+				}
+			case ev := <-events:
+				data, _ := json.Marshal(ev)
+				if err := ws.WriteText(data); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	events, unsubscribe := s.Agent.Events.Subscribe(session)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case ev := <-events:
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+// Chat job states. A job is running until it reaches one of the others.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// jobRetention is how long finished jobs are kept by default.
+const jobRetention = 7 * 24 * time.Hour
+
+// ChatJob is a /chat request answered in the background (POST /chat?async=true).
+type ChatJob struct {
+	ID            string     `json:"id"`
+	Status        string     `json:"status"`
+	SessionID     string     `json:"sessionId"`
+	Provider      string     `json:"provider,omitempty"`
+	ChatID        string     `json:"chatId,omitempty"`
+	Message       string     `json:"message"`
+	Response      string     `json:"response,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	CallbackURL   string     `json:"callbackUrl,omitempty"`
+	CallbackError string     `json:"callbackError,omitempty"` // Why the callback could not be delivered
+	CreatedAt     time.Time  `json:"createdAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+}
+
+func (j *ChatJob) Done() bool { return j.Status != JobRunning }
+
+// JobStore keeps chat jobs in memory and as one JSON file per job in <configDir>/jobs, so
+// results can still be fetched after a restart.
+type JobStore struct {
+	Dir       string
+	Retention time.Duration // How long finished jobs are kept (default jobRetention)
+
+	mu      sync.Mutex
+	jobs    map[string]*ChatJob
+	cancels map[string]context.CancelFunc // Aborts the runs of running jobs
+}
+
+// NewJobStore loads the jobs of earlier runs. Jobs that were still running when the server
+// stopped are marked as failed, and finished jobs older than a week are removed. Later ones are
+// removed as new jobs are created.
+func NewJobStore(configDir string) *JobStore {
+	st := &JobStore{Dir: filepath.Join(configDir, "jobs"), Retention: jobRetention, jobs: make(map[string]*ChatJob), cancels: make(map[string]context.CancelFunc)}
+	entries, err := os.ReadDir(st.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error reading chat jobs: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(st.Dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var job ChatJob
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			log.Printf("Skipping unreadable chat job %s: %v", e.Name(), err)
+			continue
+		}
+		if st.expired(&job) {
+			os.Remove(path)
+			continue
+		}
+		if !job.Done() {
+			now := time.Now()
+			job.Status, job.Error, job.FinishedAt = JobFailed, "interrupted by a server restart", &now
+			if err := st.save(&job); err != nil {
+				log.Printf("Error saving chat job %s: %v", job.ID, err)
+			}
+		}
+		st.jobs[job.ID] = &job
+	}
+	return st
+}
+
+// Create registers a new running job and returns it with its ID. cancel is called if the job is
+// cancelled.
+func (st *JobStore) Create(job ChatJob, cancel context.CancelFunc) (ChatJob, error) {
+	job.ID = randomID()
+	job.Status = JobRunning
+	job.CreatedAt = time.Now()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.prune()
+	if err := st.save(&job); err != nil {
+		return ChatJob{}, err
+	}
+	stored := job
+	st.jobs[job.ID] = &stored
+	st.cancels[job.ID] = cancel
+	return job, nil
+}
+
+// expired reports whether job finished longer than the retention period ago.
+func (st *JobStore) expired(job *ChatJob) bool {
+	retention := st.Retention
+	if retention <= 0 {
+		retention = jobRetention
+	}
+	return job.Done() && job.FinishedAt != nil && time.Since(*job.FinishedAt) > retention
+}
+
+// prune removes expired jobs and their files. Callers hold st.mu.
+func (st *JobStore) prune() {
+	for id, job := range st.jobs {
+		if !st.expired(job) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(st.Dir, id+".json")); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing chat job %s: %v", id, err)
+			continue
+		}
+		delete(st.jobs, id)
+	}
+}
+
+// Get returns a copy of the job.
+func (st *JobStore) Get(id string) (ChatJob, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job, ok := st.jobs[id]
+	if !ok {
+		return ChatJob{}, false
+	}
+	return *job, true
+}
+
+// List returns up to limit jobs, newest first. A limit <= 0 returns all of them.
+func (st *JobStore) List(limit int) []ChatJob {
+	st.mu.Lock()
+	jobs := make([]ChatJob, 0, len(st.jobs))
+	for _, job := range st.jobs {
+		jobs = append(jobs, *job)
+	}
+	st.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].CreatedAt.After(jobs[k].CreatedAt) })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// Finish records the result of a running job. It returns false if the job was cancelled in the
+// meantime, in which case the result is dropped.
+func (st *JobStore) Finish(id, response string, runErr error) (ChatJob, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.cancels, id)
+	job, ok := st.jobs[id]
+	if !ok || job.Done() {
+		if ok {
+			return *job, false
+		}
+		return ChatJob{}, false
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	if runErr != nil {
+		job.Status, job.Error = JobFailed, runErr.Error()
+	} else {
+		job.Status, job.Response = JobSucceeded, response
+	}
+	if err := st.save(job); err != nil {
+		log.Printf("Error saving chat job %s: %v", id, err)
+	}
+	return *job, true
+}
+
+// Cancel marks a running job as cancelled and aborts its run. It fails if the job does not
+// exist or has finished.
+func (st *JobStore) Cancel(id string) (ChatJob, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job, ok := st.jobs[id]
+	if !ok {
+		return ChatJob{}, fmt.Errorf("job not found: %s", id)
+	}
+	if job.Done() {
+		return *job, fmt.Errorf("job already %s", job.Status)
+	}
+	now := time.Now()
+	job.Status, job.FinishedAt = JobCancelled, &now
+	if err := st.save(job); err != nil {
+		log.Printf("Error saving chat job %s: %v", id, err)
+	}
+	if cancel := st.cancels[id]; cancel != nil {
+		cancel()
+		delete(st.cancels, id)
+	}
+	return *job, nil
+}
+
+// SetCallbackError records why the result could not be delivered to the callback URL.
+func (st *JobStore) SetCallbackError(id, msg string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if job, ok := st.jobs[id]; ok {
+		job.CallbackError = msg
+		if err := st.save(job); err != nil {
+			log.Printf("Error saving chat job %s: %v", id, err)
+		}
+	}
+}
+
+// save writes the job atomically. Callers hold st.mu, except during loading.
+func (st *JobStore) save(job *ChatJob) error {
+	if err := os.MkdirAll(st.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(st.Dir, job.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// callbackTimeout limits the POST of a finished job to its callback URL.
+const callbackTimeout = 30 * time.Second
+
+// startChatJob runs req in the background and answers 202 with the job.
+func (s *Server) startChatJob(w http.ResponseWriter, req ChatRequest) {
+	if s.Jobs == nil {
+		http.Error(w, "Async jobs not available", http.StatusServiceUnavailable)
+		return
+	}
+	if req.CallbackURL != "" {
+		if u, err := url.Parse(req.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "Invalid callbackUrl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Not the request's context: the job outlives the request
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err := s.Jobs.Create(ChatJob{
+		SessionID:   req.SessionID,
+		Provider:    req.Provider,
+		ChatID:      req.ChatID,
+		Message:     req.Message,
+		CallbackURL: req.CallbackURL,
+	}, cancel)
+	if err != nil {
+		cancel()
+		log.Printf("Error creating chat job: %v", err)
+		http.Error(w, "Failed to create job", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Started chat job %s for session %s", job.ID, job.SessionID)
+
+	go func() {
+		defer cancel()
+		response, err := s.runChat(ctx, req)
+		finished, ok := s.Jobs.Finish(job.ID, response, err)
+		if !ok {
+			return // Cancelled
+		}
+		if err != nil {
+			log.Printf("Chat job %s failed: %v", job.ID, err)
+		}
+		if finished.CallbackURL == "" {
+			return
+		}
+		if err := s.postJobCallback(finished); err != nil {
+			log.Printf("Error delivering chat job %s to %s: %v", job.ID, finished.CallbackURL, err)
+			s.Jobs.SetCallbackError(job.ID, err.Error())
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// postJobCallback posts the finished job as JSON to its callback URL, subject to the egress policy.
+func (s *Server) postJobCallback(job ChatJob) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	policy, err := netguard.NewPolicy(s.Config.Egress)
+	if err != nil {
+		return fmt.Errorf("invalid egress policy: %w", err)
+	}
+	resp, err := netguard.HTTPClient(policy, callbackTimeout).Post(job.CallbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Jobs == nil {
+		http.Error(w, "Async jobs not available", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Jobs.List(limit))
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		s.handleJobCancel(w, r)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Jobs == nil {
+		http.Error(w, "Async jobs not available", http.StatusServiceUnavailable)
+		return
+	}
+	job, ok := s.Jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Job not found: %s", r.PathValue("id")), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleJobCancel cancels a running job: its run is aborted and no callback is sent.
+func (s *Server) handleJobCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Jobs == nil {
+		http.Error(w, "Async jobs not available", http.StatusServiceUnavailable)
+		return
+	}
+	id := r.PathValue("id")
+	job, err := s.Jobs.Cancel(id)
+	if err != nil {
+		status := http.StatusConflict
+		if _, ok := s.Jobs.Get(id); !ok {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("Cancelled chat job %s", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/llm"
+)
+
+// openAIModel is the single model the OpenAI-compatible endpoints expose: the agent itself,
+// with whatever LLM is selected in the config behind it.
+const openAIModel = "yaocc"
+
+// streamKeepAlive is how often a streaming response sends an SSE comment while the agent is
+// still working, so clients and proxies do not give up during long tool runs.
+const streamKeepAlive = 10 * time.Second
+
+// openAISessionPrefix is prepended to the session IDs clients choose, e.g. "webui" is stored
+// as the session "openai-webui".
+const openAISessionPrefix = "openai-"
+
+// OpenAIChatRequest is the subset of the OpenAI chat completions request yaocc understands.
+// Client-side tools are ignored: the agent runs its own tools on the server.
+type OpenAIChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OpenAIMessage `json:"messages"`
+	Stream   bool            `json:"stream,omitempty"`
+	User     string          `json:"user,omitempty"`
+}
+
+type OpenAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // A string or an array of content parts
+}
+
+// Text returns the message content. Of multi-part content, only the text parts are kept.
+func (m OpenAIMessage) Text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type openAIChoice struct {
+	Index        int             `json:"index"`
+	Message      *openAIResponse `json:"message,omitempty"`
+	Delta        *openAIResponse `json:"delta,omitempty"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+type openAIResponse struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+// openAIUsage is always zero: the agent makes several LLM calls per answer and does not count tokens.
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (s *Server) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		openAIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{
+			{"id": openAIModel, "object": "model", "created": 0, "owned_by": "yaocc"},
+		},
+	})
+}
+
+// handleOpenAIChat answers an OpenAI chat completion with the agent. With a session, from the
+// X-Session-ID header or the user field, the last user message continues that yaocc session and
+// the client's copy of the history and its system messages are ignored. These sessions are kept
+// apart from the others with openAISessionPrefix, so a chat client cannot write to, say, a
+// Telegram chat. Without a session, the client's messages are the history and nothing is stored.
+func (s *Server) handleOpenAIChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		openAIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req OpenAIChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		openAIError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var system []string
+	var history []llm.Message
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, m.Text())
+		case "user", "assistant":
+			history = append(history, llm.Message{Role: m.Role, Content: m.Text()})
+		}
+	}
+	if len(history) == 0 || history[len(history)-1].Role != "user" {
+		openAIError(w, http.StatusBadRequest, "The last message must be from the user")
+		return
+	}
+	input := history[len(history)-1].Content
+	history = history[:len(history)-1]
+
+	sessionID := r.Header.Get("X-Session-ID")
+	if sessionID == "" {
+		sessionID = req.User
+	}
+	if sessionID != "" {
+		if strings.ContainsAny(sessionID, `/\`) {
+			openAIError(w, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+		sessionID = openAISessionPrefix + sessionID
+		if len(system) > 0 {
+			log.Printf("Ignoring %d system messages for session %s: the session uses the agent's system prompt", len(system), sessionID)
+		}
+	}
+	// The run is cancelled if the client disconnects
+	run := func() (string, error) {
+		if sessionID != "" {
+			return s.Agent.Run(r.Context(), sessionID, nil, sessionID, input)
+		}
+		return s.Agent.RunConversation(r.Context(), strings.Join(system, "\n\n"), history, input)
+	}
+
+	model := req.Model
+	if model == "" {
+		model = openAIModel
+	}
+	completion := openAICompletion{ID: "chatcmpl-" + randomID(), Created: time.Now().Unix(), Model: model}
+	if req.Stream {
+		s.streamOpenAIChat(w, r, completion, run)
+		return
+	}
+
+	response, err := run()
+	if err != nil {
+		log.Printf("Agent error: %v", err)
+		openAIError(w, agentErrorStatus(err), err.Error())
+		return
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []openAIChoice{{Message: &openAIResponse{Role: "assistant", Content: response}, FinishReason: &stop}}
+	completion.Usage = &openAIUsage{}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completion)
+}
+
+// streamOpenAIChat sends the answer as server-sent chunks. The agent does not stream tokens, so
+// the role is sent right away, keep-alive comments while it works, and then the whole answer.
+func (s *Server) streamOpenAIChat(w http.ResponseWriter, r *http.Request, completion openAICompletion, run func() (string, error)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		openAIError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	completion.Object = "chat.completion.chunk"
+	send := func(delta openAIResponse, finish *string) {
+		chunk := completion
+		chunk.Choices = []openAIChoice{{Delta: &delta, FinishReason: finish}}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(openAIResponse{Role: "assistant"}, nil)
+
+	type result struct {
+		response string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := run()
+		done <- result{response, err}
+	}()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return // The client went away, which cancels the run
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case res := <-done:
+			if res.err != nil {
+				log.Printf("Agent error: %v", res.err)
+				data, _ := json.Marshal(map[string]interface{}{"error": map[string]string{"message": res.err.Error(), "type": "server_error"}})
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			} else {
+				stop := "stop"
+				send(openAIResponse{Content: res.response}, nil)
+				send(openAIResponse{}, &stop)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// openAIError writes an error in the OpenAI format, which clients show to the user.
+func openAIError(w http.ResponseWriter, status int, message string) {
+	errType := "invalid_request_error"
+	if status >= 500 {
+		errType = "server_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": message, "type": errType}})
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
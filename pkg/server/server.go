@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/agent"
 	"github.com/dev-dhg/yaocc/pkg/config"
@@ -19,45 +24,129 @@ import (
 var openAPIFile embed.FS
 
 type Server struct {
-	Config    *config.Config
-	Agent     *agent.Agent
-	Providers map[string]messaging.Provider
-	Scheduler *cron.Scheduler
+	Config     *config.Config
+	ConfigPath string // Config file updated by the cron management endpoints
+	Agent      *agent.Agent
+	Providers  map[string]messaging.Provider
+	Scheduler  *cron.Scheduler
+	Jobs       *JobStore // Async chat jobs; nil disables ?async=true
+
+	authMu  sync.RWMutex
+	keys    []authKey // Accepted bearer tokens, see SetAuth
+	origins []string  // Allowed WebSocket origins besides the server's own, see SetAuth
+
+	configMu sync.Mutex // Serializes config edits through the API, see changeConfig
+
+	httpMu     sync.Mutex
+	httpServer *http.Server  // Set by Run
+	closing    chan struct{} // Closed when Shutdown starts, to end event streams
+	closeOnce  sync.Once
 }
 
 func NewServer(cfg *config.Config, agt *agent.Agent, providers map[string]messaging.Provider, scheduler *cron.Scheduler) *Server {
-	return &Server{
+	s := &Server{
 		Config:    cfg,
 		Agent:     agt,
 		Providers: providers,
 		Scheduler: scheduler,
+		closing:   make(chan struct{}),
 	}
+	s.SetAuth(cfg.Server)
+	return s
 }
 
-func (s *Server) Run() error {
+// Handler returns the API routes. Everything except the docs and /hooks (which check the
+// job's own secret) requires a bearer token with the route's scope.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// API Endpoints
-	mux.HandleFunc("/chat", s.handleChat)
-	mux.HandleFunc("/exec", s.handleExec)
-	mux.HandleFunc("/cron/run", s.handleCronRun)
+	mux.HandleFunc("/chat", s.requireScope(ScopeChat, s.handleChat))
+	mux.HandleFunc("/chat/{session}/current", s.requireScope(ScopeChat, s.handleStopChat))
+	mux.HandleFunc("/exec", s.requireScope(ScopeExec, s.handleExec))
+	mux.HandleFunc("/cron/run", s.requireScope(ScopeCron, s.handleCronRun))
+	mux.HandleFunc("/cron/jobs", s.requireScope(ScopeCron, s.handleCronJobs))
+	mux.HandleFunc("/cron/jobs/{ref}", s.requireScope(ScopeCron, s.handleCronJob))
+	mux.HandleFunc("/cron/jobs/{ref}/runs", s.requireScope(ScopeCron, s.handleCronRuns))
+	mux.HandleFunc("/cron/jobs/{ref}/run", s.requireScope(ScopeCron, s.handleCronJobRun))
+	mux.HandleFunc("/cron/jobs/{ref}/enable", s.requireScope(ScopeCron, s.handleCronSetEnabled(true)))
+	mux.HandleFunc("/cron/jobs/{ref}/disable", s.requireScope(ScopeCron, s.handleCronSetEnabled(false)))
+	mux.HandleFunc("/sessions/{id}/events", allowQueryToken(s.requireScope(ScopeChat, s.handleSessionEvents)))
+	mux.HandleFunc("/jobs", s.requireScope(ScopeChat, s.handleJobs))
+	mux.HandleFunc("/jobs/{id}", s.requireScope(ScopeChat, s.handleJob))
+	mux.HandleFunc("/jobs/{id}/cancel", s.requireScope(ScopeChat, s.handleJobCancel))
+	mux.HandleFunc("/hooks/{name}", s.handleHook)
+	mux.HandleFunc("/models", s.requireScope(ScopeAdmin, s.handleModels))
+	mux.HandleFunc("/models/select", s.requireScope(ScopeAdmin, s.handleModelSelect))
+	mux.HandleFunc("/models/test", s.requireScope(ScopeAdmin, s.handleModelTest))
+	mux.HandleFunc("/skills", s.requireScope(ScopeAdmin, s.handleSkills))
+	mux.HandleFunc("/skills/{name}", s.requireScope(ScopeAdmin, s.handleSkill))
+	mux.HandleFunc("/config", s.requireScope(ScopeAdmin, s.handleConfig))
+
+	// OpenAI-compatible endpoints for chat frontends
+	mux.HandleFunc("/v1/models", s.requireScope(ScopeChat, s.handleOpenAIModels))
+	mux.HandleFunc("/v1/chat/completions", s.requireScope(ScopeChat, s.handleOpenAIChat))
 
 	// OpenAPI Documentation
 	mux.Handle("/openapi.yaml", http.FileServer(http.FS(openAPIFile)))
 	mux.HandleFunc("/docs", s.handleSwaggerUI)
 
+	return mux
+}
+
+func (s *Server) Run() error {
 	addr := fmt.Sprintf(":%d", s.Config.Server.Port)
 	log.Printf("Server listening on %s", addr)
 	log.Printf("OpenAPI Docs available at http://localhost:%d/docs", s.Config.Server.Port)
 
-	return http.ListenAndServe(addr, mux)
+	srv := &http.Server{Addr: addr, Handler: s.Handler()}
+	s.httpMu.Lock()
+	s.httpServer = srv
+	s.httpMu.Unlock()
+
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// shutdownGrace is how long requests whose runs were cancelled by Shutdown get to answer.
+const shutdownGrace = 5 * time.Second
+
+// Shutdown stops accepting connections and waits for the agent's in-flight turns (chat
+// requests, async jobs, chat messages and cron runs) to finish. If ctx ends first, the
+// remaining turns are cancelled. Run returns once Shutdown has started.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	s.httpMu.Lock()
+	srv := s.httpServer
+	s.httpMu.Unlock()
+
+	httpCtx, cancelHTTP := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHTTP()
+	httpDone := make(chan error, 1)
+	if srv != nil {
+		go func() { httpDone <- srv.Shutdown(httpCtx) }()
+	} else {
+		httpDone <- nil
+	}
+
+	var agentErr error
+	if s.Agent != nil {
+		agentErr = s.Agent.Shutdown(ctx)
+	}
+	timer := time.AfterFunc(shutdownGrace, cancelHTTP)
+	defer timer.Stop()
+	return errors.Join(agentErr, <-httpDone)
 }
 
 type ChatRequest struct {
-	SessionID string `json:"sessionId,omitempty"`
-	Provider  string `json:"provider,omitempty"`
-	ChatID    string `json:"chatId,omitempty"`
-	Message   string `json:"message"`
+	SessionID   string `json:"sessionId,omitempty"`
+	Provider    string `json:"provider,omitempty"`
+	ChatID      string `json:"chatId,omitempty"`
+	Message     string `json:"message"`
+	CallbackURL string `json:"callbackUrl,omitempty"` // Async only: receives the finished job as JSON
 }
 
 type ChatResponse struct {
@@ -76,62 +165,108 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req = normalizeChatRequest(req)
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		s.startChatJob(w, req)
+		return
+	}
+
+	// The run is cancelled if the client disconnects
+	response, err := s.runChat(r.Context(), req)
+	if err != nil {
+		log.Printf("Agent error: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(agentErrorStatus(err))
+		json.NewEncoder(w).Encode(ChatResponse{Error: err.Error()})
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChatResponse{Response: response})
+}
+
+// normalizeChatRequest fills in the provider, session and chat ID defaults of a chat request.
+func normalizeChatRequest(req ChatRequest) ChatRequest {
 	// Default to "local" provider if not specified
-	provider := req.Provider
-	if provider == "" {
-		provider = "local"
+	if req.Provider == "" {
+		req.Provider = "local"
 	}
 
-	sessionID := req.SessionID
-	if sessionID == "" {
-		sessionID = "general"
+	if req.SessionID == "" {
+		req.SessionID = "general"
 	}
 
 	// Default ChatID to SessionID if not specified (for backward compatibility / convenience)
-	chatID := req.ChatID
-	if chatID == "" {
-		chatID = sessionID
+	if req.ChatID == "" {
+		req.ChatID = req.SessionID
 	}
 
 	// If SessionID is default ("general") BUT provider and chatID are set,
 	// construct a session ID from them to avoid collision with general session.
-	if sessionID == "general" && provider != "local" && chatID != "" {
-		sessionID = fmt.Sprintf("%s-%s", provider, chatID)
+	if req.SessionID == "general" && req.Provider != "local" && req.ChatID != "" {
+		req.SessionID = fmt.Sprintf("%s-%s", req.Provider, req.ChatID)
 	}
+	return req
+}
 
+// runChat answers a normalized chat request with the agent until ctx ends.
+func (s *Server) runChat(ctx context.Context, req ChatRequest) (string, error) {
 	var providerObj messaging.Provider
 	if s.Providers != nil {
-		providerObj = s.Providers[provider]
+		providerObj = s.Providers[req.Provider]
+	}
+
+	if s.Scheduler != nil {
+		s.Scheduler.MatchMessage(req.Provider, req.ChatID, req.Message)
 	}
 
-	response, err := s.Agent.Run(sessionID, providerObj, chatID, req.Message)
+	response, err := s.Agent.Run(ctx, req.SessionID, providerObj, req.ChatID, req.Message)
 	if err != nil {
-		log.Printf("Agent error: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ChatResponse{Error: err.Error()})
-		return
+		return "", err
 	}
 
 	// If provider is NOT local, we should also send the response to the provider
 	// This helps in simulation scenarios where we want the actual provider to send the message
-	if provider != "local" && s.Providers != nil {
-		if prov, ok := s.Providers[provider]; ok {
-			log.Printf("Injecting response to provider %s (ID: %s)", provider, chatID)
+	if req.Provider != "local" && s.Providers != nil {
+		if prov, ok := s.Providers[req.Provider]; ok {
+			log.Printf("Injecting response to provider %s (ID: %s)", req.Provider, req.ChatID)
 			go func() {
 				// Send async to not block API response
-				if err := prov.SendMessage(chatID, response); err != nil {
-					log.Printf("Error sending injected message to %s: %v", provider, err)
+				if err := prov.SendMessage(req.ChatID, response); err != nil {
+					log.Printf("Error sending injected message to %s: %v", req.Provider, err)
 				}
 			}()
 		} else {
-			log.Printf("Warning: Provider '%s' not found for injection", provider)
+			log.Printf("Warning: Provider '%s' not found for injection", req.Provider)
 		}
 	}
+	return response, nil
+}
 
+// agentErrorStatus is the HTTP status for a failed agent run.
+func agentErrorStatus(err error) int {
+	if errors.Is(err, agent.ErrShuttingDown) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// handleStopChat stops the turns in progress for a session, like /stop in a chat.
+func (s *Server) handleStopChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := r.PathValue("session")
+	stopped := s.Agent.Stop(session)
+	if stopped == 0 {
+		http.Error(w, fmt.Sprintf("No run in progress for session %s", session), http.StatusNotFound)
+		return
+	}
+	log.Printf("Stopped %d run(s) of session %s", stopped, session)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ChatResponse{Response: response})
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessionId": session, "stopped": stopped})
 }
 
 func (s *Server) handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
@@ -146,7 +281,8 @@ func (s *Server) handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
 }
 
 type CronRunRequest struct {
-	Index int `json:"index"`
+	Job   string `json:"job,omitempty"`   // Job ID or name
+	Index *int   `json:"index,omitempty"` // Deprecated: position in the cron list, shifts when jobs are removed
 }
 
 func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
@@ -164,7 +300,8 @@ func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		// Try query parameter
+		// Try query parameters
+		req.Job = r.URL.Query().Get("job")
 		indexStr := r.URL.Query().Get("index")
 		if indexStr != "" {
 			idx, err := strconv.Atoi(indexStr)
@@ -172,7 +309,7 @@ func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Invalid index parameter", http.StatusBadRequest)
 				return
 			}
-			req.Index = idx
+			req.Index = &idx
 		}
 	}
 
@@ -181,13 +318,51 @@ func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Index < 0 || req.Index >= len(s.Config.Cron) {
-		http.Error(w, fmt.Sprintf("Invalid job index: %d (available: 0-%d)", req.Index, len(s.Config.Cron)-1), http.StatusBadRequest)
+	jobs := s.Scheduler.Config.Cron
+	var job config.CronJob
+	switch {
+	case req.Job != "":
+		found, ok := s.Scheduler.FindJob(req.Job)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Job not found: %s", req.Job), http.StatusNotFound)
+			return
+		}
+		job = found
+	case req.Index != nil:
+		if *req.Index < 0 || *req.Index >= len(jobs) {
+			http.Error(w, fmt.Sprintf("Invalid job index: %d (available: 0-%d)", *req.Index, len(jobs)-1), http.StatusBadRequest)
+			return
+		}
+		job = jobs[*req.Index]
+	default:
+		http.Error(w, "Job ID or name is required", http.StatusBadRequest)
 		return
 	}
 
-	job := s.Config.Cron[req.Index]
-	log.Printf("Manually triggered cron job: %s (index: %d)", job.Name, req.Index)
+	s.triggerCronJob(w, job)
+}
+
+func (s *Server) handleCronJobRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Scheduler == nil {
+		http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	job, ok := s.Scheduler.FindJob(r.PathValue("ref"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Job not found: %s", r.PathValue("ref")), http.StatusNotFound)
+		return
+	}
+	s.triggerCronJob(w, job)
+}
+
+// triggerCronJob starts job in the background and answers 202 Accepted.
+func (s *Server) triggerCronJob(w http.ResponseWriter, job config.CronJob) {
+	log.Printf("Manually triggered cron job: %s (id: %s)", job.Name, job.JobID())
 
 	// Run asynchronously so the API returns immediately
 	go s.Scheduler.RunJob(job)
@@ -197,6 +372,270 @@ func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "accepted",
 		"job":    job.Name,
+		"id":     job.JobID(),
+	})
+}
+
+// handleCronJobs lists the jobs (GET) or adds one to the config file (POST).
+func (s *Server) handleCronJobs(w http.ResponseWriter, r *http.Request) {
+	if s.Scheduler == nil {
+		http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Scheduler.Jobs())
+
+	case http.MethodPost:
+		var job config.CronJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if runsCommands(job.Script, job.Digest, job.Trigger) && !s.hasScope(r, ScopeExec) {
+			http.Error(w, execScopeError, http.StatusForbidden)
+			return
+		}
+		if job.Schedule != "" {
+			if err := cron.ValidateSchedule(job.Schedule); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		ok := s.changeConfig(w, func(path string) error {
+			var err error
+			job, err = config.AddCronJob(path, job)
+			return err
+		})
+		if !ok {
+			return
+		}
+		log.Printf("Added cron job: %s (id: %s)", job.Name, job.ID)
+
+		st, _ := s.Scheduler.Status(job.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/cron/jobs/"+job.ID)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(st)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// execScopeError refuses cron changes that need the exec scope.
+const execScopeError = "Forbidden: scripts and triggers need the exec scope"
+
+// runsCommands reports whether a job body sets something that runs commands on the server or
+// lets others start it: a script, digest script sources or an event trigger. Creating or
+// changing these needs the exec scope besides cron, so a cron key cannot run arbitrary commands.
+func runsCommands(script string, digest []config.CronDigestSource, trigger *config.CronTrigger) bool {
+	if script != "" || trigger != nil {
+		return true
+	}
+	for _, src := range digest {
+		if src.Script != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleCronRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Scheduler == nil {
+		http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	job, ok := s.Scheduler.FindJob(r.PathValue("ref"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Job not found: %s", r.PathValue("ref")), http.StatusNotFound)
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	runs, err := s.Scheduler.History.Runs(job.JobID(), limit)
+	if err != nil {
+		log.Printf("Error reading run history of %s: %v", job.Name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// handleCronJob shows (GET), edits (PATCH) or removes (DELETE) a single job.
+func (s *Server) handleCronJob(w http.ResponseWriter, r *http.Request) {
+	if s.Scheduler == nil {
+		http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+	ref := r.PathValue("ref")
+
+	switch r.Method {
+	case http.MethodGet:
+		st, ok := s.Scheduler.Status(ref)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Job not found: %s", ref), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+
+	case http.MethodPatch:
+		var patch config.CronJobPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var script string
+		var digest []config.CronDigestSource
+		if patch.Script != nil {
+			script = *patch.Script
+		}
+		if patch.Digest != nil {
+			digest = *patch.Digest
+		}
+		if runsCommands(script, digest, patch.Trigger) && !s.hasScope(r, ScopeExec) {
+			http.Error(w, execScopeError, http.StatusForbidden)
+			return
+		}
+		s.updateCronJob(w, ref, patch)
+
+	case http.MethodDelete:
+		if _, ok := s.Scheduler.FindJob(ref); !ok {
+			http.Error(w, fmt.Sprintf("Job not found: %s", ref), http.StatusNotFound)
+			return
+		}
+		var job config.CronJob
+		ok := s.changeConfig(w, func(path string) error {
+			var err error
+			job, err = config.RemoveCronJob(path, ref)
+			return err
+		})
+		if !ok {
+			return
+		}
+		log.Printf("Removed cron job: %s (id: %s)", job.Name, job.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleCronSetEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.Scheduler == nil {
+			http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+			return
+		}
+		s.updateCronJob(w, r.PathValue("ref"), config.CronJobPatch{Enabled: &enabled})
+	}
+}
+
+// updateCronJob persists patch to the config file and reschedules the jobs right away
+// instead of waiting for the config watcher.
+func (s *Server) updateCronJob(w http.ResponseWriter, ref string, patch config.CronJobPatch) {
+	if patch.Schedule != nil {
+		if err := cron.ValidateSchedule(*patch.Schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := s.Scheduler.FindJob(ref); !ok {
+		http.Error(w, fmt.Sprintf("Job not found: %s", ref), http.StatusNotFound)
+		return
+	}
+
+	var job config.CronJob
+	ok := s.changeConfig(w, func(path string) error {
+		var err error
+		job, err = config.UpdateCronJob(path, ref, patch)
+		return err
+	})
+	if !ok {
+		return
+	}
+	log.Printf("Updated cron job: %s (id: %s)", job.Name, job.ID)
+
+	st, _ := s.Scheduler.Status(job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleHook fires the webhook-triggered job with the given name or ID. The request body is
+// passed to the job as the event payload. Callers prove themselves with the job's secret, and
+// with an API token with the cron scope if the job has no secret or sets requireToken.
+func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Scheduler == nil {
+		http.Error(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.PathValue("name")
+	job, ok := s.Scheduler.FindJob(name)
+	if !ok || !job.IsEnabled() || job.Trigger == nil || job.Trigger.Type != "webhook" {
+		http.Error(w, fmt.Sprintf("Hook not found: %s", name), http.StatusNotFound)
+		return
+	}
+	if job.Trigger.Secret == "" || job.Trigger.RequireToken {
+		s.requireScope(ScopeCron, func(w http.ResponseWriter, r *http.Request) { s.fireHook(w, r, job) })(w, r)
+		return
+	}
+	s.fireHook(w, r, job)
+}
+
+func (s *Server) fireHook(w http.ResponseWriter, r *http.Request, job config.CronJob) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, cron.MaxWebhookBody+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > cron.MaxWebhookBody {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if job.Trigger.Secret != "" && !cron.VerifyWebhook(*job.Trigger, body, r.Header, r.URL.Query()) {
+		log.Printf("Rejected webhook for job %s: invalid secret or signature", job.Name)
+		http.Error(w, "Invalid secret or signature", http.StatusUnauthorized)
+		return
+	}
+
+	log.Printf("Webhook triggered cron job: %s (id: %s)", job.Name, job.JobID())
+	go s.Scheduler.RunEvent(job, cron.WebhookEvent(body, r.URL.Query()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "accepted",
+		"job":    job.Name,
+		"id":     job.JobID(),
 	})
 }
 
@@ -31,6 +31,23 @@ func (s *Skill) IsBuiltIn() bool {
 	return false
 }
 
+// reservedNames are CLI commands a registered skill cannot shadow.
+var reservedNames = map[string]bool{
+	"register": true, "unregister": true, "list": true, "help": true,
+	"file": true, "cron": true, "chat": true, "model": true, "init": true, "fetch": true, "websearch": true, "skills": true, "prompt": true,
+}
+
+// ValidateName checks that name can be used for a registered skill.
+func ValidateName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t/\\") {
+		return fmt.Errorf("invalid skill name '%s'", name)
+	}
+	if reservedNames[strings.ToLower(name)] {
+		return fmt.Errorf("'%s' is a reserved command name", name)
+	}
+	return nil
+}
+
 type Loader struct {
 	Paths []string
 }
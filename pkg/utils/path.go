@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveSafePath ensures that the path is within the config directory and is not a sensitive file.
+func ResolveSafePath(configDir, inputPath string) (string, error) {
+	// 1. Join with configDir
+	fullPath := filepath.Join(configDir, inputPath)
+
+	// 2. Clean path
+	cleanPath := filepath.Clean(fullPath)
+
+	// 3. Check for path escape
+	absConfigDir, _ := filepath.Abs(configDir)
+	absPath, _ := filepath.Abs(cleanPath)
+
+	if absPath != absConfigDir && !strings.HasPrefix(absPath, absConfigDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("access denied: path escapes configuration directory")
+	}
+
+	// 4. Blacklist Check
+	baseName := filepath.Base(absPath)
+	if baseName == "config.json" || baseName == ".env" || baseName == "agent.log" {
+		return "", fmt.Errorf("access denied: cannot access sensitive configuration file '%s'", baseName)
+	}
+
+	return absPath, nil
+}
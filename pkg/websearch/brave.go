@@ -8,9 +8,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 )
 
 const braveUsageFile = "brave_usage.json"
@@ -23,14 +25,17 @@ type BraveProvider struct {
 	AllProviders map[string]config.SearchProvider
 	TempDir      string
 	MaxResults   int
+	Fallback     string
+	Policy       *netguard.Policy
 }
 
+// BraveUsage is the format of the legacy brave_usage.json counter file.
 type BraveUsage struct {
 	LastRequest time.Time `json:"lastRequest"`
 	Count       int       `json:"count"`
 }
 
-func NewBraveProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string) (*BraveProvider, error) {
+func NewBraveProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (*BraveProvider, error) {
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("brave provider '%s' requires an API key", name)
 	}
@@ -38,6 +43,9 @@ func NewBraveProvider(name string, cfg config.SearchProvider, allProviders map[s
 	if maxResults <= 0 {
 		maxResults = 5
 	}
+	if tempDir != "" {
+		migrateLegacyUsage(name, tempDir)
+	}
 	return &BraveProvider{
 		Name:         name,
 		APIKey:       cfg.APIKey,
@@ -45,58 +53,46 @@ func NewBraveProvider(name string, cfg config.SearchProvider, allProviders map[s
 		AllProviders: allProviders,
 		TempDir:      tempDir,
 		MaxResults:   maxResults,
+		Fallback:     cfg.Fallback,
+		Policy:       policy,
 	}, nil
 }
 
-func (p *BraveProvider) Search(query string) ([]SearchResult, error) {
-	if p.FreeTier.Enabled {
-		allowed, err := p.checkMonthlyQuota()
-		if err != nil {
-			return nil, fmt.Errorf("failed to check rate limit: %w", err)
-		}
-
-		if !allowed {
-			if p.FreeTier.Fallback != "" {
-				fallbackCfg, ok := p.AllProviders[p.FreeTier.Fallback]
-				if !ok {
-					return nil, fmt.Errorf("fallback provider '%s' not found", p.FreeTier.Fallback)
-				}
-				// create fallback provider
-				fallbackProvider, err := NewProvider(p.FreeTier.Fallback, fallbackCfg, p.AllProviders, p.TempDir)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create fallback provider: %w", err)
-				}
-				return fallbackProvider.Search(query)
-			}
-			return nil, fmt.Errorf("brave free tier limit reached and no fallback configured")
-		}
-
-		// Enforce 1 RPS limit (Wait if needed)
-		if err := p.enforceOneRequestPerSecond(); err != nil {
-			// Log error but proceed? Or fail?
-			// Proceeding seems safer as file read error shouldn't block search if API might work.
-			// But let's log it.
-			fmt.Printf("Warning: failed to enforce rate limit delay: %v\n", err)
-		}
-	}
-
-	results, err := p.performSearch(query)
+// Search queries Brave. The free tier's monthly budget and 1 RPS limit are enforced by the
+// QuotaProvider that NewProvider wraps around it.
+func (p *BraveProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	results, err := p.performSearch(query, opts)
 	if err != nil {
-		return nil, err
+		return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
 	}
-
-	if p.FreeTier.Enabled {
-		if err := p.incrementUsage(); err != nil {
-			// Log error but don't fail search? For now print to stdout/stderr or ignore
-			fmt.Printf("Warning: failed to update usage: %v\n", err)
-		}
-	}
-
 	return results, nil
 }
 
-func (p *BraveProvider) performSearch(query string) ([]SearchResult, error) {
-	endpoint := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d", url.QueryEscape(query), p.MaxResults)
+func (p *BraveProvider) performSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	vertical := "web"
+	if opts.Category != "" {
+		vertical = opts.Category
+	}
+	params := url.Values{}
+	params.Set("q", opts.QueryWithSites(query))
+	params.Set("count", fmt.Sprintf("%d", p.MaxResults))
+	if opts.Freshness != "" && vertical != "images" {
+		params.Set("freshness", "p"+opts.Freshness[:1]) // pd, pw, pm, py
+	}
+	if opts.Language != "" {
+		params.Set("search_lang", opts.Language)
+	}
+	if opts.Region != "" {
+		params.Set("country", strings.ToUpper(opts.Region))
+	}
+	if opts.SafeSearch != "" {
+		safe := opts.SafeSearch
+		if vertical == "images" && safe == "moderate" {
+			safe = "strict" // The image vertical only knows off/strict
+		}
+		params.Set("safesearch", safe)
+	}
+	endpoint := fmt.Sprintf("https://api.search.brave.com/res/v1/%s/search?%s", vertical, params.Encode())
 
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
@@ -106,9 +102,7 @@ func (p *BraveProvider) performSearch(query string) ([]SearchResult, error) {
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("X-Subscription-Token", p.APIKey)
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -133,8 +127,11 @@ func (p *BraveProvider) performSearch(query string) ([]SearchResult, error) {
 	}
 
 	// Brave API response structure
-	// We want data.web.results
+	// Web search: data.web.results; news/images/videos verticals: data.results
 	webData, ok := data["web"].(map[string]interface{})
+	if vertical != "web" {
+		webData, ok = data, true
+	}
 	if !ok {
 		// handle case where no web results
 		return []SearchResult{}, nil
@@ -158,6 +155,11 @@ func (p *BraveProvider) performSearch(query string) ([]SearchResult, error) {
 			// Example says "description".
 			content, _ := item["description"].(string)
 			urlStr, _ := item["url"].(string)
+			if props, ok := item["properties"].(map[string]interface{}); ok && vertical == "images" {
+				if imgURL, ok := props["url"].(string); ok && imgURL != "" {
+					content = "Image: " + imgURL
+				}
+			}
 
 			results = append(results, SearchResult{
 				Title:   title,
@@ -170,101 +172,24 @@ func (p *BraveProvider) performSearch(query string) ([]SearchResult, error) {
 	return results, nil
 }
 
-func (p *BraveProvider) getUsageFilePath() string {
-	// If we want one file per provider instance, we could include name.
-	// But user said "brave.json", and monthly limit is usually per token/account.
-	// Let's assume one global "brave_usage.json" for now, or use name if multiple braves?
-	// The requirement said "brave.json or something".
-	return filepath.Join(p.TempDir, braveUsageFile)
-}
-
-func (p *BraveProvider) checkMonthlyQuota() (bool, error) {
-	usagePath := p.getUsageFilePath()
-	if _, err := os.Stat(usagePath); os.IsNotExist(err) {
-		return true, nil
-	}
-
-	data, err := os.ReadFile(usagePath)
-	if err != nil {
-		return false, err
-	}
-
-	var usage BraveUsage
-	if err := json.Unmarshal(data, &usage); err != nil {
-		// if corrupted, maybe reset? or fail.
-		return false, err
-	}
-
-	now := time.Now()
-	// Check if new month
-	if now.Month() != usage.LastRequest.Month() || now.Year() != usage.LastRequest.Year() {
-		return true, nil // Should reset on next update
-	}
-
-	if usage.Count >= braveFreeTierLimit {
-		return false, nil
-	}
-
-	return true, nil
-}
-
-func (p *BraveProvider) incrementUsage() error {
-	usagePath := p.getUsageFilePath()
-	now := time.Now()
-
-	var usage BraveUsage
-
-	if _, err := os.Stat(usagePath); err == nil {
-		data, err := os.ReadFile(usagePath)
-		if err == nil {
-			_ = json.Unmarshal(data, &usage)
-		}
-	}
-
-	// Check reset
-	if now.Month() != usage.LastRequest.Month() || now.Year() != usage.LastRequest.Year() {
-		usage.Count = 0
-	}
-
-	usage.Count++
-	usage.LastRequest = now
-
-	// Write back
-	data, err := json.MarshalIndent(usage, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Ensure temp dir exists
-	if err := os.MkdirAll(p.TempDir, 0755); err != nil {
-		return err
-	}
-
-	return os.WriteFile(usagePath, data, 0644)
-}
-
-func (p *BraveProvider) enforceOneRequestPerSecond() error {
-	usagePath := p.getUsageFilePath()
-	if _, err := os.Stat(usagePath); os.IsNotExist(err) {
-		return nil
-	}
-
-	data, err := os.ReadFile(usagePath)
+// migrateLegacyUsage imports the counter kept in brave_usage.json by earlier versions into
+// the shared quota file, so upgrading mid-month does not reset the free tier budget.
+func migrateLegacyUsage(name, tempDir string) {
+	legacyPath := filepath.Join(tempDir, braveUsageFile)
+	data, err := os.ReadFile(legacyPath)
 	if err != nil {
-		return err
+		return
 	}
-
-	var usage BraveUsage
-	if err := json.Unmarshal(data, &usage); err != nil {
-		return err
+	var legacy BraveUsage
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		// Keep the file, so its counts are not lost and it can be fixed by hand
+		fmt.Fprintf(os.Stderr, "Warning: failed to import %s: %v\n", legacyPath, err)
+		return
 	}
-
-	const requiredWait = 1100 * time.Millisecond
-	elapsed := time.Since(usage.LastRequest)
-	if elapsed < requiredWait {
-		sleepDuration := requiredWait - elapsed
-		time.Sleep(sleepDuration)
+	usage := Usage{LastRequest: legacy.LastRequest, Month: legacy.LastRequest.Format("2006-01"), MonthCount: legacy.Count}
+	if err := NewQuotaManager(tempDir).Seed(name, usage); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to import %s: %v\n", legacyPath, err)
+		return
 	}
-
-	return nil
+	os.Remove(legacyPath)
 }
@@ -0,0 +1,54 @@
+package websearch
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/httpcache"
+)
+
+// DefaultSearchTTL is how long search results are reused when no TTL is configured.
+const DefaultSearchTTL = time.Hour
+
+// CachedProvider reuses recent results for identical queries, saving quota on paid backends.
+type CachedProvider struct {
+	Provider Provider
+	Name     string
+	Cache    *httpcache.Cache
+	TTL      time.Duration
+}
+
+// WithCache wraps p with the cache configured in cfg. It returns p unchanged when c is nil.
+func WithCache(p Provider, name string, c *httpcache.Cache, cfg config.CacheConfig) Provider {
+	if c == nil {
+		return p
+	}
+	ttl := DefaultSearchTTL
+	if cfg.SearchTTLMinutes > 0 {
+		ttl = time.Duration(cfg.SearchTTLMinutes) * time.Minute
+	}
+	return &CachedProvider{Provider: p, Name: name, Cache: c, TTL: ttl}
+}
+
+func (p *CachedProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	key := "websearch:" + p.Name + ":" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if optsJSON, err := json.Marshal(opts); err == nil && string(optsJSON) != "{}" {
+		key += ":" + string(optsJSON)
+	}
+
+	var results []SearchResult
+	if p.Cache.GetJSON(key, &results) {
+		return results, nil
+	}
+
+	results, err := p.Provider.Search(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > 0 {
+		_ = p.Cache.PutJSON(key, results, p.TTL)
+	}
+	return results, nil
+}
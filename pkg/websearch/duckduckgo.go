@@ -0,0 +1,159 @@
+package websearch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/fetch"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+const duckDuckGoEndpoint = "https://html.duckduckgo.com/html/"
+
+var duckDuckGoSafeSearch = map[string]string{"off": "-2", "moderate": "-1", "strict": "1"}
+
+// DuckDuckGoProvider scrapes the DuckDuckGo HTML results page. It needs no API key,
+// which makes it a reasonable last-resort fallback, but the markup may change without notice.
+type DuckDuckGoProvider struct {
+	Name         string
+	Endpoint     string
+	MaxResults   int
+	Fallback     string
+	AllProviders map[string]config.SearchProvider
+	TempDir      string
+	Policy       *netguard.Policy
+}
+
+func NewDuckDuckGoProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) *DuckDuckGoProvider {
+	maxResults := cfg.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = duckDuckGoEndpoint
+	}
+	return &DuckDuckGoProvider{
+		Name:         name,
+		Endpoint:     endpoint,
+		MaxResults:   maxResults,
+		Fallback:     cfg.Fallback,
+		AllProviders: allProviders,
+		TempDir:      tempDir,
+		Policy:       policy,
+	}
+}
+
+func (p *DuckDuckGoProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	results, err := p.performSearch(query, opts)
+	if err != nil {
+		return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
+	}
+	return results, nil
+}
+
+func (p *DuckDuckGoProvider) performSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	form := url.Values{}
+	form.Set("q", opts.QueryWithSites(query))
+	if opts.Freshness != "" {
+		form.Set("df", opts.Freshness[:1]) // d, w, m, y
+	}
+	if opts.Region != "" {
+		lang := opts.Language
+		if lang == "" {
+			lang = "en"
+		}
+		form.Set("kl", opts.Region+"-"+lang)
+	}
+	if level, ok := duckDuckGoSafeSearch[opts.SafeSearch]; ok {
+		form.Set("kp", level)
+	}
+
+	req, err := http.NewRequest("POST", p.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// The HTML endpoint rejects requests without a browser-like user agent
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	return ParseDuckDuckGoHTML(string(body), p.MaxResults)
+}
+
+// ParseDuckDuckGoHTML extracts organic results (ads are skipped) from a DuckDuckGo HTML results page.
+func ParseDuckDuckGoHTML(doc string, maxResults int) ([]SearchResult, error) {
+	root := fetch.ParseHTML(doc)
+
+	var results []SearchResult
+	for _, node := range root.Select("div.result") {
+		if len(results) >= maxResults {
+			break
+		}
+		if node.HasClass("result--ad") {
+			continue
+		}
+		links := node.Select("a.result__a")
+		if len(links) == 0 {
+			continue
+		}
+		link := decodeDuckDuckGoLink(links[0].Attrs["href"])
+		if link == "" {
+			continue
+		}
+		var snippet string
+		if s := node.Select(".result__snippet"); len(s) > 0 {
+			snippet = strings.Join(strings.Fields(s[0].TextContent()), " ")
+		}
+		results = append(results, SearchResult{
+			Title:   strings.Join(strings.Fields(links[0].TextContent()), " "),
+			Snippet: snippet,
+			Link:    link,
+		})
+	}
+
+	if len(results) == 0 && (len(root.Select("form#challenge-form")) > 0 || strings.Contains(doc, "anomaly-modal")) {
+		return nil, fmt.Errorf("duckduckgo returned a bot challenge instead of results")
+	}
+	return results, nil
+}
+
+// decodeDuckDuckGoLink unwraps DuckDuckGo's redirect links ("//duckduckgo.com/l/?uddg=<target>").
+func decodeDuckDuckGoLink(href string) string {
+	if strings.HasPrefix(href, "//") {
+		href = "https:" + href
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if strings.HasSuffix(u.Hostname(), "duckduckgo.com") && strings.HasPrefix(u.Path, "/l/") {
+		if target := u.Query().Get("uddg"); target != "" {
+			return target
+		}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,170 @@
+package websearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+const googleEndpoint = "https://www.googleapis.com/customsearch/v1"
+
+// googleMaxNum is the largest page size the Custom Search JSON API accepts.
+const googleMaxNum = 10
+
+// GoogleProvider uses the Google Programmable Search (Custom Search JSON) API.
+type GoogleProvider struct {
+	Name         string
+	APIKey       string
+	EngineID     string
+	Endpoint     string
+	MaxResults   int
+	Fallback     string
+	AllProviders map[string]config.SearchProvider
+	TempDir      string
+	Policy       *netguard.Policy
+}
+
+func NewGoogleProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (*GoogleProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("google provider '%s' requires an API key", name)
+	}
+	if cfg.EngineID == "" {
+		return nil, fmt.Errorf("google provider '%s' requires an engineId (cx)", name)
+	}
+	maxResults := cfg.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = googleEndpoint
+	}
+	return &GoogleProvider{
+		Name:         name,
+		APIKey:       cfg.APIKey,
+		EngineID:     cfg.EngineID,
+		Endpoint:     strings.TrimSuffix(endpoint, "/"),
+		MaxResults:   maxResults,
+		Fallback:     cfg.Fallback,
+		AllProviders: allProviders,
+		TempDir:      tempDir,
+		Policy:       policy,
+	}, nil
+}
+
+func (p *GoogleProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	results, err := p.performSearch(query, opts)
+	if err != nil {
+		return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
+	}
+	return results, nil
+}
+
+func (p *GoogleProvider) performSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	num := p.MaxResults
+	if num > googleMaxNum {
+		num = googleMaxNum
+	}
+	params := url.Values{}
+	params.Set("key", p.APIKey)
+	params.Set("cx", p.EngineID)
+	params.Set("num", strconv.Itoa(num))
+
+	// siteSearch takes a single domain; anything more goes into the query as operators
+	if len(opts.Sites) == 1 && len(opts.ExcludeSites) == 0 {
+		params.Set("siteSearch", opts.Sites[0])
+		params.Set("siteSearchFilter", "i")
+	} else if len(opts.Sites) == 0 && len(opts.ExcludeSites) == 1 {
+		params.Set("siteSearch", opts.ExcludeSites[0])
+		params.Set("siteSearchFilter", "e")
+	} else {
+		query = opts.QueryWithSites(query)
+	}
+	params.Set("q", query)
+
+	if opts.Freshness != "" {
+		params.Set("dateRestrict", opts.Freshness[:1]+"1") // d1, w1, m1, y1
+	}
+	if opts.Language != "" {
+		params.Set("lr", "lang_"+opts.Language)
+		params.Set("hl", opts.Language)
+	}
+	if opts.Region != "" {
+		params.Set("gl", opts.Region)
+	}
+	switch opts.SafeSearch {
+	case "strict", "moderate":
+		params.Set("safe", "active")
+	case "off":
+		params.Set("safe", "off")
+	}
+	switch opts.Category {
+	case "images":
+		params.Set("searchType", "image")
+	case "news", "videos":
+		// Programmable Search has no news/video vertical; bias the query instead
+		params.Set("q", query+" "+opts.Category)
+	}
+
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
+	resp, err := client.Get(p.Endpoint + "?" + params.Encode())
+	if err != nil {
+		// The URL carries the API key; don't echo it back in the error
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	// Google response structure:
+	// { "items": [ { "title": "...", "link": "...", "snippet": "..." } ], "error": { "message": "..." } }
+	var data struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status code %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if data.Error != nil {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, data.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	var results []SearchResult
+	for _, item := range data.Items {
+		if len(results) >= p.MaxResults {
+			break
+		}
+		results = append(results, SearchResult{
+			Title:   item.Title,
+			Snippet: item.Snippet,
+			Link:    item.Link,
+		})
+	}
+	return results, nil
+}
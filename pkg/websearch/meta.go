@@ -0,0 +1,184 @@
+package websearch
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+// rrfK dampens the influence of top ranks in reciprocal-rank fusion (the usual constant from the literature).
+const rrfK = 60
+
+// MetaMember is one provider queried by a MetaProvider.
+type MetaMember struct {
+	Name     string
+	Provider Provider
+}
+
+// MetaProvider queries several providers concurrently and fuses their rankings.
+// Each member keeps its own quota tracking, rate limiting and fallback.
+type MetaProvider struct {
+	Name       string
+	Members    []MetaMember
+	MaxResults int
+}
+
+func NewMetaProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (*MetaProvider, error) {
+	if len(cfg.Providers) == 0 {
+		return nil, fmt.Errorf("meta provider '%s' requires a list of providers", name)
+	}
+	maxResults := cfg.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+
+	p := &MetaProvider{Name: name, MaxResults: maxResults}
+	for _, member := range cfg.Providers {
+		memberCfg, ok := allProviders[member]
+		if !ok {
+			return nil, fmt.Errorf("meta provider '%s': provider '%s' not found", name, member)
+		}
+		if memberCfg.Type == "meta" {
+			return nil, fmt.Errorf("meta provider '%s': provider '%s' cannot itself be a meta provider", name, member)
+		}
+		provider, err := NewProvider(member, memberCfg, allProviders, tempDir, policy)
+		if err != nil {
+			return nil, fmt.Errorf("meta provider '%s': %w", name, err)
+		}
+		p.Members = append(p.Members, MetaMember{Name: member, Provider: provider})
+	}
+	return p, nil
+}
+
+func (p *MetaProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	lists := make([][]SearchResult, len(p.Members))
+	errs := make([]error, len(p.Members))
+
+	var wg sync.WaitGroup
+	for i, m := range p.Members {
+		wg.Add(1)
+		go func(i int, m MetaMember) {
+			defer wg.Done()
+			lists[i], errs[i] = m.Provider.Search(query, opts)
+		}(i, m)
+	}
+	wg.Wait()
+
+	ranked := make(map[string][]SearchResult)
+	var order []string
+	var failures []string
+	for i, m := range p.Members {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", m.Name, errs[i]))
+			continue
+		}
+		ranked[m.Name] = lists[i]
+		order = append(order, m.Name)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("all providers failed: %s", strings.Join(failures, "; "))
+	}
+
+	results := FuseResults(order, ranked)
+	if len(results) > p.MaxResults {
+		results = results[:p.MaxResults]
+	}
+	return results, nil
+}
+
+// FuseResults merges ranked lists with reciprocal-rank fusion. Results pointing to the
+// same normalized URL are merged, and every provider that returned them is listed in Sources.
+// order fixes the provider precedence used to break ties.
+func FuseResults(order []string, ranked map[string][]SearchResult) []SearchResult {
+	type fused struct {
+		result SearchResult
+		score  float64
+		first  int // Position of first appearance, for stable tie-breaking
+	}
+	byURL := map[string]*fused{}
+	var all []*fused
+
+	seen := 0
+	for _, name := range order {
+		for rank, r := range ranked[name] {
+			key := NormalizeURL(r.Link)
+			if key == "" {
+				continue
+			}
+			f, ok := byURL[key]
+			if !ok {
+				f = &fused{result: r, first: seen}
+				f.result.Sources = nil
+				byURL[key] = f
+				all = append(all, f)
+			}
+			seen++
+			f.score += 1.0 / float64(rrfK+rank+1)
+			if !containsString(f.result.Sources, name) {
+				f.result.Sources = append(f.result.Sources, name)
+			}
+			// Keep the most informative snippet
+			if len(r.Snippet) > len(f.result.Snippet) {
+				f.result.Snippet = r.Snippet
+			}
+			if f.result.Title == "" {
+				f.result.Title = r.Title
+			}
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].first < all[j].first
+	})
+
+	results := make([]SearchResult, 0, len(all))
+	for _, f := range all {
+		results = append(results, f.result)
+	}
+	return results
+}
+
+// NormalizeURL reduces a link to a canonical form for deduplication: lowercase host
+// without "www.", no fragment, no tracking parameters, sorted query and no trailing slash.
+func NormalizeURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(link)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	q := u.Query()
+	for k := range q {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "utm_") || lk == "ref" || lk == "fbclid" || lk == "gclid" {
+			q.Del(k)
+		}
+	}
+
+	normalized := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := q.Encode(); encoded != "" { // Encode sorts by key
+		normalized += "?" + encoded
+	}
+	return normalized
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package websearch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SearchOptions narrows a search. Every field is optional; providers map what they
+// support onto native parameters and ignore the rest.
+type SearchOptions struct {
+	Freshness    string   `json:"freshness,omitempty"`    // "day", "week", "month" or "year"
+	Sites        []string `json:"sites,omitempty"`        // Only return results from these domains
+	ExcludeSites []string `json:"excludeSites,omitempty"` // Never return results from these domains
+	Language     string   `json:"language,omitempty"`     // ISO 639-1 code, e.g. "en"
+	Region       string   `json:"region,omitempty"`       // ISO 3166-1 alpha-2 code, e.g. "us"
+	SafeSearch   string   `json:"safeSearch,omitempty"`   // "off", "moderate" or "strict"
+	Category     string   `json:"category,omitempty"`     // "web" (default), "news", "images" or "videos"
+
+	tried []string // Providers that already failed this search, see searchFallback
+}
+
+var (
+	validFreshness  = []string{"day", "week", "month", "year"}
+	validSafeSearch = []string{"off", "moderate", "strict"}
+	validCategories = []string{"web", "news", "images", "videos"}
+)
+
+// Validate normalizes the options and rejects values no provider understands.
+func (o *SearchOptions) Validate() error {
+	o.Freshness = strings.ToLower(strings.TrimSpace(o.Freshness))
+	o.SafeSearch = strings.ToLower(strings.TrimSpace(o.SafeSearch))
+	o.Category = strings.ToLower(strings.TrimSpace(o.Category))
+	o.Language = strings.ToLower(strings.TrimSpace(o.Language))
+	o.Region = strings.ToLower(strings.TrimSpace(o.Region))
+
+	if o.Freshness != "" && !containsString(validFreshness, o.Freshness) {
+		return fmt.Errorf("invalid freshness %q (expected one of: %s)", o.Freshness, strings.Join(validFreshness, ", "))
+	}
+	if o.SafeSearch != "" && !containsString(validSafeSearch, o.SafeSearch) {
+		return fmt.Errorf("invalid safesearch %q (expected one of: %s)", o.SafeSearch, strings.Join(validSafeSearch, ", "))
+	}
+	if o.Category == "web" {
+		o.Category = ""
+	}
+	if o.Category != "" && !containsString(validCategories, o.Category) {
+		return fmt.Errorf("invalid category %q (expected one of: %s)", o.Category, strings.Join(validCategories, ", "))
+	}
+	for i, s := range o.Sites {
+		o.Sites[i] = cleanSite(s)
+	}
+	for i, s := range o.ExcludeSites {
+		o.ExcludeSites[i] = cleanSite(s)
+	}
+	return nil
+}
+
+// QueryWithSites appends site: operators for backends without native domain filters.
+func (o SearchOptions) QueryWithSites(query string) string {
+	return o.queryWithSites(query, o.Sites, o.ExcludeSites)
+}
+
+func (o SearchOptions) queryWithSites(query string, include, exclude []string) string {
+	var parts []string
+	for _, s := range include {
+		parts = append(parts, "site:"+s)
+	}
+	if len(parts) > 1 {
+		query += " (" + strings.Join(parts, " OR ") + ")"
+	} else if len(parts) == 1 {
+		query += " " + parts[0]
+	}
+	for _, s := range exclude {
+		query += " -site:" + s
+	}
+	return query
+}
+
+// Locale returns "language-REGION" (e.g. "en-US"), or whichever half is set.
+func (o SearchOptions) Locale() string {
+	switch {
+	case o.Language != "" && o.Region != "":
+		return o.Language + "-" + strings.ToUpper(o.Region)
+	case o.Language != "":
+		return o.Language
+	default:
+		return strings.ToUpper(o.Region)
+	}
+}
+
+func cleanSite(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+	return strings.TrimSuffix(s, "/")
+}
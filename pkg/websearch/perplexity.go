@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 )
 
 type PerplexityProvider struct {
@@ -18,9 +20,10 @@ type PerplexityProvider struct {
 	Fallback     string
 	AllProviders map[string]config.SearchProvider
 	TempDir      string
+	Policy       *netguard.Policy
 }
 
-func NewPerplexityProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string) (*PerplexityProvider, error) {
+func NewPerplexityProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (*PerplexityProvider, error) {
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("perplexity provider '%s' requires an API key", name)
 	}
@@ -35,36 +38,42 @@ func NewPerplexityProvider(name string, cfg config.SearchProvider, allProviders
 		Fallback:     cfg.Fallback,
 		AllProviders: allProviders,
 		TempDir:      tempDir,
+		Policy:       policy,
 	}, nil
 }
 
-func (p *PerplexityProvider) Search(query string) ([]SearchResult, error) {
-	results, err := p.performSearch(query)
+func (p *PerplexityProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	results, err := p.performSearch(query, opts)
 	if err != nil {
-		if p.Fallback != "" {
-			fmt.Printf("Perplexity search failed: %v. Using fallback provider: %s\n", err, p.Fallback)
-			fallbackCfg, ok := p.AllProviders[p.Fallback]
-			if !ok {
-				return nil, fmt.Errorf("search failed and fallback provider '%s' not found: %w", p.Fallback, err)
-			}
-			fallbackProvider, err := NewProvider(p.Fallback, fallbackCfg, p.AllProviders, p.TempDir)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create fallback provider: %w", err)
-			}
-			return fallbackProvider.Search(query)
-		}
-		return nil, err
+		return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
 	}
 	return results, nil
 }
 
-func (p *PerplexityProvider) performSearch(query string) ([]SearchResult, error) {
+func (p *PerplexityProvider) performSearch(query string, opts SearchOptions) ([]SearchResult, error) {
 	url := "https://api.perplexity.ai/search"
 
 	payload := map[string]interface{}{
 		"query":       query,
 		"max_results": p.MaxResults,
 	}
+	var domains []string
+	domains = append(domains, opts.Sites...)
+	for _, site := range opts.ExcludeSites {
+		domains = append(domains, "-"+site) // A leading dash excludes the domain
+	}
+	if len(domains) > 0 {
+		payload["search_domain_filter"] = domains
+	}
+	if opts.Freshness != "" {
+		payload["search_recency_filter"] = opts.Freshness
+	}
+	if opts.Region != "" {
+		payload["country"] = strings.ToUpper(opts.Region)
+	}
+	if opts.Language != "" {
+		payload["search_language_filter"] = []string{opts.Language}
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
@@ -79,9 +88,7 @@ func (p *PerplexityProvider) performSearch(query string) ([]SearchResult, error)
 	req.Header.Add("Authorization", "Bearer "+p.APIKey)
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
 
 	resp, err := client.Do(req)
 	if err != nil {
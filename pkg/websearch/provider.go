@@ -2,29 +2,91 @@ package websearch
 
 import (
 	"fmt"
+	"os"
+	"slices"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 )
 
 type SearchResult struct {
-	Title   string `json:"title"`
-	Snippet string `json:"snippet"`
-	Link    string `json:"link"`
+	Title   string   `json:"title"`
+	Snippet string   `json:"snippet"`
+	Link    string   `json:"link"`
+	Sources []string `json:"sources,omitempty"` // Providers that returned this result (meta search)
 }
 
 type Provider interface {
-	Search(query string) ([]SearchResult, error)
+	Search(query string, opts SearchOptions) ([]SearchResult, error)
 }
 
-func NewProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string) (Provider, error) {
+// NewProvider creates the provider configured under name. policy restricts outbound
+// connections and may be nil. Providers with a quota are wrapped in a QuotaProvider.
+func NewProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (Provider, error) {
+	provider, err := newBackend(name, cfg, allProviders, tempDir, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := EffectiveQuota(cfg)
+	if limits.IsZero() || cfg.Type == "meta" {
+		return provider, nil // Meta members enforce their own quotas
+	}
+	fallback := cfg.Fallback
+	if cfg.FreeTier.Fallback != "" {
+		fallback = cfg.FreeTier.Fallback
+	}
+	return &QuotaProvider{
+		Name:         name,
+		Provider:     provider,
+		Limits:       limits,
+		Manager:      NewQuotaManager(tempDir),
+		Fallback:     fallback,
+		AllProviders: allProviders,
+		TempDir:      tempDir,
+		Policy:       policy,
+	}, nil
+}
+
+func newBackend(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (Provider, error) {
 	switch cfg.Type {
 	case "searxng":
-		return NewSearxNGProvider(cfg), nil
+		return NewSearxNGProvider(cfg, policy), nil
 	case "brave":
-		return NewBraveProvider(name, cfg, allProviders, tempDir)
+		return NewBraveProvider(name, cfg, allProviders, tempDir, policy)
 	case "perplexity":
-		return NewPerplexityProvider(name, cfg, allProviders, tempDir)
+		return NewPerplexityProvider(name, cfg, allProviders, tempDir, policy)
+	case "tavily":
+		return NewTavilyProvider(name, cfg, allProviders, tempDir, policy)
+	case "google":
+		return NewGoogleProvider(name, cfg, allProviders, tempDir, policy)
+	case "duckduckgo":
+		return NewDuckDuckGoProvider(name, cfg, allProviders, tempDir, policy), nil
+	case "meta":
+		return NewMetaProvider(name, cfg, allProviders, tempDir, policy)
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", cfg.Type)
 	}
 }
+
+// searchFallback retries query with the named fallback provider after the primary failed with err.
+// A fallback that the search already went through ends the chain, so a cycle cannot loop forever.
+func searchFallback(primary, fallback string, err error, query string, opts SearchOptions, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) ([]SearchResult, error) {
+	if fallback == "" {
+		return nil, err
+	}
+	opts.tried = append(slices.Clone(opts.tried), primary)
+	if slices.Contains(opts.tried, fallback) {
+		return nil, fmt.Errorf("search failed and fallback provider '%s' was already tried: %w", fallback, err)
+	}
+	fmt.Fprintf(os.Stderr, "%s search failed (%v), using fallback provider: %s\n", primary, err, fallback)
+	fallbackCfg, ok := allProviders[fallback]
+	if !ok {
+		return nil, fmt.Errorf("search failed and fallback provider '%s' not found: %w", fallback, err)
+	}
+	fallbackProvider, ferr := NewProvider(fallback, fallbackCfg, allProviders, tempDir, policy)
+	if ferr != nil {
+		return nil, fmt.Errorf("failed to create fallback provider: %w", ferr)
+	}
+	return fallbackProvider.Search(query, opts)
+}
@@ -0,0 +1,258 @@
+package websearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+	"github.com/dev-dhg/yaocc/pkg/utils"
+)
+
+const quotaFile = "websearch_quota.json"
+
+// ErrQuotaExhausted is returned (wrapped in a *QuotaError) when a provider used up a window.
+var ErrQuotaExhausted = errors.New("quota exhausted")
+
+// QuotaError reports which provider and window ran out.
+type QuotaError struct {
+	Provider string
+	Window   string // "day" or "month"
+	Limit    int
+	ResetAt  time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s quota of %d requests exhausted (resets %s)", e.Provider, e.Window, e.Limit, e.ResetAt.Format("2006-01-02 15:04"))
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExhausted }
+
+// Usage holds the persisted counters of one provider.
+type Usage struct {
+	LastRequest time.Time `json:"lastRequest"`
+	Day         string    `json:"day"` // 2006-01-02
+	DayCount    int       `json:"dayCount"`
+	Month       string    `json:"month"` // 2006-01
+	MonthCount  int       `json:"monthCount"`
+}
+
+// QuotaStatus is a snapshot of a provider's remaining budget. Remaining values are -1 when unlimited.
+type QuotaStatus struct {
+	Provider       string             `json:"provider"`
+	Limits         config.QuotaConfig `json:"limits"`
+	UsedToday      int                `json:"usedToday"`
+	RemainingToday int                `json:"remainingToday"`
+	UsedThisMonth  int                `json:"usedThisMonth"`
+	RemainingMonth int                `json:"remainingMonth"`
+	LastRequest    time.Time          `json:"lastRequest,omitempty"`
+	DayResetsAt    time.Time          `json:"dayResetsAt"`
+	MonthResetsAt  time.Time          `json:"monthResetsAt"`
+}
+
+// QuotaManager tracks request counts for all providers in a single JSON file under the
+// temp dir. Every update happens under a file lock so concurrent CLI processes (e.g. a
+// meta search or several cron jobs) share the same counters.
+type QuotaManager struct {
+	Path string
+	Now  func() time.Time // Overridable for tests
+}
+
+func NewQuotaManager(tempDir string) *QuotaManager {
+	return &QuotaManager{Path: filepath.Join(tempDir, quotaFile), Now: time.Now}
+}
+
+// EffectiveQuota returns the limits that apply to a provider. Brave's free tier implies
+// 1 request per second and 2000 per month unless explicit limits are configured.
+func EffectiveQuota(cfg config.SearchProvider) config.QuotaConfig {
+	q := cfg.Quota
+	if cfg.Type == "brave" && cfg.FreeTier.Enabled {
+		if q.PerSecond == 0 {
+			q.PerSecond = 1
+		}
+		if q.PerMonth == 0 {
+			q.PerMonth = braveFreeTierLimit
+		}
+	}
+	return q
+}
+
+// Acquire reserves one request for provider. It returns a *QuotaError if a daily or monthly
+// window is exhausted, and otherwise blocks as needed to respect the per-second rate.
+func (m *QuotaManager) Acquire(provider string, limits config.QuotaConfig) error {
+	if limits.IsZero() {
+		return nil
+	}
+
+	var wait time.Duration
+	err := m.update(func(all map[string]*Usage) error {
+		now := m.Now()
+		u := usageFor(all, provider, now)
+
+		if limits.PerDay > 0 && u.DayCount >= limits.PerDay {
+			return &QuotaError{Provider: provider, Window: "day", Limit: limits.PerDay, ResetAt: nextDay(now)}
+		}
+		if limits.PerMonth > 0 && u.MonthCount >= limits.PerMonth {
+			return &QuotaError{Provider: provider, Window: "month", Limit: limits.PerMonth, ResetAt: nextMonth(now)}
+		}
+
+		slot := now
+		if limits.PerSecond > 0 && !u.LastRequest.IsZero() {
+			// Small safety margin, providers measure the window on their side
+			interval := time.Second/time.Duration(limits.PerSecond) + 100*time.Millisecond
+			if next := u.LastRequest.Add(interval); next.After(now) {
+				slot = next
+			}
+		}
+		// Reserve the slot before sleeping so concurrent callers queue up behind it
+		u.LastRequest = slot
+		u.DayCount++
+		u.MonthCount++
+		wait = slot.Sub(now)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// Status reports usage for provider without modifying it.
+func (m *QuotaManager) Status(provider string, limits config.QuotaConfig) (QuotaStatus, error) {
+	all, err := m.load()
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+	now := m.Now()
+	u := usageFor(all, provider, now)
+
+	st := QuotaStatus{
+		Provider:       provider,
+		Limits:         limits,
+		UsedToday:      u.DayCount,
+		UsedThisMonth:  u.MonthCount,
+		RemainingToday: -1,
+		RemainingMonth: -1,
+		LastRequest:    u.LastRequest,
+		DayResetsAt:    nextDay(now),
+		MonthResetsAt:  nextMonth(now),
+	}
+	if limits.PerDay > 0 {
+		st.RemainingToday = max(limits.PerDay-u.DayCount, 0)
+	}
+	if limits.PerMonth > 0 {
+		st.RemainingMonth = max(limits.PerMonth-u.MonthCount, 0)
+	}
+	return st, nil
+}
+
+// Seed records usage counted elsewhere (e.g. a legacy counter file) if the provider has none yet.
+func (m *QuotaManager) Seed(provider string, usage Usage) error {
+	return m.update(func(all map[string]*Usage) error {
+		if _, ok := all[provider]; !ok {
+			all[provider] = &usage
+		}
+		return nil
+	})
+}
+
+func (m *QuotaManager) update(fn func(map[string]*Usage) error) error {
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+		return err
+	}
+	unlock, err := utils.LockFile(m.Path, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	all, err := m.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(all); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.Path)
+}
+
+func (m *QuotaManager) load() (map[string]*Usage, error) {
+	all := map[string]*Usage{}
+	data, err := os.ReadFile(m.Path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("corrupt quota file %s: %w", m.Path, err)
+	}
+	return all, nil
+}
+
+// usageFor returns the provider's counters with expired windows reset.
+func usageFor(all map[string]*Usage, provider string, now time.Time) *Usage {
+	u, ok := all[provider]
+	if !ok {
+		u = &Usage{}
+		all[provider] = u
+	}
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day, u.DayCount = day, 0
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.MonthCount = month, 0
+	}
+	return u
+}
+
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+func nextMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+}
+
+// QuotaProvider enforces a provider's limits and switches to its fallback once a window is exhausted.
+type QuotaProvider struct {
+	Name         string
+	Provider     Provider
+	Limits       config.QuotaConfig
+	Manager      *QuotaManager
+	Fallback     string
+	AllProviders map[string]config.SearchProvider
+	TempDir      string
+	Policy       *netguard.Policy
+}
+
+func (p *QuotaProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	if err := p.Manager.Acquire(p.Name, p.Limits); err != nil {
+		var qerr *QuotaError
+		if errors.As(err, &qerr) {
+			return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
+		}
+		// A broken counter file should not take search down with it
+		fmt.Fprintf(os.Stderr, "Warning: failed to track quota for %s: %v\n", p.Name, err)
+	}
+	return p.Provider.Search(query, opts)
+}
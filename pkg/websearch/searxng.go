@@ -10,31 +10,55 @@ import (
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
 )
 
+var searxngSafeSearch = map[string]string{"off": "0", "moderate": "1", "strict": "2"}
+
 type SearxNGProvider struct {
 	Endpoint   string
 	MaxResults int
+	Policy     *netguard.Policy
 }
 
-func NewSearxNGProvider(cfg config.SearchProvider) *SearxNGProvider {
+func NewSearxNGProvider(cfg config.SearchProvider, policy *netguard.Policy) *SearxNGProvider {
 	maxResults := cfg.MaxResults
 	if maxResults <= 0 {
 		maxResults = 5
 	}
+	// SearxNG is commonly self-hosted on the LAN; the configured endpoint is trusted.
+	if policy != nil {
+		if u, err := url.Parse(cfg.Endpoint); err == nil && u.Hostname() != "" {
+			policy = policy.Trust(strings.ToLower(u.Hostname()))
+		}
+	}
 	return &SearxNGProvider{
 		Endpoint:   cfg.Endpoint,
 		MaxResults: maxResults,
+		Policy:     policy,
 	}
 }
 
-func (p *SearxNGProvider) Search(query string) ([]SearchResult, error) {
+func (p *SearxNGProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
 	baseURL := strings.TrimSuffix(p.Endpoint, "/")
-	endpoint := fmt.Sprintf("%s/search?format=json&q=%s", baseURL, url.QueryEscape(query))
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("q", opts.QueryWithSites(query))
+	if opts.Freshness != "" {
+		params.Set("time_range", opts.Freshness)
 	}
+	if locale := opts.Locale(); locale != "" {
+		params.Set("language", locale)
+	}
+	if level, ok := searxngSafeSearch[opts.SafeSearch]; ok {
+		params.Set("safesearch", level)
+	}
+	if opts.Category != "" {
+		params.Set("categories", opts.Category)
+	}
+	endpoint := fmt.Sprintf("%s/search?%s", baseURL, params.Encode())
+
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
 
 	resp, err := client.Get(endpoint)
 	if err != nil {
@@ -0,0 +1,131 @@
+package websearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+const tavilyEndpoint = "https://api.tavily.com"
+
+type TavilyProvider struct {
+	Name         string
+	APIKey       string
+	Endpoint     string
+	MaxResults   int
+	Fallback     string
+	AllProviders map[string]config.SearchProvider
+	TempDir      string
+	Policy       *netguard.Policy
+}
+
+func NewTavilyProvider(name string, cfg config.SearchProvider, allProviders map[string]config.SearchProvider, tempDir string, policy *netguard.Policy) (*TavilyProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("tavily provider '%s' requires an API key", name)
+	}
+	maxResults := cfg.MaxResults
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = tavilyEndpoint
+	}
+	return &TavilyProvider{
+		Name:         name,
+		APIKey:       cfg.APIKey,
+		Endpoint:     strings.TrimSuffix(endpoint, "/"),
+		MaxResults:   maxResults,
+		Fallback:     cfg.Fallback,
+		AllProviders: allProviders,
+		TempDir:      tempDir,
+		Policy:       policy,
+	}, nil
+}
+
+func (p *TavilyProvider) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	results, err := p.performSearch(query, opts)
+	if err != nil {
+		return searchFallback(p.Name, p.Fallback, err, query, opts, p.AllProviders, p.TempDir, p.Policy)
+	}
+	return results, nil
+}
+
+func (p *TavilyProvider) performSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	payload := map[string]interface{}{
+		"query":        query,
+		"max_results":  p.MaxResults,
+		"search_depth": "basic",
+	}
+	if opts.Category == "news" {
+		payload["topic"] = "news"
+	}
+	if opts.Freshness != "" {
+		payload["time_range"] = opts.Freshness
+	}
+	if len(opts.Sites) > 0 {
+		payload["include_domains"] = opts.Sites
+	}
+	if len(opts.ExcludeSites) > 0 {
+		payload["exclude_domains"] = opts.ExcludeSites
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.Endpoint+"/search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+p.APIKey)
+	req.Header.Add("Content-Type", "application/json")
+
+	client := netguard.HTTPClient(p.Policy, 30*time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Tavily response structure:
+	// { "results": [ { "title": "...", "url": "...", "content": "...", "score": 0.9 } ] }
+	var data struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var results []SearchResult
+	for _, item := range data.Results {
+		if len(results) >= p.MaxResults {
+			break
+		}
+		results = append(results, SearchResult{
+			Title:   item.Title,
+			Snippet: item.Content,
+			Link:    item.URL,
+		})
+	}
+	return results, nil
+}
@@ -1,12 +1,31 @@
 package test
 
 import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dev-dhg/yaocc/pkg/agent"
 	"github.com/dev-dhg/yaocc/pkg/config"
 	"github.com/dev-dhg/yaocc/pkg/cron"
+	"github.com/dev-dhg/yaocc/pkg/llm"
 	"github.com/dev-dhg/yaocc/pkg/messaging"
 )
 
@@ -61,3 +80,925 @@ func TestNewScheduler_Timezone(t *testing.T) {
 		}
 	})
 }
+
+func TestScheduler_RunHistory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	script := "echo \"disk usage: $1%\"\n[ \"$1\" -lt 90 ] || exit 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Cron: []config.CronJob{
+		{Name: "disk_ok", Schedule: "@hourly", Type: "script", Script: "check.sh 40",
+			Targets: []config.CronTarget{{Provider: "local", ID: "general"}, {Provider: "missing", ID: "1"}}},
+		{Name: "disk_full", Schedule: "0 9 * * *", Type: "script", Script: "check.sh 95"},
+	}}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{})
+
+	ok := s.RunJob(cfg.Cron[0])
+	if ok.Status != "partial" || ok.ExitCode == nil || *ok.ExitCode != 0 || len(ok.Deliveries) != 2 || ok.Deliveries[1].Error == "" {
+		t.Errorf("expected a partial run with one failed delivery, got %+v", ok)
+	}
+
+	s.RunJob(cfg.Cron[1])
+	full := s.RunJob(cfg.Cron[1])
+	if full.Status != "failed" || full.ExitCode == nil || *full.ExitCode != 3 || full.Output != "disk usage: 95%\n" {
+		t.Errorf("expected a failed run with exit code 3, got %+v", full)
+	}
+
+	runs, err := s.History.Runs(cfg.Cron[1].JobID(), 0)
+	if err != nil {
+		t.Fatalf("Runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].StartedAt.Before(runs[1].StartedAt) || runs[0].Trigger != "manual" {
+		t.Errorf("expected 2 manual runs, newest first, got %+v", runs)
+	}
+
+	s.Start()
+	defer s.Stop()
+	jobs := s.Jobs()
+	if len(jobs) != 2 || jobs[0].NextRun == nil || jobs[1].LastRun == nil || jobs[1].LastRun.Status != "failed" {
+		t.Errorf("expected next and last runs in job status, got %+v", jobs)
+	}
+}
+
+func TestUpdateCronJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	legacy := `{"cron": [
+		{"name": "backup", "schedule": "0 3 * * *", "type": "script", "script": "backup.sh"},
+		{"name": "Greeting", "schedule": "0 9 * * *", "type": "prompt", "prompt": "Say hi"}
+	]}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, _, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	shownID := cfg.Cron[1].JobID()
+	if config.FindCronJob(cfg.Cron, "greeting") != 1 || config.FindCronJob(cfg.Cron, shownID) != 1 {
+		t.Fatalf("expected lookups by name and derived ID to find the job")
+	}
+
+	// Disabling persists the ID shown before, and renaming keeps it
+	disabled := false
+	if _, err := config.UpdateCronJob(path, shownID, config.CronJobPatch{Enabled: &disabled}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	newName := "morning"
+	job, err := config.UpdateCronJob(path, "greeting", config.CronJobPatch{Name: &newName})
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if job.ID != shownID || job.Name != "morning" || job.IsEnabled() {
+		t.Errorf("unexpected job after edits: %+v", job)
+	}
+
+	taken := "BACKUP"
+	if _, err := config.UpdateCronJob(path, shownID, config.CronJobPatch{Name: &taken}); err == nil {
+		t.Errorf("expected an error when renaming to an existing name")
+	}
+	script := ""
+	if _, err := config.UpdateCronJob(path, "backup", config.CronJobPatch{Script: &script}); err == nil {
+		t.Errorf("expected an error when a job would have neither prompt nor script")
+	}
+
+	cfg, _, _, _ = config.LoadConfig(path)
+	if cfg.Cron[0].ID == "" || cfg.Cron[1].ID != shownID {
+		t.Errorf("expected IDs to be persisted, got %+v", cfg.Cron)
+	}
+
+	s := cron.NewScheduler(cfg, t.TempDir(), &agent.Agent{}, map[string]messaging.Provider{})
+	s.Start()
+	defer s.Stop()
+	st, ok := s.Status("morning")
+	if !ok || st.NextRun != nil {
+		t.Errorf("expected the disabled job to have no next run, got %+v", st)
+	}
+}
+
+func TestNextFire(t *testing.T) {
+	after := time.Date(2026, 1, 5, 10, 30, 0, 0, time.UTC)
+	next, err := cron.NextFire("0 9 * * *", "UTC", after)
+	if err != nil {
+		t.Fatalf("NextFire: %v", err)
+	}
+	if want := time.Date(2026, 1, 6, 9, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextFire = %v, want %v", next, want)
+	}
+	if _, err := cron.NextFire("not a schedule", "", after); err == nil {
+		t.Errorf("expected an error for an invalid schedule")
+	}
+}
+
+// flakyProvider fails the first failures sends.
+type flakyProvider struct {
+	mu       sync.Mutex
+	failures int
+	sent     []string
+}
+
+func (p *flakyProvider) Name() string                      { return "flaky" }
+func (p *flakyProvider) Start()                            {}
+func (p *flakyProvider) SystemPromptInstruction() string   { return "" }
+func (p *flakyProvider) SendImage(_, _, _ string) error    { return nil }
+func (p *flakyProvider) SendAudio(_, _, _ string) error    { return nil }
+func (p *flakyProvider) SendVideo(_, _, _ string) error    { return nil }
+func (p *flakyProvider) SendDocument(_, _, _ string) error { return nil }
+func (p *flakyProvider) SendMessage(targetID, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("connection reset")
+	}
+	p.sent = append(p.sent, message)
+	return nil
+}
+
+func TestScheduler_Policies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "slow.sh"), []byte("echo started\nsleep $1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	flaky := &flakyProvider{failures: 2}
+	cfg := &config.Config{Cron: []config.CronJob{
+		{Name: "hung", Schedule: "@hourly", Script: "slow.sh 10", TimeoutMs: 200},
+		{Name: "overlap", Schedule: "@every 1m", Script: "slow.sh 1", Concurrency: "skip"},
+		{Name: "flaky", Schedule: "@hourly", Script: "slow.sh 0", Retries: 2, BackoffMs: 1,
+			Targets: []config.CronTarget{{Provider: "flaky", ID: "chat"}}},
+	}}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": flaky})
+
+	start := time.Now()
+	hung := s.RunJob(cfg.Cron[0])
+	if hung.Status != "failed" || !strings.Contains(hung.Error, "timed out") || time.Since(start) > 5*time.Second {
+		t.Errorf("expected the script to be killed after the timeout, got %+v after %s", hung, time.Since(start))
+	}
+
+	done := make(chan cron.Run)
+	go func() { done <- s.RunJob(cfg.Cron[1]) }()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		if st, _ := s.Status("overlap"); st.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first run never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if second := s.RunJob(cfg.Cron[1]); second.Status != "skipped" {
+		t.Errorf("expected the overlapping run to be skipped, got %s", second.Status)
+	}
+	if first := <-done; first.Status != "success" {
+		t.Errorf("expected the first run to succeed, got %+v", first)
+	}
+
+	retried := s.RunJob(cfg.Cron[2])
+	if retried.Status != "success" || retried.Retries != 2 || len(flaky.sent) != 1 {
+		t.Errorf("expected delivery to succeed on the third attempt, got %+v", retried)
+	}
+}
+
+func TestScheduler_Notify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	status := filepath.Join(dir, "status.txt")
+	script := "cat status.txt\ngrep -q ERROR status.txt && exit 2\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setStatus := func(s string) {
+		if err := os.WriteFile(status, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := &flakyProvider{}
+	targets := []config.CronTarget{{Provider: "flaky", ID: "chat"}}
+	cfg := &config.Config{Cron: []config.CronJob{
+		{Name: "change", Schedule: "@hourly", Script: "check.sh", Targets: targets,
+			Notify: &config.CronNotifyConfig{On: "change"}},
+		{Name: "failure", Schedule: "@hourly", Script: "check.sh", Targets: targets,
+			Notify: &config.CronNotifyConfig{On: "failure", Resolved: true}},
+		{Name: "match", Schedule: "@hourly", Script: "check.sh", Targets: targets,
+			Notify: &config.CronNotifyConfig{On: "match", Pattern: `price: \d+`}},
+	}}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": sink})
+	run := func(i int) (cron.Run, []string) {
+		sink.sent = nil
+		return s.RunJob(cfg.Cron[i]), sink.sent
+	}
+
+	setStatus("all good\n")
+	if r, sent := run(0); len(sent) != 0 || r.Notification != "suppressed: condition not met" {
+		t.Errorf("expected the first run to only record a baseline, got %+v", r)
+	}
+	if _, sent := run(0); len(sent) != 0 {
+		t.Errorf("expected unchanged output to be suppressed, got %q", sent)
+	}
+	setStatus("all good\nnew line\n")
+	if _, sent := run(0); len(sent) != 1 || !strings.Contains(sent[0], "+ new line") || strings.Contains(sent[0], "+ all good") {
+		t.Errorf("expected a diff of the changed output, got %q", sent)
+	}
+	// Output beyond what is stored, cut in the middle of a character
+	large := "x" + strings.Repeat("é", 40*1024) + "\n"
+	setStatus(large)
+	run(0)
+	if _, sent := run(0); len(sent) != 0 {
+		t.Errorf("expected unchanged large output to be suppressed, got %d messages", len(sent))
+	}
+	setStatus(large + "tail\n")
+	if _, sent := run(0); len(sent) != 1 {
+		t.Errorf("expected a change after the stored output to be sent, got %d messages", len(sent))
+	}
+
+	if _, sent := run(1); len(sent) != 0 {
+		t.Errorf("expected a successful run to be suppressed, got %q", sent)
+	}
+	setStatus("ERROR: disk full\n")
+	if r, sent := run(1); len(sent) != 1 || r.Notification != "sent: exit code 2" {
+		t.Errorf("expected the failure to be sent, got %+v", r)
+	}
+	setStatus("all good\n")
+	if r, sent := run(1); len(sent) != 1 || !strings.HasPrefix(sent[0], "RESOLVED [failure]") || r.Notification != "resolved" {
+		t.Errorf("expected a resolved message, got %q", sent)
+	}
+	if _, sent := run(1); len(sent) != 0 {
+		t.Errorf("expected the resolved message to be sent once, got %q", sent)
+	}
+
+	if _, sent := run(2); len(sent) != 0 {
+		t.Errorf("expected output without a match to be suppressed, got %q", sent)
+	}
+	setStatus("price: 42\n")
+	if _, sent := run(2); len(sent) != 1 || !strings.Contains(sent[0], "price: 42") {
+		t.Errorf("expected matching output to be sent, got %q", sent)
+	}
+}
+
+func TestParseWhen(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, loc) // A Wednesday
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		in       string
+		schedule string
+		at       time.Time
+	}{
+		{"every weekday at 8:30", "30 8 * * 1-5", time.Time{}},
+		{"Every Monday and Thursday at 6pm", "0 18 * * 1,4", time.Time{}},
+		{"every 15 minutes", "*/15 * * * *", time.Time{}},
+		{"every hour", "0 * * * *", time.Time{}},
+		{"every 90 minutes", "@every 1h30m", time.Time{}},
+		{"daily at 7", "0 7 * * *", time.Time{}},
+		{"every weekend morning", "0 9 * * 6,0", time.Time{}},
+		{"every month on the 1st at 9", "0 9 1 * *", time.Time{}},
+		{"in 2 hours", "", at(10, 14, 12, 0)},
+		{"in 1 hour and 30 minutes", "", at(10, 14, 11, 30)},
+		{"tomorrow at 9", "", at(10, 15, 9, 0)},
+		{"at 9am tomorrow", "", at(10, 15, 9, 0)},
+		{"tomorrow evening", "", at(10, 15, 18, 0)},
+		{"tonight at 8", "", at(10, 14, 20, 0)},
+		{"at 9", "", at(10, 15, 9, 0)}, // Already past today
+		{"friday 6pm", "", at(10, 16, 18, 0)},
+		{"next wednesday at 10:15", "", at(10, 21, 10, 15)},
+		{"oct 20 at 15:00", "", at(10, 20, 15, 0)},
+		{"2026-11-02 08:00", "", at(11, 2, 8, 0)},
+	}
+	for _, tt := range tests {
+		w, err := cron.ParseWhen(tt.in, now, loc)
+		if err != nil {
+			t.Errorf("ParseWhen(%q): %v", tt.in, err)
+			continue
+		}
+		if w.Schedule != tt.schedule || !w.At.Equal(tt.at) {
+			t.Errorf("ParseWhen(%q) = %q / %s, want %q / %s", tt.in, w.Schedule, w.At, tt.schedule, tt.at)
+		}
+		if w.Schedule != "" {
+			if err := cron.ValidateSchedule(w.Schedule); err != nil {
+				t.Errorf("ParseWhen(%q) produced an invalid spec: %v", tt.in, err)
+			}
+		}
+	}
+
+	for _, in := range []string{"yesterday at 9", "every 30 seconds", "sometime soon", "today at 8", "2026-01-01T00:00:00Z"} {
+		if _, err := cron.ParseWhen(in, now, loc); err == nil {
+			t.Errorf("ParseWhen(%q): expected an error", in)
+		}
+	}
+
+	if got, err := cron.ParseAt("+45m", now, loc); err != nil || !got.Equal(at(10, 14, 10, 45)) {
+		t.Errorf("ParseAt(+45m) = %s, %v", got, err)
+	}
+}
+
+func TestScheduler_OneShot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("echo hello\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []config.CronTarget{{Provider: "flaky", ID: "chat"}}
+	cfg := &config.Config{Cron: []config.CronJob{
+		{ID: "soon", Name: "soon", At: time.Now().Add(1500 * time.Millisecond).Format(time.RFC3339), Script: "hello.sh", Targets: targets},
+		{ID: "missed", Name: "missed", At: time.Now().Add(-time.Hour).Format(time.RFC3339), Script: "hello.sh", Targets: targets},
+		{ID: "daily", Name: "daily", Schedule: "@daily", Script: "hello.sh", Targets: targets},
+	}}
+	configPath := filepath.Join(dir, "config.json")
+	if err := config.SaveConfig(cfg, configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &flakyProvider{}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": sink})
+	s.ConfigPath = configPath
+	s.Start()
+	defer s.Stop()
+
+	var remaining []config.CronJob
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		saved, _, _, err := config.LoadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remaining = saved.Cron; len(remaining) == 1 {
+			break
+		}
+	}
+	if len(remaining) != 1 || remaining[0].Name != "daily" {
+		t.Fatalf("expected only the recurring job to remain, got %+v", remaining)
+	}
+
+	for _, id := range []string{"soon", "missed"} {
+		if last, _ := s.History.Last(id); last == nil || last.Status != "success" {
+			t.Errorf("expected one-shot job %s to have run, got %+v", id, last)
+		}
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("echo hello\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []config.CronTarget{{Provider: "flaky", ID: "chat"}}
+	job := func(id, catchUp string, windowMs int) config.CronJob {
+		return config.CronJob{ID: id, Name: id, Schedule: "@every 1h", Script: "hello.sh", Targets: targets, CatchUp: catchUp, CatchUpWindowMs: windowMs}
+	}
+	cfg := &config.Config{Cron: []config.CronJob{
+		job("all", "all", 0),
+		job("once", "once", 0),
+		job("none", "", 0),
+		job("windowed", "all", int((90 * time.Minute).Milliseconds())),
+		job("rescheduled", "all", 0),
+	}}
+
+	// The server went down 3 hours ago, missing three hourly runs of each job
+	history := cron.NewHistory(dir)
+	down := time.Now().Add(-3*time.Hour - time.Minute)
+	for _, j := range cfg.Cron {
+		schedule := j.Schedule
+		if j.ID == "rescheduled" {
+			schedule = "@every 2h"
+		}
+		if err := history.RecordFire(j.ID, cron.Fire{At: down, Schedule: schedule}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": &flakyProvider{}})
+	s.Start()
+	defer s.Stop()
+
+	want := map[string]int{"all": 3, "once": 1, "none": 0, "windowed": 1, "rescheduled": 0}
+	counts := func() map[string]int {
+		got := make(map[string]int)
+		for id := range want {
+			runs, _ := s.History.Runs(id, 0)
+			for _, run := range runs {
+				if run.Trigger != "catch-up" || run.ScheduledFor == nil || run.ScheduledFor.After(time.Now()) {
+					t.Errorf("unexpected run of %s: %+v", id, run)
+				}
+			}
+			got[id] = len(runs)
+		}
+		return got
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if got := counts(); got["all"] == want["all"] && got["once"] == want["once"] && got["windowed"] == want["windowed"] {
+			break
+		}
+	}
+	time.Sleep(100 * time.Millisecond) // Let any extra runs show up
+	for id, n := range counts() {
+		if n != want[id] {
+			t.Errorf("expected %d caught-up runs of %s, got %d", want[id], id, n)
+		}
+	}
+
+	if fire, ok := s.History.LastFire("all"); !ok || time.Since(fire.At) > time.Minute {
+		t.Errorf("expected the fire time to be reset at startup, got %+v", fire)
+	}
+}
+
+func TestScheduler_EphemeralTools(t *testing.T) {
+	// The LLM asks for a tool first, then answers with what it learned
+	var mu sync.Mutex
+	var requests []llm.ChatRequest
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		turn := len(requests)
+		mu.Unlock()
+
+		msg := llm.Message{Role: "assistant", Content: "Sunny, 21°C"}
+		if turn == 1 {
+			msg = llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function",
+				Function: llm.FunctionCall{Name: "yaocc_weather_usage", Arguments: "{}"}}}}
+		}
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: msg}}})
+	}))
+	defer llmServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{UseNativeToolCalling: true, Cron: []config.CronJob{
+		{Name: "weather", Schedule: "@daily", Prompt: "Check the weather and tell me", UseTools: true, LogSession: "cron-weather",
+			Targets: []config.CronTarget{{Provider: "flaky", ID: "chat"}}},
+	}}
+	a := &agent.Agent{
+		Config:   cfg,
+		LLM:      &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()},
+		Sessions: agent.NewSessionManager(filepath.Join(dir, "sessions")),
+	}
+	sink := &flakyProvider{}
+	s := cron.NewScheduler(cfg, dir, a, map[string]messaging.Provider{"flaky": sink})
+
+	run := s.RunJob(cfg.Cron[0])
+	if run.Status != "success" || len(sink.sent) != 1 || sink.sent[0] != "Sunny, 21°C" {
+		t.Fatalf("expected the final answer to be delivered, got %+v (sent %q)", run, sink.sent)
+	}
+	if len(requests) != 2 || requests[1].Messages[len(requests[1].Messages)-1].Role != "tool" {
+		t.Errorf("expected a tool round trip, got %d requests", len(requests))
+	}
+
+	if history, _ := a.Sessions.LoadHistory("chat"); len(history) != 0 {
+		t.Errorf("expected the target's chat history to stay empty, got %+v", history)
+	}
+	logged, _ := a.Sessions.LoadHistory("cron-weather")
+	if len(logged) != 2 || !strings.Contains(logged[0].Content, "Check the weather") || logged[1].Content != "Sunny, 21°C" {
+		t.Errorf("expected the run in the log session, got %+v", logged)
+	}
+}
+
+func TestScheduler_FileTrigger(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "changed.sh"), []byte("echo \"$YAOCC_TRIGGER\"\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	inbox := filepath.Join(dir, "inbox")
+	if err := os.Mkdir(inbox, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &flakyProvider{}
+	cfg := &config.Config{Cron: []config.CronJob{
+		{ID: "inbox", Name: "inbox", Script: "changed.sh", Targets: []config.CronTarget{{Provider: "flaky", ID: "chat"}},
+			Trigger: &config.CronTrigger{Type: "file", Path: "inbox", Pattern: "*.txt", DebounceMs: 100}},
+	}}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": sink})
+	s.Start()
+	defer s.Stop()
+
+	time.Sleep(1200 * time.Millisecond) // Let the watcher take its first snapshot
+	for _, name := range []string{"a.txt", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inbox, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sent []string
+	for deadline := time.Now().Add(6 * time.Second); time.Now().Before(deadline) && len(sent) == 0; time.Sleep(50 * time.Millisecond) {
+		sink.mu.Lock()
+		sent = append([]string(nil), sink.sent...)
+		sink.mu.Unlock()
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "file\ncreated: a.txt") || strings.Contains(sent[0], "b.md") {
+		t.Fatalf("expected one report of the new .txt file, got %q", sent)
+	}
+	if last, _ := s.History.Last("inbox"); last == nil || last.Trigger != "file" || last.Event != "created: a.txt" {
+		t.Errorf("expected the file event in the history, got %+v", last)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":42}`)
+	trigger := config.CronTrigger{Type: "webhook", Secret: "s3cret"}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	cases := []struct {
+		name   string
+		header http.Header
+		query  url.Values
+		want   bool
+	}{
+		{"header", http.Header{"X-Hook-Secret": {"s3cret"}}, nil, true},
+		{"query", nil, url.Values{"secret": {"s3cret"}}, true},
+		{"signature", http.Header{"X-Hub-Signature-256": {signature}}, nil, true},
+		{"wrong secret", http.Header{"X-Hook-Secret": {"guess"}}, nil, false},
+		{"wrong signature", http.Header{"X-Hub-Signature-256": {"sha256=00"}}, url.Values{"secret": {"s3cret"}}, false},
+		{"missing", nil, nil, false},
+	}
+	for _, tc := range cases {
+		header := tc.header
+		if header == nil {
+			header = http.Header{}
+		}
+		if got := cron.VerifyWebhook(trigger, body, header, tc.query); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+	if cron.VerifyWebhook(config.CronTrigger{Type: "webhook", RequireToken: true}, body, http.Header{}, nil) {
+		t.Error("expected a hook without a secret to be left to token authentication")
+	}
+}
+
+func TestScheduler_WebhookPrompt(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "Order received"}}}})
+	}))
+	defer llmServer.Close()
+
+	cfg := &config.Config{Cron: []config.CronJob{
+		{Name: "templated", Prompt: "Tell me about order {{.payload.id}} from {{.query.shop}}.", Targets: []config.CronTarget{{Provider: "flaky", ID: "chat"}},
+			Trigger: &config.CronTrigger{Type: "webhook"}},
+		{Name: "plain", Prompt: "Summarize this event.", Targets: []config.CronTarget{{Provider: "flaky", ID: "chat"}},
+			Trigger: &config.CronTrigger{Type: "webhook"}},
+	}}
+	a := &agent.Agent{Config: cfg, LLM: &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()}}
+	sink := &flakyProvider{}
+	s := cron.NewScheduler(cfg, t.TempDir(), a, map[string]messaging.Provider{"flaky": sink})
+
+	ev := cron.WebhookEvent([]byte(`{"id":42}`), url.Values{"shop": {"berlin"}, "secret": {"s3cret"}})
+	for _, job := range cfg.Cron {
+		if run := s.RunEvent(job, ev); run.Status != "success" || run.Trigger != "webhook" {
+			t.Fatalf("expected job %s to succeed, got %+v", job.Name, run)
+		}
+	}
+	if len(prompts) != 2 || prompts[0] != "Tell me about order 42 from berlin." {
+		t.Fatalf("expected the payload in the templated prompt, got %q", prompts)
+	}
+	if !strings.Contains(prompts[1], "Event (webhook):\n{\"id\":42}") || !strings.HasSuffix(prompts[1], "Summarize this event.") {
+		t.Errorf("expected the payload as context of the plain prompt, got %q", prompts[1])
+	}
+	if len(sink.sent) != 2 {
+		t.Errorf("expected both answers to be delivered, got %q", sink.sent)
+	}
+}
+
+func TestScheduler_MessageTrigger(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "echo.sh"), []byte("cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := false
+	cfg := &config.Config{Cron: []config.CronJob{
+		{Name: "expense", Script: "echo.sh", Trigger: &config.CronTrigger{Type: "message", Pattern: `(?i)^expense: (\d+)`}},
+		{Name: "off", Script: "echo.sh", Enabled: &disabled, Trigger: &config.CronTrigger{Type: "message", Pattern: `.`}},
+	}}
+	sink := &flakyProvider{}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{"flaky": sink})
+
+	if n := s.MatchMessage("flaky", "chat", "hello"); n != 0 {
+		t.Errorf("expected no job for a message without a match, got %d", n)
+	}
+	if n := s.MatchMessage("flaky", "chat", "Expense: 12 lunch"); n != 1 {
+		t.Fatalf("expected one matching job, got %d", n)
+	}
+
+	var sent []string
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline) && len(sent) == 0; time.Sleep(20 * time.Millisecond) {
+		sink.mu.Lock()
+		sent = append([]string(nil), sink.sent...)
+		sink.mu.Unlock()
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "Expense: 12 lunch") {
+		t.Fatalf("expected the reply in the originating chat, got %q", sent)
+	}
+}
+
+func TestCronTrigger_Validate(t *testing.T) {
+	cases := []struct {
+		job   config.CronJob
+		valid bool
+	}{
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "webhook"}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "webhook", Secret: "s3cret"}}, true},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "webhook", RequireToken: true}}, true},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file", Path: "inbox"}}, true},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file"}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file", Path: "/etc"}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file", Path: "../.."}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file", Path: "~/notes"}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "file", Path: "inbox/../../x"}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "message", Pattern: "("}}, false},
+		{config.CronJob{Trigger: &config.CronTrigger{Type: "sms"}}, false},
+		{config.CronJob{Schedule: "@daily", Trigger: &config.CronTrigger{Type: "webhook"}}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.job.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tc.job.Trigger, tc.valid, err)
+		}
+	}
+
+	job := config.CronJob{Name: "x", Schedule: "@daily", Prompt: "hi"}
+	if err := (config.CronJobPatch{Trigger: &config.CronTrigger{Type: "webhook", Secret: "s3cret"}}).Apply(&job); err != nil || job.Schedule != "" || !job.IsTriggered() {
+		t.Errorf("expected the trigger to replace the schedule, got %+v (%v)", job, err)
+	}
+	schedule := "@hourly"
+	if err := (config.CronJobPatch{Schedule: &schedule}).Apply(&job); err != nil || job.IsTriggered() {
+		t.Errorf("expected the schedule to replace the trigger, got %+v (%v)", job, err)
+	}
+}
+
+func TestScheduler_Digest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"weather.sh": "sleep 0.3\necho sunny\n",
+		"status.sh":  "sleep 0.3\necho all servers up\n",
+		"broken.sh":  "echo oops >&2\nexit 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var prompts []string
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "Good morning!"}}}})
+	}))
+	defer llmServer.Close()
+
+	disabled := false
+	targets := []config.CronTarget{{Provider: "flaky", ID: "chat"}}
+	cfg := &config.Config{Cron: []config.CronJob{
+		{ID: "w", Name: "weather", Schedule: "@daily", Script: "weather.sh", Enabled: &disabled, Targets: targets},
+		{Name: "briefing", Schedule: "0 7 * * *", Targets: targets, Digest: []config.CronDigestSource{
+			{Job: "w"},
+			{Label: "Servers", Script: "status.sh"},
+			{Script: "broken.sh"},
+		}},
+		{Name: "hopeless", Schedule: "0 7 * * *", Targets: targets, Digest: []config.CronDigestSource{
+			{Script: "broken.sh"}, {Job: "missing"},
+		}},
+	}}
+	a := &agent.Agent{Config: cfg, LLM: &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()}}
+	sink := &flakyProvider{}
+	s := cron.NewScheduler(cfg, dir, a, map[string]messaging.Provider{"flaky": sink})
+
+	start := time.Now()
+	run := s.RunJob(cfg.Cron[1])
+	if run.Status != "success" || len(sink.sent) != 1 || sink.sent[0] != "Good morning!" {
+		t.Fatalf("expected one combined message, got %+v (sent %q)", run, sink.sent)
+	}
+	if elapsed := time.Since(start); elapsed > 550*time.Millisecond {
+		t.Errorf("expected the sources to run concurrently, took %s", elapsed)
+	}
+	if len(prompts) != 1 {
+		t.Fatalf("expected a single LLM call, got %d", len(prompts))
+	}
+	for _, want := range []string{"## weather\nsunny", "## Servers\nall servers up", "## broken.sh (failed: exit status 1: oops)"} {
+		if !strings.Contains(prompts[0], want) {
+			t.Errorf("expected %q in the digest prompt, got %q", want, prompts[0])
+		}
+	}
+	if strings.Index(prompts[0], "## weather") > strings.Index(prompts[0], "## Servers") {
+		t.Error("expected the sources in their configured order")
+	}
+	if len(run.Sources) != 3 || run.Sources[2].Error == "" || run.Sources[0].Error != "" {
+		t.Errorf("expected the source outcomes in the run, got %+v", run.Sources)
+	}
+
+	sink.sent = nil
+	if run := s.RunJob(cfg.Cron[2]); run.Status != "failed" || len(sink.sent) != 0 {
+		t.Errorf("expected a digest without any working source to fail quietly, got %+v (sent %q)", run, sink.sent)
+	}
+}
+
+func TestScheduler_Targets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.sh"), []byte("echo \"all good\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var hook cron.WebhookMessage
+	var signature string
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &hook)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if r.Header.Get("X-Hub-Signature-256") == "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			signature = "valid"
+		}
+	}))
+	defer hookServer.Close()
+
+	mail := make(chan string, 1)
+	smtpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtpListener.Close()
+	go fakeSMTP(smtpListener, mail)
+	smtpHost, smtpPort, _ := net.SplitHostPort(smtpListener.Addr().String())
+	port, _ := strconv.Atoi(smtpPort)
+
+	cfg := &config.Config{
+		Egress: config.EgressConfig{AllowPrivate: true},
+		SMTP:   config.SMTPConfig{Host: smtpHost, Port: port, From: "bot@example.com"},
+		Cron: []config.CronJob{
+			{Name: "report", Schedule: "@daily", Type: "script", Script: "report.sh", Targets: []config.CronTarget{
+				{Provider: "local", ID: "general"},
+				{Provider: "file", ID: "memory/{date}.md"},
+				{Provider: "webhook", ID: hookServer.URL, Secret: "s3cret"},
+				{Provider: "email", ID: "me@example.com"},
+			}},
+		},
+	}
+	s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{})
+
+	run := s.RunJob(cfg.Cron[0])
+	if run.Status != "success" || len(run.Deliveries) != 4 {
+		t.Fatalf("expected all four deliveries to succeed, got %+v", run)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "memory", time.Now().Format("2006-01-02")+".md"))
+	if err != nil || !strings.Contains(string(data), " report\n\nSYSTEM REPORT [report]:\nall good\n") {
+		t.Errorf("expected the output in the daily file, got %q (%v)", data, err)
+	}
+	if hook.Job != "report" || !strings.Contains(hook.Message, "all good") || signature != "valid" {
+		t.Errorf("expected a signed webhook with the output, got %+v (signature %q)", hook, signature)
+	}
+	select {
+	case msg := <-mail:
+		if !strings.Contains(msg, "Subject: [yaocc] report\r\n") || !strings.Contains(msg, "all good") {
+			t.Errorf("unexpected email: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected an email")
+	}
+
+	unread, err := s.Inbox.Messages(true, 0)
+	if err != nil || len(unread) != 1 || unread[0].Session != "general" || !strings.Contains(unread[0].Message, "all good") {
+		t.Fatalf("expected one unread inbox message, got %+v (%v)", unread, err)
+	}
+	other := unread[0]
+	other.Session = "other"
+	if err := s.Inbox.MarkRead([]cron.InboxMessage{other}); err != nil {
+		t.Fatal(err)
+	}
+	if unread, _ = s.Inbox.Messages(true, 0); len(unread) != 1 {
+		t.Errorf("expected marking another session to leave the message unread, got %+v", unread)
+	}
+
+	// Only the messages shown are marked, not older ones left out by a limit
+	s.Inbox.Add(cron.InboxMessage{Time: time.Now(), Session: "general", Message: "newer"})
+	shown, _ := s.Inbox.Messages(true, 1)
+	if err := s.Inbox.MarkRead(shown); err != nil {
+		t.Fatal(err)
+	}
+	if unread, _ = s.Inbox.Messages(true, 0); len(unread) != 1 || !strings.Contains(unread[0].Message, "all good") {
+		t.Errorf("expected the older message to stay unread, got %+v", unread)
+	}
+	if err := s.Inbox.MarkRead(unread); err != nil {
+		t.Fatal(err)
+	}
+	if unread, _ = s.Inbox.Messages(true, 0); len(unread) != 0 {
+		t.Errorf("expected no unread messages, got %+v", unread)
+	}
+}
+
+func TestScheduler_FileTargetEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, "config")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.sh"), []byte("echo injected\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(root, "outside.md")
+
+	for _, target := range []string{"../outside.md", outside, "memory/../../outside.md", "../config2/x.md", "config.json"} {
+		job := config.CronJob{Name: "escape", Schedule: "@daily", Type: "script", Script: "report.sh",
+			Targets: []config.CronTarget{{Provider: "file", ID: target}}}
+		// Sensitive files inside the config dir are only refused on delivery
+		if err := job.Validate(); err == nil && target != "config.json" {
+			t.Errorf("expected file target %q to be rejected", target)
+		}
+
+		cfg := &config.Config{Cron: []config.CronJob{job}}
+		s := cron.NewScheduler(cfg, dir, &agent.Agent{}, map[string]messaging.Provider{})
+		if run := s.RunJob(job); run.Status == "success" {
+			t.Errorf("expected the delivery to %q to fail, got %+v", target, run)
+		}
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Error("a file target wrote outside the config dir")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); err == nil {
+		t.Error("a file target wrote to config.json")
+	}
+}
+
+// fakeSMTP accepts one message without authentication and sends its data to mail.
+func fakeSMTP(l net.Listener, mail chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case cmd == "DATA":
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			mail <- data.String()
+			fmt.Fprint(conn, "250 ok\r\n")
+		case cmd == "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/fetch"
+)
+
+const articleHTML = `<!DOCTYPE html>
+<html><head><title>Go 1.24 Released &amp; More</title>
+<script>var tracking = "do not include me";</script>
+<style>.x{color:red}</style></head>
+<body>
+<nav class="menu"><a href="/">Home</a> <a href="/news">News</a></nav>
+<div id="sidebar"><p>Subscribe to our newsletter for weekly updates, deals, and more offers.</p></div>
+<div class="post-content">
+  <h1>Go 1.24 is out</h1>
+  <p>The Go team is happy to announce the release of Go 1.24, which brings generic type aliases, faster maps, and a new <a href="/doc/weak">weak package</a>.
+  <p>Read the <a href="https://go.dev/doc/go1.24">release notes</a> for the full list of changes, improvements, and fixes.</p>
+  <ul><li>Swiss tables<li>Weak pointers</ul>
+  <pre><code>go install golang.org/dl/go1.24@latest</code></pre>
+</div>
+<footer><p>Copyright 2025, all rights reserved, do not copy this text please.</p></footer>
+</body></html>`
+
+func TestExtractReadable(t *testing.T) {
+	article, err := fetch.ExtractReadable(articleHTML, "https://go.dev/blog/go1.24", "")
+	if err != nil {
+		t.Fatalf("ExtractReadable() error = %v", err)
+	}
+
+	if article.Title != "Go 1.24 Released & More" {
+		t.Errorf("unexpected title %q", article.Title)
+	}
+
+	md := article.Markdown
+	for _, want := range []string{
+		"# Go 1.24 is out",
+		"[weak package](https://go.dev/doc/weak)",
+		"[release notes](https://go.dev/doc/go1.24)",
+		"- Swiss tables",
+		"- Weak pointers",
+		"```\ngo install golang.org/dl/go1.24@latest\n```",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+	for _, unwanted := range []string{"tracking", "newsletter", "Copyright", "Home"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("expected markdown not to contain %q, got:\n%s", unwanted, md)
+		}
+	}
+}
+
+func TestExtractReadable_Selector(t *testing.T) {
+	article, err := fetch.ExtractReadable(articleHTML, "https://go.dev/", "div.post-content ul")
+	if err != nil {
+		t.Fatalf("ExtractReadable() error = %v", err)
+	}
+	if article.Markdown != "- Swiss tables\n- Weak pointers" {
+		t.Errorf("unexpected selector output %q", article.Markdown)
+	}
+
+	if _, err := fetch.ExtractReadable(articleHTML, "", "#missing"); err == nil {
+		t.Error("expected error for selector without matches")
+	}
+}
+
+func TestParseHTML_NonUTF8(t *testing.T) {
+	// Latin-1 bytes in raw text elements must not shift the search for the closing tag
+	latin1 := strings.Repeat("caf\xe9 cr\xe8me ", 4)
+	doc := fetch.ParseHTML("<html><script>var s = '" + latin1 + "';</SCRIPT><title>" + latin1 + "</TITLE><p>ok</p></html>")
+
+	title := doc.Find("title")
+	if title == nil || title.TextContent() != latin1 {
+		t.Fatalf("unexpected title node %+v", title)
+	}
+	if p := doc.Find("p"); p == nil || p.TextContent() != "ok" {
+		t.Errorf("expected the paragraph after the raw text elements, got %+v", p)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	text := strings.Repeat("äbc ", 50) + "\n\n" + strings.Repeat("xyz ", 50)
+
+	first := fetch.Paginate(text, 0, 250)
+	if first.NextOffset == 0 {
+		t.Fatal("expected first page to be truncated")
+	}
+	if !strings.HasSuffix(first.Content, "äbc ") {
+		t.Errorf("expected cut at paragraph boundary, got %q", first.Content[len(first.Content)-10:])
+	}
+	if !strings.Contains(first.Footer(), "--offset 200") {
+		t.Errorf("unexpected footer %q", first.Footer())
+	}
+
+	second := fetch.Paginate(text, first.NextOffset, 250)
+	if second.NextOffset != 0 || second.Footer() != "" {
+		t.Errorf("expected last page, got next offset %d", second.NextOffset)
+	}
+	if got := utf8.RuneCountInString(first.Content) + utf8.RuneCountInString(second.Content); got != first.Total {
+		t.Errorf("pages cover %d characters, expected %d", got, first.Total)
+	}
+}
+
+func TestRequestBuild_AuthProfiles(t *testing.T) {
+	profiles := map[string]config.AuthProfile{
+		"ha":     {Type: "bearer", Token: "secret", Hosts: []string{"ha.example.com"}},
+		"api":    {Type: "header", Header: "X-API-Key", Value: "k", Hosts: []string{"*.example.org"}},
+		"nohost": {Type: "bearer", Token: "secret"},
+	}
+
+	req, secrets, err := (&fetch.Request{
+		Method: "post",
+		URL:    "https://ha.example.com/api/services?x=1",
+		Query:  map[string]string{"y": "2"},
+		Body:   `{"entity_id":"light.kitchen"}`,
+		Auth:   "ha",
+	}).Build(profiles)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if req.Method != "POST" || req.URL.RawQuery != "x=1&y=2" {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" || len(secrets) != 1 {
+		t.Errorf("expected bearer auth, got %q (%v)", req.Header.Get("Authorization"), secrets)
+	}
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON content type, got %q", req.Header.Get("Content-Type"))
+	}
+
+	req, _, err = (&fetch.Request{URL: "https://api.example.org/v1", Auth: "api"}).Build(profiles)
+	if err != nil || req.Header.Get("X-API-Key") != "k" {
+		t.Errorf("expected header auth for wildcard host, got %v", err)
+	}
+
+	for name, target := range map[string]string{
+		"ha":      "https://evil.example.net/",
+		"nohost":  "https://ha.example.com/",
+		"missing": "https://ha.example.com/",
+	} {
+		if _, _, err := (&fetch.Request{URL: target, Auth: name}).Build(profiles); err == nil {
+			t.Errorf("expected auth profile %q to be rejected for %s", name, target)
+		}
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	if got := fetch.PrettyJSON(`{"a":[1,2]}`); got != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Errorf("unexpected pretty JSON %q", got)
+	}
+	if got := fetch.PrettyJSON("plain text"); got != "plain text" {
+		t.Errorf("expected non-JSON body unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/httpcache"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+func TestHTTPCache_Transport(t *testing.T) {
+	var hits, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			fmt.Fprint(w, "fresh body")
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			fmt.Fprint(w, "etag body")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			fmt.Fprint(w, "secret")
+		}
+	}))
+	defer srv.Close()
+
+	cache := httpcache.New(t.TempDir(), 0)
+	client := &http.Client{Transport: httpcache.NewTransport(cache, nil)}
+
+	get := func(path string) (string, string) {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get(httpcache.StatusHeader)
+	}
+
+	tests := []struct {
+		path       string
+		wantBody   string
+		wantStatus []string
+		wantHits   int32
+	}{
+		{"/fresh", "fresh body", []string{"MISS", "HIT"}, 1},
+		{"/etag", "etag body", []string{"MISS", "REVALIDATED"}, 2},
+		{"/nostore", "secret", []string{"MISS", "MISS"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			for i, want := range tt.wantStatus {
+				body, status := get(tt.path)
+				if body != tt.wantBody {
+					t.Errorf("request %d: body = %q, want %q", i, body, tt.wantBody)
+				}
+				if status != want {
+					t.Errorf("request %d: cache status = %q, want %q", i, status, want)
+				}
+			}
+			if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+				t.Errorf("server hits = %d, want %d", got, tt.wantHits)
+			}
+		})
+	}
+	if notModified != 1 {
+		t.Errorf("expected one 304 revalidation, got %d", notModified)
+	}
+}
+
+func TestHTTPCache_CredentialsAndDenials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, "private body")
+	}))
+	dir := t.TempDir()
+	cache := httpcache.New(dir, 0)
+	allowLocal, _ := netguard.NewPolicy(config.EgressConfig{AllowHosts: []string{"127.0.0.1"}})
+	denyAll, _ := netguard.NewPolicy(config.EgressConfig{})
+
+	get := func(policy *netguard.Policy) (*http.Response, error) {
+		client := &http.Client{Transport: httpcache.NewTransport(cache, policy.Client(5*time.Second).Transport)}
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Authorization", "Bearer super-secret-token")
+		resp, err := client.Do(req)
+		if err == nil {
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	if _, err := get(allowLocal); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected one cache file, got %d", len(entries))
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if strings.Contains(string(data), "super-secret-token") {
+		t.Errorf("cache file contains the request's credentials: %s", data)
+	}
+
+	// A request the egress policy refuses fails even though a cached copy exists
+	if _, err := get(denyAll); !errors.Is(err, netguard.ErrDenied) {
+		t.Errorf("expected the egress denial, got %v", err)
+	}
+
+	// An unreachable origin still gets the stale copy
+	srv.Close()
+	resp, err := get(allowLocal)
+	if err != nil || resp.Header.Get(httpcache.StatusHeader) != "STALE" {
+		t.Errorf("expected a stale response for an unreachable origin, got %v", err)
+	}
+}
+
+func TestHTTPCache_Eviction(t *testing.T) {
+	dir := t.TempDir()
+	cache := httpcache.New(dir, 4096)
+
+	payload := strings.Repeat("x", 600)
+	for i := 0; i < 10; i++ {
+		if err := cache.PutJSON(fmt.Sprintf("key-%d", i), payload, time.Minute); err != nil {
+			t.Fatalf("PutJSON: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond) // Distinct mtimes keep the LRU order deterministic
+	}
+
+	var got string
+	if !cache.GetJSON("key-9", &got) || got != payload {
+		t.Errorf("most recent entry should survive eviction")
+	}
+	if cache.GetJSON("key-0", &got) {
+		t.Errorf("oldest entry should have been evicted")
+	}
+
+	var total int64
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		info, _ := e.Info()
+		total += info.Size()
+	}
+	if total > cache.MaxBytes {
+		t.Errorf("cache size %d exceeds bound %d", total, cache.MaxBytes)
+	}
+}
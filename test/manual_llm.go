@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -57,7 +58,7 @@ func testProvider(cfg *config.Config, providerName string) {
 		{Role: "user", Content: "Hello, are you functional? Reply with 'Yes, I am functioning'."},
 	}
 
-	response, _, err := client.Chat(messages, nil)
+	response, _, err := client.Chat(context.Background(), messages, nil)
 	if err != nil {
 		fmt.Printf("Error chatting with %s: %v\n", providerName, err)
 		return
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/mcp"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+func TestMCP_HTTPTransport(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/mcp", http.StatusTemporaryRedirect)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer mcp-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req mcp.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		methods = append(methods, req.Method)
+		mu.Unlock()
+		if req.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != "session-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+
+		switch req.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "session-1")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{"protocolVersion":"2024-11-05","serverInfo":{"name":"test","version":"1"}}}`, req.ID)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/list":
+			// Streamed answer with a notification first
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%d,\n", req.ID)
+			fmt.Fprint(w, "data: \"result\":{\"tools\":[{\"name\":\"echo\",\"inputSchema\":{}}]}}\n\n")
+		case "tools/call":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{"content":[{"type":"text","text":"hello"}]}}`, req.ID)
+		}
+	}))
+	defer srv.Close()
+
+	policy, _ := netguard.NewPolicy(config.EgressConfig{})
+	policy = policy.Trust("127.0.0.1")
+	client, err := mcp.NewHTTPClient("test", srv.URL+"/mcp", map[string]string{"Authorization": "Bearer mcp-token"}, policy.Client(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if res, err := client.Initialize(ctx); err != nil || res.ServerInfo.Name != "test" {
+		t.Fatalf("Initialize() = %+v, %v", res, err)
+	}
+	tools, err := client.GetTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatalf("GetTools() = %+v, %v", tools, err)
+	}
+	result, err := client.CallTool(ctx, "echo", map[string]string{})
+	if err != nil || len(result.Content) != 1 || result.Content[0].Text != "hello" {
+		t.Fatalf("CallTool() = %+v, %v", result, err)
+	}
+	mu.Lock()
+	if len(methods) != 4 || methods[1] != "notifications/initialized" {
+		t.Errorf("unexpected requests %q", methods)
+	}
+	mu.Unlock()
+
+	// The egress policy applies: a redirect to a private address is refused
+	redirected, _ := mcp.NewHTTPClient("redirect", srv.URL+"/redirect", nil, policy.Client(0))
+	if _, err := redirected.Initialize(ctx); !errors.Is(err, netguard.ErrDenied) {
+		t.Errorf("expected the redirect to be denied, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/netguard"
+)
+
+func TestPolicy_CheckURL(t *testing.T) {
+	policy, err := netguard.NewPolicy(config.EgressConfig{
+		DenyHosts: []string{"*.internal.example", "203.0.113.7"},
+		Ports:     []int{80, 443},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/page", true},
+		{"http://example.com:80/", true},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://127.0.0.1/exec", false},
+		{"http://[::1]/", false},
+		{"http://10.0.0.5/", false},
+		{"http://100.64.1.1/", false},
+		{"ftp://example.com/file", false},
+		{"file:///etc/passwd", false},
+		{"https://example.com:8443/", false},
+		{"https://db.internal.example/", false},
+		{"https://203.0.113.7/", false},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		err := policy.CheckURL(u)
+		if (err == nil) != tt.allowed {
+			t.Errorf("CheckURL(%s) allowed=%v, want %v (err: %v)", tt.url, err == nil, tt.allowed, err)
+		}
+	}
+}
+
+func TestPolicy_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	denyAll, _ := netguard.NewPolicy(config.EgressConfig{})
+	if _, err := denyAll.Client(5 * time.Second).Get(srv.URL); err == nil || !strings.Contains(err.Error(), "egress denied") {
+		t.Errorf("expected loopback request to be denied, got %v", err)
+	}
+
+	// "localhost" passes the URL check but must be caught when its address is dialed.
+	localURL := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	if _, err := denyAll.Client(5 * time.Second).Get(localURL); err == nil || !strings.Contains(err.Error(), "egress denied") {
+		t.Errorf("expected localhost request to be denied at dial time, got %v", err)
+	}
+
+	allowLocal, _ := netguard.NewPolicy(config.EgressConfig{AllowHosts: []string{"127.0.0.1"}})
+	client := allowLocal.Client(5 * time.Second)
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected allow-listed host to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(srv.URL + "/redirect"); err == nil || !strings.Contains(err.Error(), "egress denied") {
+		t.Errorf("expected redirect to metadata address to be denied, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dev-dhg/yaocc/pkg/llm"
+	"github.com/dev-dhg/yaocc/pkg/research"
+	"github.com/dev-dhg/yaocc/pkg/websearch"
+)
+
+type scriptedLLM struct {
+	mu       sync.Mutex
+	prompts  []string
+	replies  []string
+	failFrom int // Calls at or after this index fail (0 disables)
+}
+
+func (s *scriptedLLM) Chat(ctx context.Context, messages []llm.Message, tools []llm.Tool) (string, []llm.ToolCall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompts = append(s.prompts, messages[len(messages)-1].Content)
+	n := len(s.prompts)
+	if s.failFrom > 0 && n >= s.failFrom {
+		return "", nil, errors.New("model unavailable")
+	}
+	return s.replies[n-1], nil, nil
+}
+
+type querySearch struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (q *querySearch) Search(query string, opts websearch.SearchOptions) ([]websearch.SearchResult, error) {
+	q.mu.Lock()
+	q.queries = append(q.queries, query)
+	q.mu.Unlock()
+	return []websearch.SearchResult{
+		{Title: "Go 1.22 release notes", Link: "https://go.dev/doc/go1.22", Snippet: "Go 1.22 release notes snippet."},
+		{Title: "Loopvar blog", Link: "https://go.dev/blog/loopvar-preview", Snippet: "The loop variable change, as a snippet."},
+	}, nil
+}
+
+func TestResearcher_Run(t *testing.T) {
+	model := &scriptedLLM{replies: []string{
+		"```json\n[\"go 1.22 loop variable semantics\", \"go 1.22 range over int\"]\n```",
+		"Go 1.22 changed loop variables to be per-iteration [1].",
+	}}
+	search := &querySearch{}
+	fetcher := func(ctx context.Context, url string) (string, string, error) {
+		if strings.Contains(url, "blog") {
+			return "", "", errors.New("timeout")
+		}
+		return "Go 1.22", "# Go 1.22\n\nNavigation links here.\n\n" +
+			"In Go 1.22 each iteration of a for loop has its own loop variable, fixing a common class of bugs.\n\n" +
+			"Unrelated paragraph about the toolchain installer and the download mirrors for other systems.", nil
+	}
+
+	r := &research.Researcher{LLM: model, Search: search, Fetch: fetcher}
+	report, err := r.Run(context.Background(), "What changed about loop variables in Go 1.22?")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(report.SubQueries) != 3 || len(search.queries) != 3 {
+		t.Errorf("expected the question plus 2 planned queries, got %v (searched %v)", report.SubQueries, search.queries)
+	}
+	if len(report.Sources) != 2 || report.Sources[0].N != 1 || report.Sources[1].N != 2 {
+		t.Fatalf("expected 2 numbered, deduplicated sources, got %+v", report.Sources)
+	}
+
+	first := report.Sources[0]
+	if !first.Fetched || len(first.Passages) != 1 || !strings.Contains(first.Passages[0], "own loop variable") {
+		t.Errorf("expected only the relevant passage from the fetched page, got %+v", first)
+	}
+	second := report.Sources[1]
+	if second.Fetched || len(second.Passages) != 1 || !strings.Contains(second.Passages[0], "snippet") {
+		t.Errorf("expected a snippet fallback for the failed fetch, got %+v", second)
+	}
+	if len(report.Notes) != 1 || !strings.Contains(report.Notes[0], "could not fetch") {
+		t.Errorf("expected a note about the failed fetch, got %v", report.Notes)
+	}
+
+	synthesisPrompt := model.prompts[1]
+	if !strings.Contains(synthesisPrompt, "[1] Go 1.22 release notes (https://go.dev/doc/go1.22)") {
+		t.Errorf("synthesis prompt is missing numbered sources:\n%s", synthesisPrompt)
+	}
+
+	out := report.Format()
+	if !strings.HasPrefix(out, "Go 1.22 changed loop variables") || !strings.Contains(out, "[2] Loopvar blog - https://go.dev/blog/loopvar-preview") {
+		t.Errorf("unexpected formatted report:\n%s", out)
+	}
+}
+
+func TestResearcher_SynthesisFailure(t *testing.T) {
+	model := &scriptedLLM{replies: []string{"[]"}, failFrom: 2}
+	r := &research.Researcher{
+		LLM:     model,
+		Search:  &querySearch{},
+		Options: research.Options{MaxSources: 1},
+	}
+
+	report, err := r.Run(context.Background(), "loop variables")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(report.Sources) != 1 {
+		t.Errorf("expected MaxSources to cap the sources, got %d", len(report.Sources))
+	}
+	if !strings.Contains(report.Answer, "[1]") || len(report.Notes) == 0 {
+		t.Errorf("expected a cited extractive answer and a note, got %q / %v", report.Answer, report.Notes)
+	}
+}
+
+func TestSelectPassages_Budget(t *testing.T) {
+	doc := strings.Repeat("golang generics are type parameters for functions and types in Go programs.\n\n", 10)
+	terms := map[string]bool{"generics": true}
+	passages := research.SelectPassages(doc, terms, 200)
+
+	total := 0
+	for _, p := range passages {
+		total += len(p)
+	}
+	if len(passages) == 0 || total > 200 {
+		t.Errorf("expected passages within the 200 character budget, got %d passages / %d chars", len(passages), total)
+	}
+}
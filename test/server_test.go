@@ -0,0 +1,887 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/agent"
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/cron"
+	"github.com/dev-dhg/yaocc/pkg/llm"
+	"github.com/dev-dhg/yaocc/pkg/messaging"
+	"github.com/dev-dhg/yaocc/pkg/server"
+)
+
+func TestServer_Auth(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{
+		AuthToken: "root-token",
+		APIKeys: []config.APIKey{
+			{Name: "ci", Key: "ci-key", Scopes: []string{"cron"}},
+			{Name: "unset", Key: "", Scopes: []string{"admin"}},
+		},
+	}}
+	srv := server.NewServer(cfg, &agent.Agent{}, nil, nil)
+	handler := srv.Handler()
+
+	status := func(method, path, remote, auth string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	const remote = "203.0.113.7:5000"
+	tests := []struct {
+		name, method, path, auth string
+		want                     int
+	}{
+		{"no token", "GET", "/cron/jobs", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "/cron/jobs", "Bearer nope", http.StatusUnauthorized},
+		{"empty key does not match", "GET", "/cron/jobs", "Bearer ", http.StatusUnauthorized},
+		{"basic scheme", "GET", "/cron/jobs", "Basic cm9vdC10b2tlbg==", http.StatusUnauthorized},
+		{"scoped key", "GET", "/cron/jobs", "Bearer ci-key", http.StatusServiceUnavailable},
+		{"scoped key on other route", "POST", "/exec", "Bearer ci-key", http.StatusForbidden},
+		{"auth token has every scope", "GET", "/chat", "bearer root-token", http.StatusMethodNotAllowed},
+		{"hooks check their own secret", "POST", "/hooks/missing", "", http.StatusServiceUnavailable},
+		{"docs are public", "GET", "/docs", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := status(tt.method, tt.path, remote, tt.auth); got != tt.want {
+			t.Errorf("%s: %s %s = %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	// Without any token, only loopback clients get through
+	srv.SetAuth(config.ServerConfig{})
+	if got := status("GET", "/cron/jobs", remote, ""); got != http.StatusForbidden {
+		t.Errorf("expected remote requests to be refused without a token, got %d", got)
+	}
+	if got := status("GET", "/cron/jobs", "127.0.0.1:5000", ""); got != http.StatusServiceUnavailable {
+		t.Errorf("expected loopback requests to be allowed without a token, got %d", got)
+	}
+}
+
+func TestServer_OpenAIChat(t *testing.T) {
+	// The LLM runs a tool first, then answers with how many messages it was given
+	var mu sync.Mutex
+	var requests []llm.ChatRequest
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		msg := llm.Message{Role: "assistant", Content: "Hello from yaocc"}
+		if last := req.Messages[len(req.Messages)-1]; last.Role == "user" {
+			msg = llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function",
+				Function: llm.FunctionCall{Name: "yaocc_weather_usage", Arguments: "{}"}}}}
+		}
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: msg}}})
+	}))
+	defer llmServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{UseNativeToolCalling: true, Server: config.ServerConfig{AuthToken: "secret"}}
+	a := &agent.Agent{
+		Config:   cfg,
+		LLM:      &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()},
+		Sessions: agent.NewSessionManager(filepath.Join(dir, "sessions")),
+	}
+	api := httptest.NewServer(server.NewServer(cfg, a, nil, nil).Handler())
+	defer api.Close()
+
+	post := func(body, session string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, api.URL+"/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if session != "" {
+			req.Header.Set("X-Session-ID", session)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Without a session, the client's messages are the history and nothing is stored
+	resp := post(`{"model":"yaocc","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Hi"},
+		{"role":"assistant","content":"Hello!"},{"role":"user","content":[{"type":"text","text":"How are you?"}]}],
+		"tools":[{"type":"function","function":{"name":"client_tool"}}]}`, "")
+	var completion struct {
+		Object  string `json:"object"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	json.NewDecoder(resp.Body).Decode(&completion)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || completion.Object != "chat.completion" || len(completion.Choices) != 1 ||
+		completion.Choices[0].Message.Content != "Hello from yaocc" || completion.Choices[0].FinishReason != "stop" {
+		t.Fatalf("unexpected completion (%d): %+v", resp.StatusCode, completion)
+	}
+	first := requests[0].Messages
+	if len(first) != 4 || !strings.HasSuffix(first[0].Content, "Be brief.") || first[2].Content != "Hello!" || first[3].Content != "How are you?" {
+		t.Errorf("expected the client's history behind the system prompt, got %+v", first)
+	}
+	for _, tool := range requests[0].Tools {
+		if tool.Function.Name == "client_tool" {
+			t.Error("expected client tools to be ignored")
+		}
+	}
+	if history, _ := a.Sessions.LoadHistory("general"); len(history) != 0 {
+		t.Errorf("expected nothing to be stored without a session, got %+v", history)
+	}
+
+	// With a session, the answer is streamed and the exchange is stored
+	resp = post(`{"model":"yaocc","stream":true,"messages":[{"role":"user","content":"Hi"}]}`, "webui")
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+	var content strings.Builder
+	var done bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		json.Unmarshal([]byte(data), &chunk)
+		if len(chunk.Choices) == 1 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if !done || content.String() != "Hello from yaocc" {
+		t.Errorf("expected the streamed answer and [DONE], got %q (done %v)", content.String(), done)
+	}
+	if history, _ := a.Sessions.LoadHistory("openai-webui"); len(history) != 2 || history[1].Content != "Hello from yaocc" {
+		t.Errorf("expected the exchange in the prefixed session, got %+v", history)
+	}
+
+	// Client-chosen sessions cannot reach other sessions
+	resp = post(`{"model":"yaocc","user":"x/../general","messages":[{"role":"user","content":"Hi"}]}`, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid session ID to be refused, got %d", resp.StatusCode)
+	}
+	resp = post(`{"model":"yaocc","user":"telegram-42","messages":[{"role":"user","content":"Hi"}]}`, "")
+	resp.Body.Close()
+	if history, _ := a.Sessions.LoadHistory("telegram-42"); resp.StatusCode != http.StatusOK || len(history) != 0 {
+		t.Errorf("expected the user field not to reach the telegram session, got %d %+v", resp.StatusCode, history)
+	}
+}
+
+func TestServer_SessionEvents(t *testing.T) {
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		msg := llm.Message{Role: "assistant", Content: "Done"}
+		if req.Messages[len(req.Messages)-1].Role == "user" {
+			msg = llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function",
+				Function: llm.FunctionCall{Name: "yaocc_websearch_usage", Arguments: "{}"}}}}
+		}
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: msg}}})
+	}))
+	defer llmServer.Close()
+
+	cfg := &config.Config{UseNativeToolCalling: true, Server: config.ServerConfig{AuthToken: "secret"}}
+	a := &agent.Agent{
+		Config:   cfg,
+		LLM:      &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()},
+		Sessions: agent.NewSessionManager(filepath.Join(t.TempDir(), "sessions")),
+		Events:   agent.NewEventBus(),
+	}
+	api := httptest.NewServer(server.NewServer(cfg, a, nil, nil).Handler())
+	defer api.Close()
+
+	// Server-sent events, authenticated through the query parameter
+	resp, err := http.Get(api.URL + "/sessions/s1/events?access_token=secret")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected an event stream, got %v (%v)", resp, err)
+	}
+	defer resp.Body.Close()
+	sse := bufio.NewReader(resp.Body)
+	sse.ReadString('\n') // ": connected"
+
+	// WebSocket
+	conn, err := net.Dial("tcp", strings.TrimPrefix(api.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /sessions/s1/events HTTP/1.1\r\nHost: x\r\nAuthorization: Bearer secret\r\nUpgrade: websocket\r\n"+
+		"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	ws := bufio.NewReader(conn)
+	status, _ := ws.ReadString('\n')
+	for line := status; line != "\r\n"; line, _ = ws.ReadString('\n') {
+		if strings.HasPrefix(line, "Sec-WebSocket-Accept:") && strings.TrimSpace(strings.TrimPrefix(line, "Sec-WebSocket-Accept:")) != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("unexpected accept header %q", line)
+		}
+	}
+	if !strings.Contains(status, "101") {
+		t.Fatalf("expected a WebSocket upgrade, got %q", status)
+	}
+
+	for _, session := range []string{"other", "s1"} {
+		req, _ := http.NewRequest(http.MethodPost, api.URL+"/chat", strings.NewReader(`{"sessionId":"`+session+`","message":"Search something"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		chat, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chat.Body.Close()
+	}
+
+	want := []string{"turn_start", "llm_request", "llm_response", "tool_start", "tool_finish", "turn_start", "llm_request", "llm_response", "answer"}
+	var got []agent.Event
+	for len(got) < len(want) {
+		line, err := sse.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %d events: %v", len(got), err)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var ev agent.Event
+			json.Unmarshal([]byte(data), &ev)
+			got = append(got, ev)
+		}
+	}
+	for i, ev := range got {
+		if ev.Type != want[i] || ev.Session != "s1" {
+			t.Fatalf("event %d: expected %s for s1, got %+v", i, want[i], ev)
+		}
+	}
+	if got[3].Tool != "yaocc_websearch_usage" || got[3].Status != "Reading the websearch manual" || !strings.Contains(got[4].Result, "USAGE MANUAL") {
+		t.Errorf("expected the tool call with status and result, got %+v / %+v", got[3], got[4])
+	}
+	if got[8].Content != "Done" {
+		t.Errorf("expected the answer, got %+v", got[8])
+	}
+
+	// The first WebSocket frame is the first event
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(ws, head); err != nil || head[0] != 0x81 {
+		t.Fatalf("expected a text frame, got %x (%v)", head, err)
+	}
+	n := int(head[1])
+	if n == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(ws, ext)
+		n = int(ext[0])<<8 | int(ext[1])
+	}
+	payload := make([]byte, n)
+	io.ReadFull(ws, payload)
+	var first agent.Event
+	if err := json.Unmarshal(payload, &first); err != nil || first.Type != "turn_start" || first.Session != "s1" {
+		t.Errorf("expected turn_start over the WebSocket, got %s (%v)", payload, err)
+	}
+}
+
+func TestServer_AsyncChatJobs(t *testing.T) {
+	release := make(chan struct{})
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "slow") {
+			<-release
+		}
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "Answer"}}}})
+	}))
+	defer llmServer.Close()
+
+	callbacks := make(chan server.ChatJob, 2)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job server.ChatJob
+		json.NewDecoder(r.Body).Decode(&job)
+		callbacks <- job
+	}))
+	defer callbackServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{Egress: config.EgressConfig{AllowPrivate: true}}
+	a := &agent.Agent{
+		Config:   cfg,
+		LLM:      &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()},
+		Sessions: agent.NewSessionManager(filepath.Join(dir, "sessions")),
+	}
+	srv := server.NewServer(cfg, a, nil, nil)
+	srv.Jobs = server.NewJobStore(dir)
+	api := httptest.NewServer(srv.Handler())
+	defer api.Close()
+
+	start := func(body string) server.ChatJob {
+		resp, err := http.Post(api.URL+"/chat?async=true", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var job server.ChatJob
+		json.NewDecoder(resp.Body).Decode(&job)
+		if resp.StatusCode != http.StatusAccepted || job.ID == "" || job.Status != server.JobRunning || resp.Header.Get("Location") != "/jobs/"+job.ID {
+			t.Fatalf("expected a running job, got %d %+v", resp.StatusCode, job)
+		}
+		return job
+	}
+	get := func(id string) server.ChatJob {
+		resp, err := http.Get(api.URL + "/jobs/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var job server.ChatJob
+		json.NewDecoder(resp.Body).Decode(&job)
+		return job
+	}
+
+	job := start(`{"message":"Hi","callbackUrl":"` + callbackServer.URL + `"}`)
+	select {
+	case cb := <-callbacks:
+		if cb.ID != job.ID || cb.Status != server.JobSucceeded || cb.Response != "Answer" {
+			t.Errorf("unexpected callback: %+v", cb)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a callback")
+	}
+	if got := get(job.ID); got.Status != server.JobSucceeded || got.Response != "Answer" || got.FinishedAt == nil {
+		t.Errorf("expected the finished job, got %+v", got)
+	}
+
+	// A cancelled job drops its result and sends no callback
+	slow := start(`{"message":"slow one","sessionId":"other","callbackUrl":"` + callbackServer.URL + `"}`)
+	req, _ := http.NewRequest(http.MethodPost, api.URL+"/jobs/"+slow.ID+"/cancel", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the cancel to succeed, got %v (%v)", resp, err)
+	}
+	resp.Body.Close()
+	close(release)
+	if resp, _ := http.DefaultClient.Do(req); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected a second cancel to conflict, got %d", resp.StatusCode)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := get(slow.ID); got.Status != server.JobCancelled || got.Response != "" {
+		t.Errorf("expected the job to stay cancelled, got %+v", got)
+	}
+	select {
+	case cb := <-callbacks:
+		t.Errorf("expected no callback for a cancelled job, got %+v", cb)
+	default:
+	}
+
+	// Jobs survive a restart; running ones are marked as interrupted
+	interrupted, err := server.NewJobStore(dir).Create(server.ChatJob{SessionID: "general", Message: "lost"}, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := server.NewJobStore(dir)
+	if got, ok := reloaded.Get(job.ID); !ok || got.Response != "Answer" {
+		t.Errorf("expected the finished job after a restart, got %+v", got)
+	}
+	if got, _ := reloaded.Get(interrupted.ID); got.Status != server.JobFailed || !strings.Contains(got.Error, "restart") {
+		t.Errorf("expected the running job to be marked as interrupted, got %+v", got)
+	}
+	if jobs := reloaded.List(0); len(jobs) != 3 || jobs[0].ID != interrupted.ID {
+		t.Errorf("expected 3 jobs, newest first, got %+v", jobs)
+	}
+
+	// Finished jobs past the retention period are removed with their files on the next Create
+	reloaded.Retention = time.Nanosecond
+	fresh, err := reloaded.Create(server.ChatJob{SessionID: "general", Message: "new"}, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := reloaded.List(0); len(jobs) != 1 || jobs[0].ID != fresh.ID {
+		t.Errorf("expected only the new job after pruning, got %+v", jobs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "jobs", job.ID+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected the file of a pruned job to be removed, got %v", err)
+	}
+}
+
+func TestServer_StopAndShutdown(t *testing.T) {
+	started := make(chan struct{}, 4)
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // Lets the server notice when the agent aborts the request
+		started <- struct{}{}
+		<-r.Context().Done() // Never answers
+	}))
+	defer llmServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	a := &agent.Agent{
+		Config:   cfg,
+		LLM:      &llm.Client{BaseURL: llmServer.URL, HTTPClient: llmServer.Client()},
+		Sessions: agent.NewSessionManager(filepath.Join(dir, "sessions")),
+	}
+	srv := server.NewServer(cfg, a, nil, nil)
+	api := httptest.NewServer(srv.Handler())
+	defer api.Close()
+
+	stop := func(session string) int {
+		req, _ := http.NewRequest(http.MethodDelete, api.URL+"/chat/"+session+"/current", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// DELETE /chat/{session}/current aborts the turn of a waiting /chat request
+	type result struct {
+		status int
+		body   server.ChatResponse
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Post(api.URL+"/chat", "application/json", strings.NewReader(`{"message":"Hi","sessionId":"s1"}`))
+		if err != nil {
+			done <- result{}
+			return
+		}
+		defer resp.Body.Close()
+		var body server.ChatResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		done <- result{resp.StatusCode, body}
+	}()
+	<-started
+	if status := stop("s1"); status != http.StatusOK {
+		t.Fatalf("expected the stop to succeed, got %d", status)
+	}
+	select {
+	case res := <-done:
+		if res.status != http.StatusInternalServerError || !strings.Contains(res.body.Error, agent.ErrStopped.Error()) {
+			t.Errorf("expected the chat to end as stopped, got %d %+v", res.status, res.body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stopped chat to return")
+	}
+	if status := stop("s1"); status != http.StatusNotFound {
+		t.Errorf("expected nothing left to stop, got %d", status)
+	}
+
+	// A client that disconnects cancels its run
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, api.URL+"/chat", strings.NewReader(`{"message":"Hi","sessionId":"s2"}`))
+	go http.DefaultClient.Do(req)
+	<-started
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Running("s2") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if a.Running("s2") {
+		t.Error("expected the run to end when the client disconnected")
+	}
+
+	// Shutdown cancels the turns still running when its context ends, then refuses new ones
+	runErr := make(chan error, 1)
+	go func() {
+		_, err := a.Run(context.Background(), "s3", nil, "s3", "Hi")
+		runErr <- err
+	}()
+	<-started
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the shutdown to time out, got %v", err)
+	}
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, agent.ErrShuttingDown) {
+			t.Errorf("expected the run to be cancelled by the shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to be cancelled")
+	}
+	if _, err := a.Run(context.Background(), "s4", nil, "s4", "Hi"); !errors.Is(err, agent.ErrShuttingDown) {
+		t.Errorf("expected new runs to be refused, got %v", err)
+	}
+}
+
+func TestServer_AdminAPI(t *testing.T) {
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		json.NewEncoder(w).Encode(llm.ChatResponse{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "OK"}}}})
+	}))
+	defer llmServer.Close()
+
+	dir := t.TempDir()
+	t.Setenv("YAOCC_CONFIG_DIR", dir)
+	t.Setenv("ADMIN_TEST_KEY", "sk-from-env")
+	configPath := filepath.Join(dir, "config.json")
+	raw := `{
+  "models": {
+    "providers": {
+      "local": {"baseUrl": "` + llmServer.URL + `", "apiKey": "${ADMIN_TEST_KEY}", "models": [{"id": "small", "model": "small-1"}, {"id": "big", "model": "big-1"}]},
+      "paid": {"baseUrl": "http://127.0.0.1:1", "apiKey": "sk-literal", "models": [{"id": "pro", "model": "pro-1"}]}
+    },
+    "model": "local/small"
+  },
+  "messaging": [{"provider": "telegram", "telegram": {"enabled": false, "botToken": "123:literal"}}],
+  "cron": [],
+  "server": {"authToken": "root-token"}
+}`
+	if err := os.WriteFile(configPath, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "skills", "hello"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "skills", "hello", "hello.sh"), []byte("#!/bin/sh\necho hello\n"), 0755)
+	os.WriteFile(filepath.Join(dir, "skills", "hello", "SKILL.md"), []byte("# hello\nSays hello.\n"), 0644)
+
+	cfg, _, _, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &agent.Agent{Config: cfg, Sessions: agent.NewSessionManager(dir)}
+	scheduler := cron.NewScheduler(cfg, dir, a, map[string]messaging.Provider{})
+	srv := server.NewServer(cfg, a, nil, scheduler)
+	srv.ConfigPath = configPath
+	handler := srv.Handler()
+
+	do := func(method, path, body string, out interface{}) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer root-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if out != nil && rec.Code < 300 {
+			if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+				t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+			}
+		}
+		return rec.Code
+	}
+
+	// Models
+	var models struct {
+		Selected string             `json:"selected"`
+		Models   []server.ModelInfo `json:"models"`
+	}
+	if code := do("GET", "/models", "", &models); code != http.StatusOK {
+		t.Fatalf("GET /models = %d", code)
+	}
+	if len(models.Models) != 3 || models.Models[0].ID != "local/big" || models.Selected != "local/small" || !models.Models[1].Selected {
+		t.Errorf("unexpected models: %+v", models)
+	}
+	if code := do("POST", "/models/select", `{"model": "nope/none"}`, nil); code != http.StatusNotFound {
+		t.Errorf("selecting an unknown model = %d, want 404", code)
+	}
+	if code := do("POST", "/models/select", `{"model": "local/big"}`, nil); code != http.StatusOK {
+		t.Fatalf("POST /models/select = %d", code)
+	}
+	if srv.Config.Models.Selected != "local/big" || a.Config.Models.Selected != "local/big" {
+		t.Errorf("selection not applied: server %q, agent %q", srv.Config.Models.Selected, a.Config.Models.Selected)
+	}
+	var test struct {
+		OK       bool   `json:"ok"`
+		Response string `json:"response"`
+	}
+	if code := do("POST", "/models/test", "", &test); code != http.StatusOK || !test.OK || test.Response != "OK" {
+		t.Errorf("POST /models/test = %d %+v", code, test)
+	}
+	if code := do("POST", "/models/test", `{"model": "paid/pro"}`, nil); code != http.StatusBadGateway {
+		t.Errorf("testing an unreachable model = %d, want 502", code)
+	}
+
+	// Config: placeholders are shown, literal secrets are not, and a patch keeps both
+	var doc map[string]interface{}
+	if code := do("GET", "/config", "", &doc); code != http.StatusOK {
+		t.Fatalf("GET /config = %d", code)
+	}
+	body, _ := json.Marshal(doc)
+	if !strings.Contains(string(body), "${ADMIN_TEST_KEY}") || strings.Contains(string(body), "sk-literal") || strings.Contains(string(body), "123:literal") {
+		t.Errorf("config not redacted as expected: %s", body)
+	}
+	if code := do("PATCH", "/config", `{"models": {"providers": {"paid": {"apiKey": "********"}}}}`, nil); code != http.StatusBadRequest {
+		t.Errorf("patch with a redacted value = %d, want 400", code)
+	}
+	if code := do("PATCH", "/config", `{"maxTurns": 7, "timezone": "Europe/Berlin"}`, nil); code != http.StatusOK {
+		t.Fatalf("PATCH /config = %d", code)
+	}
+	if code := do("PATCH", "/config", `{"timezone": null, "maxTurns": "many"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("patch with a wrong type = %d, want 400", code)
+	}
+	if code := do("PATCH", "/config", `{"timezone": null}`, nil); code != http.StatusOK {
+		t.Fatalf("PATCH /config = %d", code)
+	}
+	written, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(written), "${ADMIN_TEST_KEY}") || !strings.Contains(string(written), "sk-literal") || strings.Contains(string(written), "Europe/Berlin") {
+		t.Errorf("unexpected config file after patches:\n%s", written)
+	}
+	if srv.Config.MaxTurns != 7 || srv.Config.Models.Providers["local"].APIKey != "sk-from-env" {
+		t.Errorf("patch not applied: maxTurns %d, apiKey %q", srv.Config.MaxTurns, srv.Config.Models.Providers["local"].APIKey)
+	}
+
+	// Skills
+	if code := do("POST", "/skills", `{"name": "hello", "script": "../outside.sh"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("registering a script outside the config dir = %d, want 400", code)
+	}
+	if code := do("POST", "/skills", `{"name": "hello", "script": "skills/hello/hello.sh"}`, nil); code != http.StatusCreated {
+		t.Fatalf("POST /skills = %d", code)
+	}
+	var skill server.SkillInfo
+	if code := do("GET", "/skills/hello", "", &skill); code != http.StatusOK || skill.Script != "skills/hello/hello.sh" || !strings.Contains(skill.Content, "Says hello.") {
+		t.Errorf("GET /skills/hello = %d %+v", code, skill)
+	}
+	var list []server.SkillInfo
+	if do("GET", "/skills", "", &list); len(list) != 1 || list[0].Name != "hello" {
+		t.Errorf("unexpected skills: %+v", list)
+	}
+	if code := do("DELETE", "/skills/hello", "", nil); code != http.StatusOK {
+		t.Errorf("DELETE /skills/hello = %d", code)
+	}
+	if code := do("DELETE", "/skills/hello", "", nil); code != http.StatusNotFound {
+		t.Errorf("deleting an unregistered skill = %d, want 404", code)
+	}
+
+	// Cron jobs
+	if code := do("POST", "/cron/jobs", `{"name": "bad", "schedule": "every blue moon", "prompt": "hi"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("adding a job with an invalid schedule = %d, want 400", code)
+	}
+	var job struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if code := do("POST", "/cron/jobs", `{"name": "greet", "schedule": "0 9 * * *", "prompt": "Say hi"}`, &job); code != http.StatusCreated || job.ID == "" {
+		t.Fatalf("POST /cron/jobs = %d %+v", code, job)
+	}
+	if code := do("POST", "/cron/jobs", `{"name": "Greet", "schedule": "0 9 * * *", "prompt": "Again"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("adding a duplicate job = %d, want 400", code)
+	}
+	if _, ok := scheduler.FindJob(job.ID); !ok {
+		t.Errorf("new job %s not scheduled", job.ID)
+	}
+	if code := do("DELETE", "/cron/jobs/"+job.ID, "", nil); code != http.StatusOK {
+		t.Errorf("DELETE /cron/jobs/%s = %d", job.ID, code)
+	}
+	if _, ok := scheduler.FindJob("greet"); ok {
+		t.Error("removed job is still scheduled")
+	}
+	if code := do("DELETE", "/cron/jobs/greet", "", nil); code != http.StatusNotFound {
+		t.Errorf("deleting a missing job = %d, want 404", code)
+	}
+
+	// Concurrent edits are applied one after another, none is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"name": "job-%d", "schedule": "0 9 * * *", "prompt": "hi"}`, i)
+			if code := do("POST", "/cron/jobs", body, nil); code != http.StatusCreated {
+				t.Errorf("concurrent POST /cron/jobs = %d", code)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if jobs := scheduler.Jobs(); len(jobs) != 8 {
+		t.Errorf("expected 8 jobs after concurrent adds, got %d", len(jobs))
+	}
+
+	// After Stop, reloads (e.g. from the config watcher during shutdown) do nothing
+	scheduler.Stop()
+	scheduler.Stop()
+	scheduler.Reload(srv.Config)
+}
+
+func TestServer_CronScriptScope(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("YAOCC_CONFIG_DIR", dir)
+	configPath := filepath.Join(dir, "config.json")
+	raw := `{
+  "cron": [],
+  "server": {"apiKeys": [
+    {"name": "ci", "key": "ci-key", "scopes": ["cron"]},
+    {"name": "ops", "key": "ops-key", "scopes": ["cron", "exec"]}
+  ]}
+}`
+	if err := os.WriteFile(configPath, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, _, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &agent.Agent{Config: cfg, Sessions: agent.NewSessionManager(dir)}
+	scheduler := cron.NewScheduler(cfg, dir, a, map[string]messaging.Provider{})
+	defer scheduler.Stop()
+	srv := server.NewServer(cfg, a, nil, scheduler)
+	srv.ConfigPath = configPath
+	handler := srv.Handler()
+
+	do := func(method, path, key, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name, method, path, key, body string
+		want                          int
+	}{
+		{"cron key adds a script job", "POST", "/cron/jobs", "ci-key", `{"name": "sh", "schedule": "@hourly", "script": "/bin/sh -c id"}`, http.StatusForbidden},
+		{"cron key adds a digest script", "POST", "/cron/jobs", "ci-key", `{"name": "dg", "schedule": "@hourly", "prompt": "sum up", "digest": [{"script": "/bin/id"}]}`, http.StatusForbidden},
+		{"cron key adds a trigger", "POST", "/cron/jobs", "ci-key", `{"name": "tr", "prompt": "hi", "trigger": {"type": "webhook", "secret": "s"}}`, http.StatusForbidden},
+		{"cron key adds a prompt job", "POST", "/cron/jobs", "ci-key", `{"name": "greet", "schedule": "@hourly", "prompt": "Say hi"}`, http.StatusCreated},
+		{"cron key sets a script", "PATCH", "/cron/jobs/greet", "ci-key", `{"script": "/bin/id"}`, http.StatusForbidden},
+		{"cron key edits the prompt", "PATCH", "/cron/jobs/greet", "ci-key", `{"prompt": "Say hello"}`, http.StatusOK},
+		{"cron key disables", "POST", "/cron/jobs/greet/disable", "ci-key", "", http.StatusOK},
+		{"exec key adds a script job", "POST", "/cron/jobs", "ops-key", `{"name": "sh", "schedule": "@hourly", "script": "true"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.path, tt.key, tt.body); got != tt.want {
+			t.Errorf("%s: %s %s = %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+	if _, ok := scheduler.FindJob("dg"); ok {
+		t.Error("expected the refused job not to be added")
+	}
+}
+
+func TestServer_Hooks(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{APIKeys: []config.APIKey{
+			{Name: "ci", Key: "ci-key", Scopes: []string{"cron"}},
+			{Name: "phone", Key: "phone-key", Scopes: []string{"chat"}},
+		}},
+		Cron: []config.CronJob{
+			{Name: "tokenonly", Script: "true", Type: "script", Trigger: &config.CronTrigger{Type: "webhook"}},
+			{Name: "signed", Script: "true", Type: "script", Trigger: &config.CronTrigger{Type: "webhook", Secret: "s3cret"}},
+			{Name: "both", Script: "true", Type: "script", Trigger: &config.CronTrigger{Type: "webhook", Secret: "s3cret", RequireToken: true}},
+		},
+	}
+	scheduler := cron.NewScheduler(cfg, t.TempDir(), &agent.Agent{}, map[string]messaging.Provider{})
+	handler := server.NewServer(cfg, &agent.Agent{}, nil, scheduler).Handler()
+
+	tests := []struct {
+		name, path, secret, auth string
+		want                     int
+	}{
+		{"no secret needs a token", "/hooks/tokenonly", "", "", http.StatusUnauthorized},
+		{"no secret with a cron token", "/hooks/tokenonly", "", "Bearer ci-key", http.StatusAccepted},
+		{"no secret with a chat token", "/hooks/tokenonly", "", "Bearer phone-key", http.StatusForbidden},
+		{"secret", "/hooks/signed", "s3cret", "", http.StatusAccepted},
+		{"wrong secret", "/hooks/signed", "guess", "", http.StatusUnauthorized},
+		{"secret without the required token", "/hooks/both", "s3cret", "", http.StatusUnauthorized},
+		{"token without the secret", "/hooks/both", "", "Bearer ci-key", http.StatusUnauthorized},
+		{"secret and token", "/hooks/both", "s3cret", "Bearer ci-key", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(`{"id":1}`))
+		req.RemoteAddr = "203.0.113.7:5000"
+		if tt.secret != "" {
+			req.Header.Set("X-Hook-Secret", tt.secret)
+		}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: POST %s = %d, want %d", tt.name, tt.path, rec.Code, tt.want)
+		}
+	}
+
+	// Let the accepted runs finish before the temp dir is removed
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		done := true
+		for _, st := range scheduler.Jobs() {
+			if st.Running || st.LastRun == nil {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+	}
+}
+
+func TestServer_WebSocketOrigin(t *testing.T) {
+	a := &agent.Agent{Events: agent.NewEventBus()}
+	srv := server.NewServer(&config.Config{}, a, nil, nil) // No keys: loopback clients are trusted
+	handler := srv.Handler()
+
+	upgrade := func(host, origin, auth string) int {
+		req := httptest.NewRequest("GET", "/sessions/s1/events", nil)
+		req.Host = host
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The recorder cannot be hijacked, so a request that passes the origin check fails with 500
+	tests := []struct {
+		name, host, origin, auth string
+		want                     int
+	}{
+		{"no origin", "localhost:8080", "", "", http.StatusInternalServerError},
+		{"own loopback origin", "localhost:8080", "http://localhost:8080", "", http.StatusInternalServerError},
+		{"other site", "localhost:8080", "https://evil.example", "", http.StatusForbidden},
+		{"rebound domain", "evil.example:8080", "http://evil.example:8080", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := upgrade(tt.host, tt.origin, tt.auth); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	srv.SetAuth(config.ServerConfig{AuthToken: "secret", AllowedOrigins: []string{"https://dash.example.com"}})
+	if got := upgrade("yaocc.example.com", "https://yaocc.example.com", "Bearer secret"); got != http.StatusInternalServerError {
+		t.Errorf("own origin with a token: got %d", got)
+	}
+	if got := upgrade("yaocc.example.com", "https://dash.example.com", "Bearer secret"); got != http.StatusInternalServerError {
+		t.Errorf("allowed origin: got %d", got)
+	}
+	if got := upgrade("yaocc.example.com", "https://evil.example", "Bearer secret"); got != http.StatusForbidden {
+		t.Errorf("other origin with a token: got %d", got)
+	}
+}
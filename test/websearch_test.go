@@ -0,0 +1,350 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dev-dhg/yaocc/pkg/config"
+	"github.com/dev-dhg/yaocc/pkg/websearch"
+)
+
+type stubProvider struct {
+	results []websearch.SearchResult
+	err     error
+}
+
+func (s stubProvider) Search(query string, opts websearch.SearchOptions) ([]websearch.SearchResult, error) {
+	return s.results, s.err
+}
+
+func TestMetaProvider_Fusion(t *testing.T) {
+	a := stubProvider{results: []websearch.SearchResult{
+		{Title: "Go", Link: "https://go.dev/", Snippet: "short"},
+		{Title: "Tour", Link: "https://go.dev/tour"},
+		{Title: "Only A", Link: "https://a.example/only"},
+	}}
+	b := stubProvider{results: []websearch.SearchResult{
+		{Title: "Tour", Link: "https://www.go.dev/tour?utm_source=x#intro"},
+		{Title: "Go", Link: "http://go.dev", Snippet: "a longer snippet"},
+		{Title: "Only B", Link: "https://b.example/only"},
+	}}
+
+	meta := &websearch.MetaProvider{
+		Name: "meta",
+		Members: []websearch.MetaMember{
+			{Name: "a", Provider: a},
+			{Name: "b", Provider: b},
+			{Name: "down", Provider: stubProvider{err: errors.New("quota exceeded")}},
+		},
+		MaxResults: 3,
+	}
+
+	results, err := meta.Search("golang", websearch.SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	var titles []string
+	for _, r := range results {
+		titles = append(titles, r.Title)
+	}
+	// Both shared URLs outrank single-provider results; "Go" wins the tie as it appeared first
+	if want := []string{"Go", "Tour", "Only A"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("titles = %v, want %v", titles, want)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(results[0].Sources, want) {
+		t.Errorf("sources = %v, want %v", results[0].Sources, want)
+	}
+	if results[0].Snippet != "a longer snippet" {
+		t.Errorf("expected the longer snippet to be kept, got %q", results[0].Snippet)
+	}
+
+	allDown := &websearch.MetaProvider{
+		Members:    []websearch.MetaMember{{Name: "down", Provider: stubProvider{err: errors.New("boom")}}},
+		MaxResults: 5,
+	}
+	if _, err := allDown.Search("golang", websearch.SearchOptions{}); err == nil {
+		t.Errorf("expected an error when every provider fails")
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"https://www.Example.com/path/", "http://example.com/path"},
+		{"https://example.com/p?b=2&a=1&utm_campaign=x", "https://example.com/p?a=1&b=2"},
+		{"https://example.com/p#section", "https://example.com/p"},
+	}
+	for _, tt := range tests {
+		if websearch.NormalizeURL(tt.a) != websearch.NormalizeURL(tt.b) {
+			t.Errorf("NormalizeURL(%q) = %q, NormalizeURL(%q) = %q; want equal",
+				tt.a, websearch.NormalizeURL(tt.a), tt.b, websearch.NormalizeURL(tt.b))
+		}
+	}
+	if websearch.NormalizeURL("https://example.com/a") == websearch.NormalizeURL("https://example.com/b") {
+		t.Errorf("different paths must not collide")
+	}
+}
+
+// fixtureServer serves a recorded provider response, checking the request first.
+func fixtureServer(t *testing.T, fixture string, status int, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "websearch", fixture))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.WriteHeader(status)
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviders_Fixtures(t *testing.T) {
+	tavily := fixtureServer(t, "tavily.json", http.StatusOK, func(r *http.Request) {
+		if r.URL.Path != "/search" || r.Header.Get("Authorization") != "Bearer tvly-test" {
+			t.Errorf("tavily: unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+	})
+	google := fixtureServer(t, "google.json", http.StatusOK, func(r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("cx") != "engine-1" || q.Get("key") != "g-key" || q.Get("num") != "2" {
+			t.Errorf("google: unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	googleQuota := fixtureServer(t, "google_quota.json", http.StatusTooManyRequests, nil)
+	ddg := fixtureServer(t, "duckduckgo.html", http.StatusOK, func(r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("duckduckgo: expected POST, got %s", r.Method)
+		}
+	})
+
+	providers := map[string]config.SearchProvider{
+		"tavily":       {Type: "tavily", APIKey: "tvly-test", Endpoint: tavily.URL, MaxResults: 2},
+		"google":       {Type: "google", APIKey: "g-key", EngineID: "engine-1", Endpoint: google.URL, MaxResults: 2},
+		"ddg":          {Type: "duckduckgo", Endpoint: ddg.URL},
+		"google-quota": {Type: "google", APIKey: "g-key", EngineID: "engine-1", Endpoint: googleQuota.URL, Fallback: "ddg"},
+	}
+
+	tests := []struct {
+		name      string
+		wantLinks []string
+	}{
+		{"tavily", []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics"}},
+		{"google", []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics"}},
+		{"ddg", []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics", "https://gobyexample.com/generics"}},
+		{"google-quota", []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics", "https://gobyexample.com/generics"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := websearch.NewProvider(tt.name, providers[tt.name], providers, t.TempDir(), nil)
+			if err != nil {
+				t.Fatalf("NewProvider: %v", err)
+			}
+			results, err := p.Search("golang generics", websearch.SearchOptions{})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var links []string
+			for _, r := range results {
+				links = append(links, r.Link)
+				if r.Title == "" || r.Snippet == "" {
+					t.Errorf("result %s is missing title or snippet: %+v", r.Link, r)
+				}
+			}
+			if !reflect.DeepEqual(links, tt.wantLinks) {
+				t.Errorf("links = %v, want %v", links, tt.wantLinks)
+			}
+		})
+	}
+
+	if _, err := websearch.NewProvider("google", config.SearchProvider{Type: "google", APIKey: "k"}, providers, "", nil); err == nil {
+		t.Errorf("expected an error for google without engineId")
+	}
+}
+
+func TestSearchOptions_Mapping(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.Form
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	opts := websearch.SearchOptions{
+		Freshness:  "Week",
+		Sites:      []string{"https://go.dev/"},
+		Language:   "en",
+		Region:     "us",
+		SafeSearch: "strict",
+		Category:   "news",
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.SearchProvider
+		want map[string]string
+	}{
+		{"searxng", config.SearchProvider{Type: "searxng", Endpoint: srv.URL}, map[string]string{
+			"q": "generics site:go.dev", "time_range": "week", "language": "en-US", "safesearch": "2", "categories": "news",
+		}},
+		{"google", config.SearchProvider{Type: "google", APIKey: "k", EngineID: "cx", Endpoint: srv.URL}, map[string]string{
+			"siteSearch": "go.dev", "siteSearchFilter": "i", "dateRestrict": "w1", "lr": "lang_en", "gl": "us", "safe": "active",
+		}},
+		{"duckduckgo", config.SearchProvider{Type: "duckduckgo", Endpoint: srv.URL}, map[string]string{
+			"q": "generics site:go.dev", "df": "w", "kl": "us-en", "kp": "1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			p, err := websearch.NewProvider(tt.name, tt.cfg, nil, t.TempDir(), nil)
+			if err != nil {
+				t.Fatalf("NewProvider: %v", err)
+			}
+			p.Search("generics", opts)
+			for k, want := range tt.want {
+				if got.Get(k) != want {
+					t.Errorf("%s = %q, want %q", k, got.Get(k), want)
+				}
+			}
+		})
+	}
+
+	bad := websearch.SearchOptions{Freshness: "hour"}
+	if err := bad.Validate(); err == nil {
+		t.Errorf("expected an error for an unknown freshness")
+	}
+}
+
+func TestQuotaManager_Windows(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	m := websearch.NewQuotaManager(t.TempDir())
+	m.Now = func() time.Time { return now }
+	limits := config.QuotaConfig{PerDay: 2, PerMonth: 3}
+
+	for i := 0; i < 2; i++ {
+		if err := m.Acquire("google", limits); err != nil {
+			t.Fatalf("Acquire %d: %v", i, err)
+		}
+	}
+	var qerr *websearch.QuotaError
+	if err := m.Acquire("google", limits); !errors.As(err, &qerr) || qerr.Window != "day" {
+		t.Fatalf("expected the daily quota to be exhausted, got %v", err)
+	}
+
+	st, err := m.Status("google", limits)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if st.RemainingToday != 0 || st.RemainingMonth != 1 || st.UsedThisMonth != 2 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	// The next day (and month) starts fresh
+	now = now.Add(2 * time.Hour)
+	st, _ = m.Status("google", limits)
+	if st.RemainingToday != 2 || st.RemainingMonth != 3 {
+		t.Errorf("expected the windows to reset, got %+v", st)
+	}
+}
+
+func TestQuotaProvider_Fallback(t *testing.T) {
+	google := fixtureServer(t, "google.json", http.StatusOK, nil)
+	ddg := fixtureServer(t, "duckduckgo.html", http.StatusOK, nil)
+	providers := map[string]config.SearchProvider{
+		"google": {Type: "google", APIKey: "g-key", EngineID: "engine-1", Endpoint: google.URL, MaxResults: 2, Quota: config.QuotaConfig{PerDay: 1}, Fallback: "ddg"},
+		"ddg":    {Type: "duckduckgo", Endpoint: ddg.URL},
+	}
+	tempDir := t.TempDir()
+
+	wantResults := []int{2, 3} // Google's fixture, then DuckDuckGo's once the quota is used up
+	for i, want := range wantResults {
+		p, err := websearch.NewProvider("google", providers["google"], providers, tempDir, nil)
+		if err != nil {
+			t.Fatalf("NewProvider: %v", err)
+		}
+		results, err := p.Search("golang generics", websearch.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search %d: %v", i, err)
+		}
+		if len(results) != want {
+			t.Errorf("search %d: got %d results, want %d", i, len(results), want)
+		}
+	}
+}
+
+func TestQuotaProvider_FallbackCycle(t *testing.T) {
+	google := fixtureServer(t, "google.json", http.StatusOK, nil)
+	providers := map[string]config.SearchProvider{
+		"a": {Type: "google", APIKey: "g-key", EngineID: "engine-1", Endpoint: google.URL, Quota: config.QuotaConfig{PerDay: 1}, Fallback: "b"},
+		"b": {Type: "google", APIKey: "g-key", EngineID: "engine-2", Endpoint: google.URL, Quota: config.QuotaConfig{PerDay: 1}, Fallback: "a"},
+	}
+	tempDir := t.TempDir()
+
+	// a, then b once a is used up, then both are used up and the cycle ends with an error
+	for i := 0; i < 3; i++ {
+		p, err := websearch.NewProvider("a", providers["a"], providers, tempDir, nil)
+		if err != nil {
+			t.Fatalf("NewProvider: %v", err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := p.Search("golang generics", websearch.SearchOptions{})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if (err != nil) != (i == 2) {
+				t.Errorf("search %d: unexpected error %v", i, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("search %d: fallback cycle did not end", i)
+		}
+	}
+}
+
+func TestBraveProvider_LegacyUsage(t *testing.T) {
+	tempDir := t.TempDir()
+	legacyPath := filepath.Join(tempDir, "brave_usage.json")
+	cfg := config.SearchProvider{Type: "brave", APIKey: "b-key"}
+	limits := config.QuotaConfig{PerMonth: 2000}
+
+	// A corrupt file is kept with its counts
+	os.WriteFile(legacyPath, []byte(`{"lastRequest": "20`), 0644)
+	if _, err := websearch.NewBraveProvider("brave", cfg, nil, tempDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		t.Errorf("expected the unreadable legacy file to be kept, got %v", err)
+	}
+
+	data, _ := json.Marshal(websearch.BraveUsage{LastRequest: time.Now(), Count: 150})
+	os.WriteFile(legacyPath, data, 0644)
+	if _, err := websearch.NewBraveProvider("brave", cfg, nil, tempDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("expected the imported legacy file to be removed, got %v", err)
+	}
+	if st, _ := websearch.NewQuotaManager(tempDir).Status("brave", limits); st.RemainingMonth != 1850 {
+		t.Errorf("expected the legacy count to be imported, got %+v", st)
+	}
+}